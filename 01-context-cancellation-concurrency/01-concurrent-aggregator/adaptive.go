@@ -0,0 +1,109 @@
+package main
+
+import (
+	"math"
+	"slices"
+	"sync"
+	"time"
+)
+
+// minAdaptiveSamples is how many latencies a fetcher must report before its
+// p99 is trusted; until then only the aggregator timeout applies
+const minAdaptiveSamples = 10
+
+// latencyTracker keeps a rolling window of recent fetch latencies per
+// fetcher and derives a per-call timeout from their p99
+type latencyTracker struct {
+	mu         sync.Mutex
+	multiplier float64
+	window     int
+	samples    map[string]*latencyWindow
+}
+
+// latencyWindow is a fixed-size ring of the most recent latencies
+type latencyWindow struct {
+	buf  []time.Duration
+	next int
+}
+
+func newLatencyTracker(multiplier float64, window int) *latencyTracker {
+	return &latencyTracker{
+		multiplier: multiplier,
+		window:     window,
+		samples:    make(map[string]*latencyWindow),
+	}
+}
+
+// observe records one latency for the named fetcher, evicting the oldest
+// once the window is full
+func (t *latencyTracker) observe(name string, d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	w, ok := t.samples[name]
+	if !ok {
+		w = &latencyWindow{buf: make([]time.Duration, 0, t.window)}
+		t.samples[name] = w
+	}
+	if len(w.buf) < t.window {
+		w.buf = append(w.buf, d)
+		return
+	}
+	w.buf[w.next] = d
+	w.next = (w.next + 1) % t.window
+}
+
+// p99 returns the 99th percentile of the named fetcher's recent latencies
+func (t *latencyTracker) p99(name string) (time.Duration, bool) {
+	t.mu.Lock()
+	w, ok := t.samples[name]
+	if !ok || len(w.buf) < minAdaptiveSamples {
+		t.mu.Unlock()
+		return 0, false
+	}
+	sorted := slices.Clone(w.buf)
+	t.mu.Unlock()
+
+	slices.Sort(sorted)
+	idx := int(math.Ceil(0.99*float64(len(sorted)))) - 1
+	return sorted[idx], true
+}
+
+// mean returns the average of the named fetcher's recent latencies, or
+// false if it has none yet
+func (t *latencyTracker) mean(name string) (time.Duration, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	w, ok := t.samples[name]
+	if !ok || len(w.buf) == 0 {
+		return 0, false
+	}
+	var total time.Duration
+	for _, d := range w.buf {
+		total += d
+	}
+	return total / time.Duration(len(w.buf)), true
+}
+
+// timeout returns the per-call timeout for the named fetcher, or false if
+// there are not enough samples yet
+func (t *latencyTracker) timeout(name string) (time.Duration, bool) {
+	p99, ok := t.p99(name)
+	if !ok {
+		return 0, false
+	}
+	return time.Duration(float64(p99) * t.multiplier), true
+}
+
+// WithAdaptiveTimeout bounds each fetch by multiplier times the fetcher's
+// observed p99 latency over its last window calls. Successful calls are
+// sampled at their latency and calls cut off by the adaptive timeout at the
+// timeout, so the estimate climbs again if the fetcher slows down. Fetchers
+// with fewer than minAdaptiveSamples observations are bounded only by the
+// aggregator timeout, which also remains the overall ceiling.
+func WithAdaptiveTimeout(multiplier float64, window int) Option {
+	return func(a *UserAggregator) {
+		a.latencies = newLatencyTracker(multiplier, max(window, minAdaptiveSamples))
+	}
+}
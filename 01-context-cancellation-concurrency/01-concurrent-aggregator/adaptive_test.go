@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLatencyTracker_TimeoutTracksP99(t *testing.T) {
+	tracker := newLatencyTracker(2, 100)
+
+	// 1ms..100ms: p99 is the 99th smallest sample
+	for i := 1; i <= 100; i++ {
+		tracker.observe("profile", time.Duration(i)*time.Millisecond)
+	}
+	if got, want := mustTimeout(t, tracker, "profile"), 198*time.Millisecond; got != want {
+		t.Errorf("expected timeout %v, got %v", want, got)
+	}
+
+	// A full window of fast calls evicts the slow history
+	for i := 0; i < 100; i++ {
+		tracker.observe("profile", 5*time.Millisecond)
+	}
+	if got, want := mustTimeout(t, tracker, "profile"), 10*time.Millisecond; got != want {
+		t.Errorf("expected timeout %v after latencies dropped, got %v", want, got)
+	}
+
+	// A few outliers below the 1% tail do not move p99
+	tracker.observe("profile", time.Second)
+	if got, want := mustTimeout(t, tracker, "profile"), 10*time.Millisecond; got != want {
+		t.Errorf("expected single outlier to be ignored, got %v", got)
+	}
+	tracker.observe("profile", time.Second)
+	if got, want := mustTimeout(t, tracker, "profile"), 2*time.Second; got != want {
+		t.Errorf("expected timeout %v once outliers reach the tail, got %v", want, got)
+	}
+}
+
+func TestLatencyTracker_NeedsMinimumSamples(t *testing.T) {
+	tracker := newLatencyTracker(2, 100)
+
+	for i := 0; i < minAdaptiveSamples-1; i++ {
+		tracker.observe("order", time.Millisecond)
+	}
+	if _, ok := tracker.timeout("order"); ok {
+		t.Error("expected no adaptive timeout before minimum samples")
+	}
+
+	tracker.observe("order", time.Millisecond)
+	if _, ok := tracker.timeout("order"); !ok {
+		t.Error("expected adaptive timeout once minimum samples are reached")
+	}
+	if _, ok := tracker.timeout("profile"); ok {
+		t.Error("expected fetchers to be tracked independently")
+	}
+}
+
+func TestAggregate_AdaptiveTimeoutCutsSlowFetch(t *testing.T) {
+	order := &countingFetcher{delay: 5 * time.Millisecond, result: "Orders: 5"}
+
+	agg := New(
+		WithTimeout(2*time.Second),
+		WithLogger(newTestLogger()),
+		WithFetcher("order", order),
+		WithAdaptiveTimeout(3, 20),
+	)
+	agg.profile.WithDelay(0)
+
+	// Distinct ids so singleflight never shares a fan-out
+	for id := 0; id < 20; id++ {
+		if _, err := agg.Aggregate(context.Background(), id); err != nil {
+			t.Fatalf("warm-up %d: unexpected error: %v", id, err)
+		}
+	}
+
+	// A call far beyond the learned p99 is cut off long before the
+	// aggregator timeout
+	order.delay = 500 * time.Millisecond
+	start := time.Now()
+	_, err := agg.Aggregate(context.Background(), 100)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected adaptive deadline exceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 250*time.Millisecond {
+		t.Errorf("expected slow fetch to be cut off early, took %v", elapsed)
+	}
+}
+
+func TestAggregate_AdaptiveTimeoutRecoversFromLatencyStep(t *testing.T) {
+	order := &countingFetcher{delay: 5 * time.Millisecond, result: "Orders: 5"}
+
+	agg := New(
+		WithTimeout(2*time.Second),
+		WithLogger(newTestLogger()),
+		WithFetcher("order", order),
+		WithAdaptiveTimeout(3, 20),
+	)
+	agg.profile.WithDelay(0)
+
+	for id := 0; id < 20; id++ {
+		if _, err := agg.Aggregate(context.Background(), id); err != nil {
+			t.Fatalf("warm-up %d: unexpected error: %v", id, err)
+		}
+	}
+
+	// The backend slows down for good, well past the learned timeout. The
+	// calls it cuts off raise the estimate until the new latency fits.
+	order.delay = 60 * time.Millisecond
+	recovered := false
+	for id := 100; id < 105; id++ {
+		if _, err := agg.Aggregate(context.Background(), id); err == nil {
+			recovered = true
+			break
+		} else if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected only adaptive timeouts, got %v", err)
+		}
+	}
+	if !recovered {
+		t.Fatal("expected the adaptive timeout to grow to the new latency")
+	}
+	if _, err := agg.Aggregate(context.Background(), 200); err != nil {
+		t.Errorf("expected calls to keep succeeding at the new latency, got %v", err)
+	}
+}
+
+// mustTimeout returns the tracker's timeout for name or fails the test
+func mustTimeout(t *testing.T, tracker *latencyTracker, name string) time.Duration {
+	t.Helper()
+	d, ok := tracker.timeout(name)
+	if !ok {
+		t.Fatalf("expected adaptive timeout for %s", name)
+	}
+	return d
+}
@@ -2,28 +2,128 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/singleflight"
 )
 
 // UserAggregator aggregates user data from multiple services
 type UserAggregator struct {
-	timeout time.Duration
-	logger  *slog.Logger
-	profile *ProfileService
-	order   *OrderService
+	timeout    time.Duration
+	noTimeout  bool
+	logger     *slog.Logger
+	profile    *ProfileService
+	order      *OrderService
+	fetchers   []namedFetcher
+	transforms map[string][]func(string) (string, error)
+	validators []func(*Result) error
+	composer   func(*Result) (string, error)
+	merge      func(existing, incoming, name string) string
+	classifier func(name string, err error) bool
+	fallbacks  map[string]Fallback
+	parallel   int
+	cacheTTLs  map[string]time.Duration
+	cache      *fetchCache
+	resultTTL  time.Duration
+	results    ResultStore
+	batchLimit int
+	raceGroups []firstSuccessGroup
+	scoring    []scoreGroup
+	fastest    []fastestGroup
+	latencies  *latencyTracker
+	breakers   map[string]*circuitBreaker
+	sizeLimits map[string]sizeLimit
+	eventSink  func(Event)
+	grace      time.Duration
+	retries    map[string]retryPolicy
+	inflight   singleflight.Group
+}
+
+// Field is the data returned by a single named fetcher
+type Field struct {
+	Name  string
+	Value string
+}
+
+// Result holds the combined data fetched for a single user.
+// Fields are in fetcher registration order.
+type Result struct {
+	UserID int
+	Fields []Field
+	// Errors has an entry for every fetcher: the error its fetch returned,
+	// or nil if it succeeded. A non-nil error here does not mean the
+	// aggregation failed; the fetcher may be optional or have been
+	// recovered by a fallback or the error classifier.
+	Errors map[string]error
+}
+
+// Get returns the value fetched by the named fetcher
+func (r *Result) Get(name string) (string, bool) {
+	for _, f := range r.Fields {
+		if f.Name == name {
+			return f.Value, true
+		}
+	}
+	return "", false
+}
+
+// String formats the result as the dashboard summary line
+func (r *Result) String() string {
+	values := make([]string, len(r.Fields))
+	for i, f := range r.Fields {
+		values[i] = f.Value
+	}
+	return "User: " + strings.Join(values, " | ")
+}
+
+// Merge folds the field values into one string in registration order.
+// merge receives the values combined so far, the next fetcher's value and
+// that fetcher's name, and returns the new combined value.
+func (r *Result) Merge(merge func(existing, incoming, name string) string) string {
+	if len(r.Fields) == 0 {
+		return ""
+	}
+	merged := r.Fields[0].Value
+	for _, f := range r.Fields[1:] {
+		merged = merge(merged, f.Value, f.Name)
+	}
+	return merged
 }
 
 // Option configures UserAggregator
 type Option func(*UserAggregator)
 
-// WithTimeout sets the timeout for aggregation operations
+// defaultTimeout bounds aggregations unless WithTimeout or WithNoTimeout
+// says otherwise
+const defaultTimeout = 5 * time.Second
+
+// WithTimeout sets the timeout for aggregation operations. A zero or
+// negative d restores the default rather than disabling the bound, so a
+// stuck fetcher can't hang Aggregate; use WithNoTimeout for that.
 func WithTimeout(d time.Duration) Option {
 	return func(a *UserAggregator) {
+		if d <= 0 {
+			d = defaultTimeout
+		}
 		a.timeout = d
+		a.noTimeout = false
+	}
+}
+
+// WithNoTimeout removes the aggregator's own timeout, leaving aggregations
+// bounded only by the caller's ctx deadline and any per-call or adaptive
+// timeouts. A fan-out shared by concurrent callers keeps the deadline of the
+// caller that started it.
+func WithNoTimeout() Option {
+	return func(a *UserAggregator) {
+		a.noTimeout = true
 	}
 }
 
@@ -34,68 +134,432 @@ func WithLogger(logger *slog.Logger) Option {
 	}
 }
 
+// WithFetcher registers a fetcher under name. Registering an existing name
+// (such as the default "profile" or "order") replaces that fetcher in place.
+// A nil f is dropped with a warning by New, leaving name unregistered.
+func WithFetcher(name string, f Fetcher) Option {
+	return func(a *UserAggregator) {
+		for i := range a.fetchers {
+			if a.fetchers[i].name == name {
+				a.fetchers[i].fetcher = f
+				a.fetchers[i].optional = false
+				return
+			}
+		}
+		a.fetchers = append(a.fetchers, namedFetcher{name: name, fetcher: f})
+	}
+}
+
+// WithOptionalFetcher registers a fetcher like WithFetcher, except that its
+// failure contributes an empty value instead of failing the aggregation.
+// The error is still reported in Result.Errors.
+func WithOptionalFetcher(name string, f Fetcher) Option {
+	return func(a *UserAggregator) {
+		WithFetcher(name, f)(a)
+		for i := range a.fetchers {
+			if a.fetchers[i].name == name {
+				a.fetchers[i].optional = true
+			}
+		}
+	}
+}
+
+// WithTransform adds a normalization step applied to the named fetcher's
+// result before it is combined. Transforms for the same fetcher run in the
+// order they were added; an error fails that fetcher.
+func WithTransform(name string, fn func(string) (string, error)) Option {
+	return func(a *UserAggregator) {
+		if a.transforms == nil {
+			a.transforms = make(map[string][]func(string) (string, error))
+		}
+		a.transforms[name] = append(a.transforms[name], fn)
+	}
+}
+
+// WithComposer replaces the default formatting of the combined result.
+// The composer sees Result.Fields in fetcher registration order, regardless
+// of the order in which the fetches completed. An error it returns reaches
+// the caller as a *ComposeError.
+func WithComposer(compose func(*Result) (string, error)) Option {
+	return func(a *UserAggregator) {
+		a.composer = compose
+	}
+}
+
+// ComposeError is returned by Aggregate when every fetch succeeded but the
+// composer failed, so callers can tell a rendering bug from a failed
+// dependency. Err is the composer's error.
+type ComposeError struct {
+	UserID int
+	Err    error
+}
+
+func (e *ComposeError) Error() string {
+	return fmt.Sprintf("compose result for user %d: %v", e.UserID, e.Err)
+}
+
+func (e *ComposeError) Unwrap() error {
+	return e.Err
+}
+
+// WithMergeStrategy controls how the default composer combines the fetcher
+// results, e.g. to concatenate, dedupe overlapping data or prefer the first
+// value. It has no effect when WithComposer is used; custom composers can
+// call Result.Merge themselves.
+func WithMergeStrategy(merge func(existing, incoming, name string) string) Option {
+	return func(a *UserAggregator) {
+		a.merge = merge
+	}
+}
+
+// WithErrorClassifier decides which fetcher errors are not failures.
+// When classify returns true the fetcher contributes an empty result
+// instead of failing the aggregation (e.g. a 404 meaning "no orders").
+func WithErrorClassifier(classify func(name string, err error) (treatAsEmpty bool)) Option {
+	return func(a *UserAggregator) {
+		a.classifier = classify
+	}
+}
+
+// WithFetchParallelism caps how many fetchers run at the same time.
+// Zero or negative means no limit (the default).
+func WithFetchParallelism(n int) Option {
+	return func(a *UserAggregator) {
+		a.parallel = n
+	}
+}
+
+// WithFetcherCache caches the named fetcher's results per user id for ttl,
+// so later aggregations reuse them instead of calling the fetcher again
+func WithFetcherCache(name string, ttl time.Duration) Option {
+	return func(a *UserAggregator) {
+		if a.cacheTTLs == nil {
+			a.cacheTTLs = make(map[string]time.Duration)
+		}
+		a.cacheTTLs[name] = ttl
+	}
+}
+
+// WithValidator adds a check run against the combined result after all
+// fetches succeed. A non-nil error fails the aggregation.
+func WithValidator(v func(*Result) error) Option {
+	return func(a *UserAggregator) {
+		a.validators = append(a.validators, v)
+	}
+}
+
 // New creates a new UserAggregator with the provided options
 func New(opts ...Option) *UserAggregator {
 	agg := &UserAggregator{
-		timeout: 5 * time.Second, // default timeout
+		timeout: defaultTimeout,
 		logger:  slog.Default(),
 		profile: NewProfileService(),
 		order:   NewOrderService(),
+		cache:   newFetchCache(),
+		results: newMemoryResultStore(),
+	}
+	agg.composer = func(r *Result) (string, error) {
+		if agg.merge != nil {
+			return "User: " + r.Merge(agg.merge), nil
+		}
+		return r.String(), nil
+	}
+
+	// Default fetchers, replaceable via WithFetcher
+	agg.fetchers = []namedFetcher{
+		{name: "profile", fetcher: agg.profile},
+		{name: "order", fetcher: agg.order},
 	}
 
 	for _, opt := range opts {
 		opt(agg)
 	}
+	agg.dropNilFetchers()
+	agg.applyFirstSuccessGroups()
+	agg.applyScoreGroups()
+	agg.applyFastestGroups()
 
 	return agg
 }
 
-// Aggregate fetches data from Profile and Order services concurrently
-// Returns combined result or error if any service fails or timeout occurs
-func (a *UserAggregator) Aggregate(ctx context.Context, id int) (string, error) {
-	// Create context with timeout
-	ctx, cancel := context.WithTimeout(ctx, a.timeout)
-	defer cancel()
-
-	// Create errgroup with context for automatic cancellation
-	g, gCtx := errgroup.WithContext(ctx)
+// dropNilFetchers unregisters fetchers that were registered as nil, which
+// would otherwise panic on every aggregation
+func (a *UserAggregator) dropNilFetchers() {
+	a.fetchers = slices.DeleteFunc(a.fetchers, func(nf namedFetcher) bool {
+		if isNilFetcher(nf.fetcher) {
+			a.logger.Warn("skipping nil fetcher", "fetcher", nf.name)
+			return true
+		}
+		return false
+	})
+}
 
-	var profileResult string
-	var orderResult string
+// Aggregate fetches data from all registered fetchers concurrently
+// Returns combined result or error if any service fails or timeout occurs
+//
+// Concurrent calls for the same id share a single fan-out and receive the
+// same result. The shared fan-out is bounded by the aggregator timeout and
+// is not cancelled when one caller gives up; each caller still returns as
+// soon as its own ctx is done.
+//
+// CallOptions override the aggregator's settings for this call only.
+func (a *UserAggregator) Aggregate(ctx context.Context, id int, opts ...CallOption) (string, error) {
+	cfg := a.callConfig(opts)
+	return a.execute(ctx, id, cfg, cfg.inflightKey(id))
+}
 
-	// Fetch profile data concurrently
-	g.Go(func() error {
-		a.logger.Info("fetching profile", "user_id", id)
-		result, err := a.profile.Fetch(gCtx, id)
+// execute serves one call with resolved settings: from the result cache if
+// possible, otherwise from a fan-out shared by calls with the same
+// inflightKey
+func (a *UserAggregator) execute(ctx context.Context, id int, cfg callConfig, inflightKey string) (string, error) {
+	if a.resultTTL > 0 && !cfg.noCache {
+		result, ok, err := a.results.Get(ctx, id)
 		if err != nil {
-			a.logger.Error("profile fetch failed", "error", err, "user_id", id)
-			return fmt.Errorf("profile service: %w", err)
+			a.logger.Warn("result cache read failed", "error", err, "user_id", id)
+		} else if ok {
+			a.logger.Info("aggregation served from result cache", "user_id", id)
+			return result, nil
 		}
-		profileResult = result
-		a.logger.Info("profile fetched successfully", "user_id", id)
-		return nil
-	})
+	}
 
-	// Fetch order data concurrently
-	g.Go(func() error {
-		a.logger.Info("fetching orders", "user_id", id)
-		result, err := a.order.Fetch(gCtx, id)
-		if err != nil {
-			a.logger.Error("order fetch failed", "error", err, "user_id", id)
-			return fmt.Errorf("order service: %w", err)
+	ch := a.inflight.DoChan(inflightKey, func() (any, error) {
+		fanCtx := context.WithoutCancel(ctx)
+		// Detaching drops the caller's deadline along with its cancellation,
+		// and under WithNoTimeout nothing else would bound the fan-out
+		if deadline, ok := ctx.Deadline(); ok && cfg.noTimeout {
+			var cancel context.CancelFunc
+			fanCtx, cancel = context.WithDeadline(fanCtx, deadline)
+			defer cancel()
 		}
-		orderResult = result
-		a.logger.Info("orders fetched successfully", "user_id", id)
-		return nil
+		return a.aggregate(fanCtx, id, cfg)
 	})
 
+	select {
+	case res := <-ch:
+		if res.Shared {
+			a.logger.Debug("aggregation shared with concurrent callers", "user_id", id)
+		}
+		if res.Err != nil {
+			return "", res.Err
+		}
+		return res.Val.(string), nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// aggregate performs one fan-out across the registered fetchers
+func (a *UserAggregator) aggregate(ctx context.Context, id int, cfg callConfig) (_ string, err error) {
+	start := time.Now()
+	outcomes := make([]fetchOutcome, len(a.fetchers))
+	defer func() {
+		a.logSummary(id, cfg.tag, start, outcomes, err)
+		a.emitEvent(id, cfg.tag, start, outcomes, err)
+	}()
+
+	// Progress counters for the grace extension
+	var succeeded atomic.Int32
+	var pending atomic.Int32
+	pending.Store(int32(len(a.fetchers)))
+
+	// Create context with timeout
+	if !cfg.noTimeout {
+		var cancel context.CancelFunc
+		if a.grace > 0 {
+			ctx, cancel = withGrace(ctx, cfg.timeout, a.grace, func() bool {
+				if succeeded.Load() == 0 || pending.Load() == 0 {
+					return false
+				}
+				a.logger.Warn("aggregation timeout extended", "user_id", id, "extension", a.grace,
+					"succeeded", succeeded.Load(), "pending", pending.Load())
+				return true
+			})
+		} else {
+			ctx, cancel = context.WithTimeout(ctx, cfg.timeout)
+		}
+		defer cancel()
+	}
+
+	// Create errgroup with context for automatic cancellation
+	g, gCtx := errgroup.WithContext(ctx)
+	if cfg.parallel > 0 {
+		g.SetLimit(cfg.parallel)
+	}
+
+	// Results are stored by registration index, never by completion order,
+	// so composition is deterministic. Each goroutine writes only its own
+	// slot, so no locking is needed.
+	fields := make([]Field, len(a.fetchers))
+
+	for i, nf := range a.fetchers {
+		g.Go(func() error {
+			out := &outcomes[i]
+			out.name = nf.name
+			fetchStart := time.Now()
+			result, err := a.runFetcher(gCtx, nf, id, cfg, out)
+			out.finish(fetchStart, err)
+			pending.Add(-1)
+			if err != nil {
+				return err
+			}
+			succeeded.Add(1)
+			fields[i] = Field{Name: nf.name, Value: result}
+			return nil
+		})
+	}
+
 	// Wait for all goroutines to complete or fail
 	if err := g.Wait(); err != nil {
 		return "", err
 	}
 
 	// Combine results
-	result := fmt.Sprintf("User: %s | %s", profileResult, orderResult)
+	res := &Result{
+		UserID: id,
+		Fields: fields,
+		Errors: make(map[string]error, len(outcomes)),
+	}
+	for _, o := range outcomes {
+		res.Errors[o.name] = o.fetchErr
+	}
+
+	// Validate the combined result before handing it back
+	for _, validate := range a.validators {
+		if err := validate(res); err != nil {
+			a.logger.Error("result validation failed", "error", err, "user_id", id)
+			return "", fmt.Errorf("validation: %w", err)
+		}
+	}
+
+	result, err := a.composer(res)
+	if err != nil {
+		a.logger.Error("result composition failed", "error", err, "user_id", id)
+		return "", &ComposeError{UserID: id, Err: err}
+	}
+	if a.resultTTL > 0 {
+		if err := a.results.Set(ctx, id, result, a.resultTTL); err != nil {
+			a.logger.Warn("result cache write failed", "error", err, "user_id", id)
+		}
+	}
 	a.logger.Info("aggregation completed", "user_id", id, "result", result)
 	return result, nil
 }
+
+// runFetcher produces one fetcher's contribution: served from the cache when
+// possible, otherwise fetched, cached and passed through its transforms.
+// Cache hits are recorded in out.
+func (a *UserAggregator) runFetcher(ctx context.Context, nf namedFetcher, id int, cfg callConfig, out *fetchOutcome) (string, error) {
+	ttl, cacheable := a.cacheTTLs[nf.name]
+
+	result, hit := "", false
+	if cacheable && !cfg.noCache {
+		result, hit = a.cache.get(nf.name, id)
+	}
+
+	if hit {
+		out.cached = true
+		a.logger.Info("fetch served from cache", "fetcher", nf.name, "user_id", id)
+	} else {
+		a.logger.Info("fetching", "fetcher", nf.name, "user_id", id)
+		var err error
+		result, err = a.fetch(ctx, nf, id)
+		out.fetchErr = err
+		if err != nil && a.classifier != nil && a.classifier(nf.name, err) {
+			a.logger.Info("fetch error treated as empty result", "fetcher", nf.name, "error", err, "user_id", id)
+			return "", nil
+		}
+		if err != nil {
+			if fallback := a.fallbacks[nf.name].handlerFor(err); fallback != nil {
+				return a.runFallback(nf.name, id, err, fallback)
+			}
+			if nf.optional {
+				a.logger.Warn("optional fetch failed, leaving it empty", "fetcher", nf.name, "error", err, "user_id", id)
+				return "", nil
+			}
+			a.logger.Error("fetch failed", "fetcher", nf.name, "error", err, "user_id", id)
+			return "", fmt.Errorf("%s service: %w", nf.name, err)
+		}
+		if cacheable {
+			a.cache.set(nf.name, id, result, ttl)
+		}
+		a.logger.Info("fetched successfully", "fetcher", nf.name, "user_id", id)
+	}
+
+	for _, transform := range a.transforms[nf.name] {
+		var err error
+		if result, err = transform(result); err != nil {
+			a.logger.Error("transform failed", "fetcher", nf.name, "error", err, "user_id", id)
+			return "", fmt.Errorf("%s service: transform: %w", nf.name, err)
+		}
+	}
+
+	return result, nil
+}
+
+// fetch calls the fetcher through its circuit breaker, if any. An oversized
+// result counts against the breaker like any other failure; a fetch that
+// succeeded after retries counts as one success.
+func (a *UserAggregator) fetch(ctx context.Context, nf namedFetcher, id int) (string, error) {
+	breaker := a.breakers[nf.name]
+	if breaker == nil {
+		return a.retryingFetch(ctx, nf, id)
+	}
+
+	if !breaker.allow() {
+		a.logger.Warn("fetch short-circuited", "fetcher", nf.name, "user_id", id)
+		return "", ErrCircuitOpen
+	}
+	result, err := a.retryingFetch(ctx, nf, id)
+	breaker.record(err)
+	return result, err
+}
+
+// limitedFetch calls the fetcher and enforces its response size limit, if any
+func (a *UserAggregator) limitedFetch(ctx context.Context, nf namedFetcher, id int) (string, error) {
+	result, err := a.timedFetch(ctx, nf, id)
+	limit, ok := a.sizeLimits[nf.name]
+	if err != nil || !ok {
+		return result, err
+	}
+	return limit.apply(result)
+}
+
+// timedFetch calls the fetcher, bounded by its adaptive timeout when enabled
+func (a *UserAggregator) timedFetch(ctx context.Context, nf namedFetcher, id int) (string, error) {
+	if a.latencies == nil {
+		return a.safeFetch(ctx, nf, id)
+	}
+
+	fetchCtx := ctx
+	timeout, adaptive := a.latencies.timeout(nf.name)
+	if adaptive {
+		var cancel context.CancelFunc
+		fetchCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+		a.logger.Debug("applying adaptive timeout", "fetcher", nf.name, "timeout", timeout, "user_id", id)
+	}
+
+	start := time.Now()
+	result, err := a.safeFetch(fetchCtx, nf, id)
+	switch {
+	case err == nil:
+		a.latencies.observe(nf.name, time.Since(start))
+	case adaptive && ctx.Err() == nil && errors.Is(fetchCtx.Err(), context.DeadlineExceeded):
+		// Cut off by its own adaptive timeout: sampled at the timeout, or a
+		// fetcher that slowed down for good would never be observed again
+		// and its estimate could only fall
+		a.latencies.observe(nf.name, timeout)
+	}
+	return result, err
+}
+
+// runFallback substitutes the fallback's result for a failed fetch
+func (a *UserAggregator) runFallback(name string, id int, fetchErr error, fallback func(int, error) (string, error)) (string, error) {
+	result, err := fallback(id, fetchErr)
+	if err != nil {
+		a.logger.Error("fallback failed", "fetcher", name, "fetch_error", fetchErr, "error", err, "user_id", id)
+		return "", fmt.Errorf("%s service: fallback: %w", name, err)
+	}
+	a.logger.Warn("fetch failed, using fallback", "fetcher", name, "error", fetchErr, "user_id", id)
+	return result, nil
+}
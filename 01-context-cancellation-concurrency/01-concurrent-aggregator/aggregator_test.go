@@ -0,0 +1,611 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestLogger returns a logger that discards output to keep tests quiet
+func newTestLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+func TestAggregate_ValidatorRejectsEmptyProfile(t *testing.T) {
+	errEmptyProfile := errors.New("profile is empty")
+
+	agg := New(
+		WithTimeout(2*time.Second),
+		WithLogger(newTestLogger()),
+		WithValidator(func(r *Result) error {
+			if profile, _ := r.Get("profile"); profile == "" {
+				return errEmptyProfile
+			}
+			return nil
+		}),
+	)
+	agg.profile.WithDelay(0).WithResult("")
+	agg.order.WithDelay(0)
+
+	result, err := agg.Aggregate(context.Background(), 1)
+	if err == nil {
+		t.Fatalf("expected validation error, got result %q", result)
+	}
+	if !errors.Is(err, errEmptyProfile) {
+		t.Errorf("expected error wrapping %v, got %v", errEmptyProfile, err)
+	}
+	if result != "" {
+		t.Errorf("expected empty result on validation failure, got %q", result)
+	}
+}
+
+func TestAggregate_ValidatorAcceptsResult(t *testing.T) {
+	var validated *Result
+
+	agg := New(
+		WithTimeout(2*time.Second),
+		WithLogger(newTestLogger()),
+		WithValidator(func(r *Result) error {
+			validated = r
+			return nil
+		}),
+	)
+	agg.profile.WithDelay(0)
+	agg.order.WithDelay(0)
+
+	result, err := agg.Aggregate(context.Background(), 7)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "User: Name: Alice | Orders: 5"; result != want {
+		t.Errorf("expected %q, got %q", want, result)
+	}
+	if validated == nil || validated.UserID != 7 {
+		t.Errorf("validator did not receive the combined result: %+v", validated)
+	}
+}
+
+// countingFetcher records how many times it was invoked
+type countingFetcher struct {
+	calls  atomic.Int32
+	delay  time.Duration
+	result string
+}
+
+func (f *countingFetcher) Fetch(ctx context.Context, id int) (string, error) {
+	f.calls.Add(1)
+	select {
+	case <-time.After(f.delay):
+		return f.result, nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+func TestAggregate_CoalescesConcurrentCallsForSameID(t *testing.T) {
+	profile := &countingFetcher{delay: 200 * time.Millisecond, result: "Name: Alice"}
+	order := &countingFetcher{delay: 200 * time.Millisecond, result: "Orders: 5"}
+
+	agg := New(
+		WithTimeout(2*time.Second),
+		WithLogger(newTestLogger()),
+		WithFetcher("profile", profile),
+		WithFetcher("order", order),
+	)
+
+	const callers = 10
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	results := make([]string, callers)
+	errs := make([]error, callers)
+
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			results[i], errs[i] = agg.Aggregate(context.Background(), 42)
+		}(i)
+	}
+
+	close(start)
+	wg.Wait()
+
+	for i := 0; i < callers; i++ {
+		if errs[i] != nil {
+			t.Errorf("caller %d: unexpected error: %v", i, errs[i])
+		}
+		if results[i] != results[0] {
+			t.Errorf("caller %d: expected shared result %q, got %q", i, results[0], results[i])
+		}
+	}
+
+	if got := profile.calls.Load(); got != 1 {
+		t.Errorf("expected profile fetcher to run once, ran %d times", got)
+	}
+	if got := order.calls.Load(); got != 1 {
+		t.Errorf("expected order fetcher to run once, ran %d times", got)
+	}
+}
+
+func TestAggregate_DoesNotCoalesceDifferentIDs(t *testing.T) {
+	profile := &countingFetcher{delay: 50 * time.Millisecond, result: "Name: Alice"}
+	order := &countingFetcher{delay: 50 * time.Millisecond, result: "Orders: 5"}
+
+	agg := New(
+		WithTimeout(2*time.Second),
+		WithLogger(newTestLogger()),
+		WithFetcher("profile", profile),
+		WithFetcher("order", order),
+	)
+
+	var wg sync.WaitGroup
+	for id := 1; id <= 3; id++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			if _, err := agg.Aggregate(context.Background(), id); err != nil {
+				t.Errorf("id %d: unexpected error: %v", id, err)
+			}
+		}(id)
+	}
+	wg.Wait()
+
+	if got := profile.calls.Load(); got != 3 {
+		t.Errorf("expected one profile fetch per id (3), got %d", got)
+	}
+}
+
+func TestAggregate_TransformAppliedBeforeCompose(t *testing.T) {
+	agg := New(
+		WithTimeout(2*time.Second),
+		WithLogger(newTestLogger()),
+		WithTransform("profile", func(s string) (string, error) {
+			return strings.ToUpper(s), nil
+		}),
+	)
+	agg.profile.WithDelay(0)
+	agg.order.WithDelay(0)
+
+	result, err := agg.Aggregate(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "User: NAME: ALICE | Orders: 5"; result != want {
+		t.Errorf("expected %q, got %q", want, result)
+	}
+}
+
+func TestAggregate_TransformErrorFailsFetcher(t *testing.T) {
+	errBadOrders := errors.New("cannot parse orders")
+
+	agg := New(
+		WithTimeout(2*time.Second),
+		WithLogger(newTestLogger()),
+		WithTransform("order", func(string) (string, error) {
+			return "", errBadOrders
+		}),
+	)
+	agg.profile.WithDelay(0)
+	agg.order.WithDelay(0)
+
+	_, err := agg.Aggregate(context.Background(), 1)
+	if !errors.Is(err, errBadOrders) {
+		t.Fatalf("expected transform error, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "order service") {
+		t.Errorf("expected error to name the order fetcher, got %v", err)
+	}
+}
+
+func TestAggregate_ComposerSeesRegistrationOrder(t *testing.T) {
+	names := []string{"a", "b", "c", "d", "e"}
+
+	opts := []Option{
+		WithTimeout(2 * time.Second),
+		WithLogger(newTestLogger()),
+		WithComposer(func(r *Result) (string, error) {
+			parts := make([]string, len(r.Fields))
+			for i, f := range r.Fields {
+				parts[i] = f.Name + "=" + f.Value
+			}
+			return strings.Join(parts, ","), nil
+		}),
+	}
+	// Later registrations finish first, so completion order is the reverse
+	// of registration order
+	for i, name := range names {
+		opts = append(opts, WithFetcher(name, &countingFetcher{
+			delay:  time.Duration(len(names)-i) * 20 * time.Millisecond,
+			result: strings.ToUpper(name),
+		}))
+	}
+
+	agg := New(opts...)
+	agg.profile.WithDelay(0)
+	agg.order.WithDelay(0)
+
+	result, err := agg.Aggregate(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := "profile=Name: Alice,order=Orders: 5,a=A,b=B,c=C,d=D,e=E"
+	if result != want {
+		t.Errorf("expected %q, got %q", want, result)
+	}
+}
+
+func TestAggregate_ComposerError(t *testing.T) {
+	errCompose := errors.New("cannot render")
+
+	agg := New(
+		WithTimeout(2*time.Second),
+		WithLogger(newTestLogger()),
+		WithComposer(func(*Result) (string, error) {
+			return "", errCompose
+		}),
+	)
+	agg.profile.WithDelay(0)
+	agg.order.WithDelay(0)
+
+	result, err := agg.Aggregate(context.Background(), 1)
+	if !errors.Is(err, errCompose) {
+		t.Errorf("expected composer error, got %v", err)
+	}
+	var composeErr *ComposeError
+	if !errors.As(err, &composeErr) {
+		t.Fatalf("expected a *ComposeError, got %T", err)
+	}
+	if composeErr.UserID != 1 || composeErr.Err != errCompose {
+		t.Errorf("expected the composer's error for user 1, got %+v", composeErr)
+	}
+	if result != "" {
+		t.Errorf("expected no result, got %q", result)
+	}
+}
+
+func TestAggregate_MergeStrategyDedupes(t *testing.T) {
+	var names []string
+
+	agg := New(
+		WithTimeout(2*time.Second),
+		WithLogger(newTestLogger()),
+		WithFetcher("profile", FetcherFunc(func(context.Context, int) (string, error) {
+			return "Name: Alice, Tier: Gold", nil
+		})),
+		WithFetcher("order", FetcherFunc(func(context.Context, int) (string, error) {
+			return "Tier: Gold, Orders: 5", nil
+		})),
+		WithMergeStrategy(func(existing, incoming, name string) string {
+			names = append(names, name)
+			parts := strings.Split(existing, ", ")
+			for _, p := range strings.Split(incoming, ", ") {
+				if !slices.Contains(parts, p) {
+					parts = append(parts, p)
+				}
+			}
+			return strings.Join(parts, ", ")
+		}),
+	)
+
+	result, err := agg.Aggregate(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "User: Name: Alice, Tier: Gold, Orders: 5"; result != want {
+		t.Errorf("expected %q, got %q", want, result)
+	}
+	if len(names) != 1 || names[0] != "order" {
+		t.Errorf("expected merge to be called once for order, got %v", names)
+	}
+}
+
+func TestAggregate_MergeStrategyPreferFirst(t *testing.T) {
+	agg := New(
+		WithTimeout(2*time.Second),
+		WithLogger(newTestLogger()),
+		WithMergeStrategy(func(existing, _, _ string) string {
+			return existing
+		}),
+	)
+	agg.profile.WithDelay(0)
+	agg.order.WithDelay(0)
+
+	result, err := agg.Aggregate(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "User: Name: Alice"; result != want {
+		t.Errorf("expected %q, got %q", want, result)
+	}
+}
+
+func TestAggregate_ErrorClassifiedAsEmpty(t *testing.T) {
+	errNotFound := errors.New("no orders found")
+	var got *Result
+
+	agg := New(
+		WithTimeout(2*time.Second),
+		WithLogger(newTestLogger()),
+		WithFetcher("order", FetcherFunc(func(context.Context, int) (string, error) {
+			return "", errNotFound
+		})),
+		WithErrorClassifier(func(name string, err error) bool {
+			return name == "order" && errors.Is(err, errNotFound)
+		}),
+		WithValidator(func(r *Result) error {
+			got = r
+			return nil
+		}),
+	)
+	agg.profile.WithDelay(0)
+
+	if _, err := agg.Aggregate(context.Background(), 1); err != nil {
+		t.Fatalf("expected classified error to be non-fatal, got %v", err)
+	}
+	if orders, ok := got.Get("order"); !ok || orders != "" {
+		t.Errorf("expected empty orders, got %q (present=%v)", orders, ok)
+	}
+	if profile, _ := got.Get("profile"); profile != "Name: Alice" {
+		t.Errorf("expected profile to be unaffected, got %q", profile)
+	}
+}
+
+func TestAggregate_UnclassifiedErrorStillFails(t *testing.T) {
+	agg := New(
+		WithTimeout(2*time.Second),
+		WithLogger(newTestLogger()),
+		WithErrorClassifier(func(string, error) bool { return false }),
+	)
+	agg.profile.WithDelay(0).WithError()
+	agg.order.WithDelay(0)
+
+	if _, err := agg.Aggregate(context.Background(), 1); err == nil {
+		t.Fatal("expected unclassified error to fail the aggregation")
+	}
+}
+
+func TestAggregate_FetchParallelismLimit(t *testing.T) {
+	const numFetchers = 20
+	const limit = 4
+
+	var running, maxRunning atomic.Int32
+	track := FetcherFunc(func(ctx context.Context, id int) (string, error) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			prev := maxRunning.Load()
+			if n <= prev || maxRunning.CompareAndSwap(prev, n) {
+				break
+			}
+		}
+		select {
+		case <-time.After(20 * time.Millisecond):
+			return "ok", nil
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	})
+
+	opts := []Option{
+		WithTimeout(5 * time.Second),
+		WithLogger(newTestLogger()),
+		WithFetchParallelism(limit),
+		WithFetcher("profile", track),
+		WithFetcher("order", track),
+	}
+	for i := 0; i < numFetchers-2; i++ {
+		opts = append(opts, WithFetcher(fmt.Sprintf("extra-%d", i), track))
+	}
+
+	agg := New(opts...)
+	if _, err := agg.Aggregate(context.Background(), 1); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := maxRunning.Load(); got > limit {
+		t.Errorf("expected at most %d concurrent fetches, saw %d", limit, got)
+	}
+	if got := maxRunning.Load(); got < 2 {
+		t.Errorf("expected fetches to still run concurrently, saw max %d", got)
+	}
+}
+
+func TestAggregate_OptionalFetcherErrorInResult(t *testing.T) {
+	errRecs := errors.New("recommendations unavailable")
+	var got *Result
+
+	agg := New(
+		WithTimeout(2*time.Second),
+		WithLogger(newTestLogger()),
+		WithOptionalFetcher("recs", FetcherFunc(func(context.Context, int) (string, error) {
+			return "", errRecs
+		})),
+		WithValidator(func(r *Result) error {
+			got = r
+			return nil
+		}),
+	)
+	agg.profile.WithDelay(0)
+	agg.order.WithDelay(0)
+
+	result, err := agg.Aggregate(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("expected optional failure not to fail the aggregation, got %v", err)
+	}
+	if want := "User: Name: Alice | Orders: 5 | "; result != want {
+		t.Errorf("expected %q, got %q", want, result)
+	}
+
+	if !errors.Is(got.Errors["recs"], errRecs) {
+		t.Errorf("expected recs error in Result.Errors, got %v", got.Errors["recs"])
+	}
+	for _, name := range []string{"profile", "order"} {
+		err, ok := got.Errors[name]
+		if !ok || err != nil {
+			t.Errorf("expected nil error entry for %s, got %v (present=%v)", name, err, ok)
+		}
+	}
+}
+
+func TestAggregate_RequiredFetcherStillFails(t *testing.T) {
+	agg := New(
+		WithTimeout(2*time.Second),
+		WithLogger(newTestLogger()),
+		WithOptionalFetcher("recs", FetcherFunc(func(context.Context, int) (string, error) {
+			return "recommended", nil
+		})),
+	)
+	agg.profile.WithDelay(0).WithError()
+	agg.order.WithDelay(0)
+
+	if _, err := agg.Aggregate(context.Background(), 1); err == nil {
+		t.Fatal("expected a required fetcher failure to fail the aggregation")
+	}
+}
+
+func TestAggregate_ZeroTimeoutStaysBounded(t *testing.T) {
+	// The fetcher hangs until its context ends, so without a deadline the
+	// aggregation could never finish. It reports the deadline it was given
+	// instead of waiting out the full default.
+	deadlines := make(chan time.Time, 1)
+	hang := FetcherFunc(func(ctx context.Context, _ int) (string, error) {
+		deadline, ok := ctx.Deadline()
+		if !ok {
+			deadlines <- time.Time{}
+			<-ctx.Done()
+			return "", ctx.Err()
+		}
+		deadlines <- deadline
+		return "", errors.New("gave up after reporting deadline")
+	})
+
+	for _, d := range []time.Duration{0, -time.Second} {
+		agg := New(
+			WithTimeout(d),
+			WithLogger(newTestLogger()),
+			WithFetcher("profile", hang),
+		)
+		agg.order.WithDelay(0)
+
+		start := time.Now()
+		done := make(chan error, 1)
+		go func() {
+			_, err := agg.Aggregate(context.Background(), 1)
+			done <- err
+		}()
+
+		deadline := <-deadlines
+		if deadline.IsZero() {
+			t.Fatalf("WithTimeout(%v): fetcher ran with no deadline", d)
+		}
+		// The deadline was set between start and now
+		if deadline.Before(start) || deadline.After(time.Now().Add(defaultTimeout)) {
+			t.Errorf("WithTimeout(%v): expected a deadline within %v, got %v", d, defaultTimeout, deadline.Sub(start))
+		}
+		<-done
+	}
+}
+
+func TestAggregate_WithNoTimeoutUsesCallerDeadline(t *testing.T) {
+	var sawDeadline atomic.Bool
+	agg := New(
+		WithNoTimeout(),
+		WithLogger(newTestLogger()),
+		WithFetcher("profile", FetcherFunc(func(ctx context.Context, _ int) (string, error) {
+			_, ok := ctx.Deadline()
+			sawDeadline.Store(ok)
+			return "Name: Alice", nil
+		})),
+	)
+	agg.order.WithDelay(0)
+
+	if _, err := agg.Aggregate(context.Background(), 1); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if sawDeadline.Load() {
+		t.Error("expected no deadline under WithNoTimeout")
+	}
+
+	if _, err := agg.Aggregate(context.Background(), 2, WithCallTimeout(time.Second)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !sawDeadline.Load() {
+		t.Error("expected a per-call timeout to apply under WithNoTimeout")
+	}
+
+	// A hung fetcher must still be released at the caller's deadline
+	deadlines := make(chan time.Time, 1)
+	released := make(chan struct{})
+	hung := New(
+		WithNoTimeout(),
+		WithLogger(newTestLogger()),
+		WithFetcher("profile", FetcherFunc(func(ctx context.Context, _ int) (string, error) {
+			deadline, _ := ctx.Deadline()
+			deadlines <- deadline
+			<-ctx.Done()
+			close(released)
+			return "", ctx.Err()
+		})),
+	)
+	hung.order.WithDelay(0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	want, _ := ctx.Deadline()
+	if _, err := hung.Aggregate(ctx, 3); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the caller's deadline to end the call, got %v", err)
+	}
+	if got := <-deadlines; !got.Equal(want) {
+		t.Errorf("expected the fetcher to see the caller's deadline %v, got %v", want, got)
+	}
+	select {
+	case <-released:
+	case <-time.After(time.Second):
+		t.Fatal("fetcher still running after the caller's deadline")
+	}
+}
+
+func TestAggregate_NilFetcherSkipped(t *testing.T) {
+	var nilFunc FetcherFunc
+	agg := New(
+		WithLogger(newTestLogger()),
+		WithFetcher("extra", nil),
+		WithFetcher("another", nilFunc),
+		WithFetcher("profile", FetcherFunc(func(context.Context, int) (string, error) {
+			return "Name: Alice", nil
+		})),
+	)
+	agg.order.WithDelay(0)
+
+	result, err := agg.Aggregate(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("expected nil fetchers to be skipped, got %v", err)
+	}
+	if want := "User: Name: Alice | Orders: 5"; result != want {
+		t.Errorf("expected %q, got %q", want, result)
+	}
+	for _, nf := range agg.fetchers {
+		if nf.name == "extra" || nf.name == "another" {
+			t.Errorf("expected nil fetcher %q to be unregistered", nf.name)
+		}
+	}
+
+	typed := NewTypedAggregator(
+		WithTypedLogger[int](newTestLogger()),
+		WithTypedFetcher[int]("score", nil),
+	)
+	values, err := typed.Aggregate(context.Background(), 1)
+	if err != nil || len(values) != 0 {
+		t.Errorf("expected the nil typed fetcher to be skipped, got %v, %v", values, err)
+	}
+}
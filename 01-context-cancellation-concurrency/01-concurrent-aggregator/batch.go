@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// errResultCacheDisabled is returned by WarmCache when there is nowhere to
+// store the warmed results
+var errResultCacheDisabled = errors.New("result cache not enabled; use WithResultCache")
+
+// WithBatchParallelism caps how many ids AggregateBatch aggregates at the
+// same time. Zero or negative means no limit (the default).
+func WithBatchParallelism(n int) Option {
+	return func(a *UserAggregator) {
+		a.batchLimit = n
+	}
+}
+
+// AggregateBatch aggregates every id, at most WithBatchParallelism at a time.
+// One id failing does not stop the others: results holds every id that
+// succeeded, and the error joins the failures, each naming its user id.
+func (a *UserAggregator) AggregateBatch(ctx context.Context, ids []int) (map[int]string, error) {
+	var (
+		mu      sync.Mutex
+		results = make(map[int]string, len(ids))
+		errs    []error
+	)
+
+	// A plain errgroup, not WithContext: failures are collected, not fatal
+	var g errgroup.Group
+	if a.batchLimit > 0 {
+		g.SetLimit(a.batchLimit)
+	}
+
+	for _, id := range ids {
+		g.Go(func() error {
+			result, err := a.Aggregate(ctx, id)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("user %d: %w", id, err))
+				return nil
+			}
+			results[id] = result
+			return nil
+		})
+	}
+	g.Wait()
+
+	return results, errors.Join(errs...)
+}
+
+// WarmCache pre-populates the result cache for ids, such as known hot users,
+// by aggregating them through AggregateBatch
+func (a *UserAggregator) WarmCache(ctx context.Context, ids []int) error {
+	if a.resultTTL <= 0 {
+		return errResultCacheDisabled
+	}
+
+	results, err := a.AggregateBatch(ctx, ids)
+	a.logger.Info("result cache warmed", "requested", len(ids), "warmed", len(results))
+	if err != nil {
+		return fmt.Errorf("warm cache: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAggregateBatch_CollectsPerIDFailures(t *testing.T) {
+	errUnknown := errors.New("unknown user")
+
+	agg := New(
+		WithTimeout(2*time.Second),
+		WithLogger(newTestLogger()),
+		WithFetcher("profile", FetcherFunc(func(_ context.Context, id int) (string, error) {
+			if id == 2 {
+				return "", errUnknown
+			}
+			return "Name: Alice", nil
+		})),
+	)
+	agg.order.WithDelay(0)
+
+	results, err := agg.AggregateBatch(context.Background(), []int{1, 2, 3})
+	if !errors.Is(err, errUnknown) {
+		t.Fatalf("expected the failure for id 2, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "user 2") {
+		t.Errorf("expected the error to name the failing id, got %v", err)
+	}
+	if len(results) != 2 || results[1] == "" || results[3] == "" {
+		t.Errorf("expected results for ids 1 and 3, got %v", results)
+	}
+}
+
+func TestAggregateBatch_RespectsParallelism(t *testing.T) {
+	const limit = 2
+
+	var running, maxRunning atomic.Int32
+	track := FetcherFunc(func(ctx context.Context, id int) (string, error) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			prev := maxRunning.Load()
+			if n <= prev || maxRunning.CompareAndSwap(prev, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		return "ok", nil
+	})
+
+	agg := New(
+		WithTimeout(2*time.Second),
+		WithLogger(newTestLogger()),
+		WithFetcher("profile", track),
+		WithFetcher("order", FetcherFunc(func(context.Context, int) (string, error) {
+			return "Orders: 5", nil
+		})),
+		WithBatchParallelism(limit),
+	)
+
+	if _, err := agg.AggregateBatch(context.Background(), []int{1, 2, 3, 4, 5, 6, 7, 8}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := maxRunning.Load(); got > limit {
+		t.Errorf("expected at most %d concurrent aggregations, saw %d", limit, got)
+	}
+}
+
+func TestWarmCache_SubsequentAggregateIsCacheHit(t *testing.T) {
+	profile := &countingFetcher{result: "Name: Alice"}
+	order := &countingFetcher{result: "Orders: 5"}
+
+	agg := New(
+		WithTimeout(2*time.Second),
+		WithLogger(newTestLogger()),
+		WithFetcher("profile", profile),
+		WithFetcher("order", order),
+		WithResultCache(time.Minute),
+		WithBatchParallelism(2),
+	)
+
+	hot := []int{1, 2, 3}
+	if err := agg.WarmCache(context.Background(), hot); err != nil {
+		t.Fatalf("WarmCache failed: %v", err)
+	}
+	if got := profile.calls.Load(); got != int32(len(hot)) {
+		t.Fatalf("expected one fan-out per warmed id, got %d profile calls", got)
+	}
+
+	for _, id := range hot {
+		result, err := agg.Aggregate(context.Background(), id)
+		if err != nil {
+			t.Fatalf("id %d: unexpected error: %v", id, err)
+		}
+		if want := "User: Name: Alice | Orders: 5"; result != want {
+			t.Errorf("id %d: expected %q, got %q", id, want, result)
+		}
+	}
+
+	if got := profile.calls.Load() + order.calls.Load(); got != int32(2*len(hot)) {
+		t.Errorf("expected warmed ids to be served from the cache, fetchers ran %d times", got)
+	}
+
+	// An id that wasn't warmed still fans out
+	if _, err := agg.Aggregate(context.Background(), 99); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := profile.calls.Load(); got != int32(len(hot)+1) {
+		t.Errorf("expected a cold id to call the fetchers, got %d profile calls", got)
+	}
+}
+
+func TestWarmCache_RequiresResultCache(t *testing.T) {
+	agg := New(WithLogger(newTestLogger()))
+
+	if err := agg.WarmCache(context.Background(), []int{1}); !errors.Is(err, errResultCacheDisabled) {
+		t.Errorf("expected errResultCacheDisabled, got %v", err)
+	}
+}
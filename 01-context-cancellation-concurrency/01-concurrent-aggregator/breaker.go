@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned for a fetcher whose circuit breaker is open
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// circuitBreaker stops calling a fetcher after threshold consecutive
+// failures. Once cooldown has passed a single probe call is let through;
+// its outcome closes the breaker again or restarts the cooldown.
+type circuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	openedAt  time.Time
+	probing   bool
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{
+		threshold: max(threshold, 1),
+		cooldown:  cooldown,
+	}
+}
+
+// allow reports whether a call may go ahead, claiming the probe slot when
+// the cooldown has passed
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.wouldAllowLocked() {
+		return false
+	}
+	if b.failures >= b.threshold {
+		b.probing = true
+	}
+	return true
+}
+
+// wouldAllow reports what allow would return without changing any state
+func (b *circuitBreaker) wouldAllow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.wouldAllowLocked()
+}
+
+func (b *circuitBreaker) wouldAllowLocked() bool {
+	if b.failures < b.threshold {
+		return true
+	}
+	return !b.probing && time.Since(b.openedAt) >= b.cooldown
+}
+
+// record feeds the outcome of an allowed call back into the breaker.
+// A cancelled call says nothing about the fetcher's health, so it only
+// frees the probe slot.
+func (b *circuitBreaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	if err == nil {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= b.threshold {
+		b.openedAt = time.Now()
+	}
+}
+
+// WithCircuitBreaker short-circuits the named fetcher with ErrCircuitOpen
+// after threshold consecutive failures, for cooldown before trying again.
+// Cancellations caused by another fetcher failing do not count as failures.
+func WithCircuitBreaker(name string, threshold int, cooldown time.Duration) Option {
+	return func(a *UserAggregator) {
+		if a.breakers == nil {
+			a.breakers = make(map[string]*circuitBreaker)
+		}
+		a.breakers[name] = newCircuitBreaker(threshold, cooldown)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// failingFetcher counts calls and fails while fail is set
+type failingFetcher struct {
+	countingFetcher
+	fail bool
+}
+
+func (f *failingFetcher) Fetch(ctx context.Context, id int) (string, error) {
+	result, err := f.countingFetcher.Fetch(ctx, id)
+	if f.fail {
+		return "", errors.New("service down")
+	}
+	return result, err
+}
+
+func TestAggregate_CircuitBreakerOpensAfterThreshold(t *testing.T) {
+	order := &failingFetcher{countingFetcher: countingFetcher{result: "Orders: 5"}, fail: true}
+
+	agg := New(
+		WithTimeout(2*time.Second),
+		WithLogger(newTestLogger()),
+		WithFetcher("order", order),
+		WithCircuitBreaker("order", 3, time.Hour),
+	)
+	agg.profile.WithDelay(0)
+
+	for i := 0; i < 3; i++ {
+		if _, err := agg.Aggregate(context.Background(), i); err == nil {
+			t.Fatalf("call %d: expected fetch failure", i)
+		}
+	}
+
+	_, err := agg.Aggregate(context.Background(), 99)
+	if !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen once the threshold is reached, got %v", err)
+	}
+	if got := order.calls.Load(); got != 3 {
+		t.Errorf("expected open breaker to skip the fetcher, got %d calls", got)
+	}
+}
+
+func TestAggregate_CircuitBreakerProbesAfterCooldown(t *testing.T) {
+	order := &failingFetcher{countingFetcher: countingFetcher{result: "Orders: 5"}, fail: true}
+
+	agg := New(
+		WithTimeout(2*time.Second),
+		WithLogger(newTestLogger()),
+		WithFetcher("order", order),
+		WithCircuitBreaker("order", 1, 50*time.Millisecond),
+	)
+	agg.profile.WithDelay(0)
+
+	if _, err := agg.Aggregate(context.Background(), 1); err == nil {
+		t.Fatal("expected the first call to fail")
+	}
+	if _, err := agg.Aggregate(context.Background(), 2); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected open breaker, got %v", err)
+	}
+
+	// The service recovers; after the cooldown a probe closes the breaker
+	order.fail = false
+	time.Sleep(60 * time.Millisecond)
+
+	for id := 3; id < 5; id++ {
+		if _, err := agg.Aggregate(context.Background(), id); err != nil {
+			t.Fatalf("id %d: expected breaker to close after a successful probe, got %v", id, err)
+		}
+	}
+}
+
+func TestCircuitBreaker_CancellationIsNeutral(t *testing.T) {
+	b := newCircuitBreaker(1, time.Hour)
+
+	if !b.allow() {
+		t.Fatal("expected closed breaker to allow calls")
+	}
+	b.record(context.Canceled)
+	if !b.wouldAllow() {
+		t.Error("expected a cancelled call not to open the breaker")
+	}
+
+	b.record(errors.New("boom"))
+	if b.wouldAllow() {
+		t.Error("expected a real failure to open the breaker")
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// fetchCacheKey identifies one fetcher's result for one user
+type fetchCacheKey struct {
+	name string
+	id   int
+}
+
+type fetchCacheEntry struct {
+	value     string
+	expiresAt time.Time
+}
+
+// fetchCache stores individual fetcher results so a partial hit still saves
+// the calls for the fetchers that are cached
+type fetchCache struct {
+	mu      sync.Mutex
+	entries map[fetchCacheKey]fetchCacheEntry
+}
+
+func newFetchCache() *fetchCache {
+	return &fetchCache{
+		entries: make(map[fetchCacheKey]fetchCacheEntry),
+	}
+}
+
+// get returns the cached result for (name, id) if present and not expired
+func (c *fetchCache) get(name string, id int) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := fetchCacheKey{name: name, id: id}
+	entry, ok := c.entries[key]
+	if !ok {
+		return "", false
+	}
+	if time.Now().After(entry.expiresAt) {
+		delete(c.entries, key)
+		return "", false
+	}
+	return entry.value, true
+}
+
+// set stores a result for (name, id) that expires after ttl
+func (c *fetchCache) set(name string, id int, value string, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[fetchCacheKey{name: name, id: id}] = fetchCacheEntry{
+		value:     value,
+		expiresAt: time.Now().Add(ttl),
+	}
+}
+
+// resultCacheName keys composed results in the aggregator's result cache,
+// which is separate from the per-fetcher cache so names can't collide
+const resultCacheName = "result"
+
+// WithResultCache caches each user's composed result for ttl, so repeat
+// aggregations for the same id are answered without any fan-out
+func WithResultCache(ttl time.Duration) Option {
+	return func(a *UserAggregator) {
+		a.resultTTL = ttl
+	}
+}
+
+// ResultStore holds composed results for WithResultCache. Get reports
+// whether a live result is stored for id; Set stores one that expires
+// after ttl. Implementations must be safe for concurrent use.
+type ResultStore interface {
+	Get(ctx context.Context, id int) (string, bool, error)
+	Set(ctx context.Context, id int, value string, ttl time.Duration) error
+}
+
+// WithResultStore keeps cached results in store, e.g. one backed by Redis
+// and shared between instances, instead of in process memory. Caching is
+// still enabled and its ttl set by WithResultCache. Store errors are
+// logged and treated as misses, so an unavailable store slows aggregations
+// down but doesn't fail them.
+func WithResultStore(store ResultStore) Option {
+	return func(a *UserAggregator) {
+		a.results = store
+	}
+}
+
+// memoryResultStore is the default ResultStore
+type memoryResultStore struct {
+	cache *fetchCache
+}
+
+func newMemoryResultStore() memoryResultStore {
+	return memoryResultStore{cache: newFetchCache()}
+}
+
+func (m memoryResultStore) Get(_ context.Context, id int) (string, bool, error) {
+	value, ok := m.cache.get(resultCacheName, id)
+	return value, ok, nil
+}
+
+func (m memoryResultStore) Set(_ context.Context, id int, value string, ttl time.Duration) error {
+	m.cache.set(resultCacheName, id, value, ttl)
+	return nil
+}
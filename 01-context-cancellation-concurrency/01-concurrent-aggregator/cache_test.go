@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAggregate_PerFetcherCacheSkipsCachedFetcher(t *testing.T) {
+	profile := &countingFetcher{result: "Name: Alice"}
+	order := &countingFetcher{result: "Orders: 5"}
+
+	agg := New(
+		WithTimeout(2*time.Second),
+		WithLogger(newTestLogger()),
+		WithFetcher("profile", profile),
+		WithFetcher("order", order),
+		WithFetcherCache("profile", time.Minute),
+	)
+
+	// Warm the profile cache
+	if _, err := agg.Aggregate(context.Background(), 1); err != nil {
+		t.Fatalf("warm-up aggregate failed: %v", err)
+	}
+
+	result, err := agg.Aggregate(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "User: Name: Alice | Orders: 5"; result != want {
+		t.Errorf("expected %q, got %q", want, result)
+	}
+
+	if got := profile.calls.Load(); got != 1 {
+		t.Errorf("expected cached profile fetcher to run once, ran %d times", got)
+	}
+	if got := order.calls.Load(); got != 2 {
+		t.Errorf("expected uncached order fetcher to run twice, ran %d times", got)
+	}
+}
+
+func TestAggregate_PerFetcherCacheKeyedByID(t *testing.T) {
+	profile := &countingFetcher{result: "Name: Alice"}
+
+	agg := New(
+		WithLogger(newTestLogger()),
+		WithFetcher("profile", profile),
+		WithFetcherCache("profile", time.Minute),
+	)
+	agg.order.WithDelay(0)
+
+	for _, id := range []int{1, 2, 1, 2} {
+		if _, err := agg.Aggregate(context.Background(), id); err != nil {
+			t.Fatalf("id %d: unexpected error: %v", id, err)
+		}
+	}
+
+	if got := profile.calls.Load(); got != 2 {
+		t.Errorf("expected one profile fetch per distinct id (2), got %d", got)
+	}
+}
+
+func TestFetchCache_Expiry(t *testing.T) {
+	c := newFetchCache()
+	c.set("profile", 1, "Name: Alice", 20*time.Millisecond)
+
+	if v, ok := c.get("profile", 1); !ok || v != "Name: Alice" {
+		t.Fatalf("expected fresh entry, got %q, ok=%v", v, ok)
+	}
+
+	time.Sleep(40 * time.Millisecond)
+
+	if _, ok := c.get("profile", 1); ok {
+		t.Error("expected entry to expire after its TTL")
+	}
+}
+
+// fakeResultStore records the calls made to it
+type fakeResultStore struct {
+	mu      sync.Mutex
+	values  map[int]string
+	ttls    map[int]time.Duration
+	gets    int
+	failGet bool
+}
+
+func newFakeResultStore() *fakeResultStore {
+	return &fakeResultStore{values: make(map[int]string), ttls: make(map[int]time.Duration)}
+}
+
+func (f *fakeResultStore) Get(_ context.Context, id int) (string, bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.gets++
+	if f.failGet {
+		return "", false, errors.New("store unavailable")
+	}
+	value, ok := f.values[id]
+	return value, ok, nil
+}
+
+func (f *fakeResultStore) Set(_ context.Context, id int, value string, ttl time.Duration) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.values[id] = value
+	f.ttls[id] = ttl
+	return nil
+}
+
+func TestAggregate_ResultStoreReadsAndWrites(t *testing.T) {
+	profile := &countingFetcher{result: "Name: Alice"}
+	store := newFakeResultStore()
+	store.values[2] = "User: from the store"
+
+	agg := New(
+		WithLogger(newTestLogger()),
+		WithFetcher("profile", profile),
+		WithResultCache(time.Minute),
+		WithResultStore(store),
+	)
+	agg.order.WithDelay(0)
+
+	// A miss fans out and writes the composed result to the store
+	result, err := agg.Aggregate(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := store.values[1]; got != result {
+		t.Errorf("expected store to hold %q, got %q", result, got)
+	}
+	if got := store.ttls[1]; got != time.Minute {
+		t.Errorf("expected result stored with the cache ttl, got %v", got)
+	}
+
+	// A hit is answered from the store without fetching
+	result, err = agg.Aggregate(context.Background(), 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result != "User: from the store" {
+		t.Errorf("expected the stored result, got %q", result)
+	}
+	if got := profile.calls.Load(); got != 1 {
+		t.Errorf("expected only the miss to fetch, got %d fetches", got)
+	}
+	if store.gets != 2 {
+		t.Errorf("expected a store lookup per call, got %d", store.gets)
+	}
+}
+
+func TestAggregate_ResultStoreErrorIsAMiss(t *testing.T) {
+	profile := &countingFetcher{result: "Name: Alice"}
+	store := newFakeResultStore()
+	store.failGet = true
+
+	agg := New(
+		WithLogger(newTestLogger()),
+		WithFetcher("profile", profile),
+		WithResultCache(time.Minute),
+		WithResultStore(store),
+	)
+	agg.order.WithDelay(0)
+
+	if _, err := agg.Aggregate(context.Background(), 1); err != nil {
+		t.Fatalf("expected a failing store not to fail the aggregation, got %v", err)
+	}
+	if got := profile.calls.Load(); got != 1 {
+		t.Errorf("expected the fan-out to run, got %d fetches", got)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// callConfig holds the settings for a single Aggregate call, starting from
+// the aggregator's defaults
+type callConfig struct {
+	timeout   time.Duration
+	noTimeout bool
+	parallel  int
+	noCache   bool
+	tag       string
+}
+
+// CallOption overrides an aggregator setting for one Aggregate call only
+type CallOption func(*callConfig)
+
+// WithCallTimeout replaces the aggregator timeout for this call, even
+// under WithNoTimeout. A zero or negative d is ignored.
+func WithCallTimeout(d time.Duration) CallOption {
+	return func(c *callConfig) {
+		if d > 0 {
+			c.timeout = d
+			c.noTimeout = false
+		}
+	}
+}
+
+// WithCallParallelism replaces the fetch parallelism limit for this call.
+// Zero or negative means no limit.
+func WithCallParallelism(n int) CallOption {
+	return func(c *callConfig) {
+		c.parallel = n
+	}
+}
+
+// WithoutCache makes this call ignore cached fetcher and aggregation
+// results. Fresh results are still stored for later calls.
+func WithoutCache() CallOption {
+	return func(c *callConfig) {
+		c.noCache = true
+	}
+}
+
+// WithTag labels this call, e.g. with a tenant ID, so its summary log line
+// and Event can be segmented by caller. Calls with different tags never
+// share a fan-out.
+func WithTag(tag string) CallOption {
+	return func(c *callConfig) {
+		c.tag = tag
+	}
+}
+
+// callConfig returns the settings for one call with opts applied
+func (a *UserAggregator) callConfig(opts []CallOption) callConfig {
+	cfg := callConfig{
+		timeout:   a.timeout,
+		noTimeout: a.noTimeout,
+		parallel:  a.parallel,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+// inflightKey groups calls that can share a fan-out: only calls for the same
+// id with the same settings produce the same result
+func (c callConfig) inflightKey(id int) string {
+	return strconv.Itoa(id) + c.inflightSuffix()
+}
+
+// inflightSuffix is the id-independent part of inflightKey
+func (c callConfig) inflightSuffix() string {
+	return fmt.Sprintf("/%v/%t/%d/%t/%q", c.timeout, c.noTimeout, c.parallel, c.noCache, c.tag)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAggregate_CallTimeoutOverridesDefault(t *testing.T) {
+	slow := FetcherFunc(func(ctx context.Context, id int) (string, error) {
+		select {
+		case <-time.After(100 * time.Millisecond):
+			return "Name: Alice", nil
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	})
+
+	agg := New(
+		WithTimeout(2*time.Second),
+		WithLogger(newTestLogger()),
+		WithFetcher("profile", slow),
+	)
+	agg.order.WithDelay(0)
+
+	_, err := agg.Aggregate(context.Background(), 1, WithCallTimeout(20*time.Millisecond))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the per-call timeout to apply, got %v", err)
+	}
+
+	if _, err := agg.Aggregate(context.Background(), 1); err != nil {
+		t.Fatalf("expected the default timeout to be unchanged, got %v", err)
+	}
+	if agg.timeout != 2*time.Second {
+		t.Errorf("expected aggregator timeout 2s, got %v", agg.timeout)
+	}
+}
+
+func TestAggregate_WithoutCacheSkipsCachedResults(t *testing.T) {
+	var calls atomic.Int32
+	agg := New(
+		WithTimeout(time.Second),
+		WithLogger(newTestLogger()),
+		WithFetcher("profile", FetcherFunc(func(context.Context, int) (string, error) {
+			calls.Add(1)
+			return "Name: Alice", nil
+		})),
+		WithFetcherCache("profile", time.Minute),
+	)
+	agg.order.WithDelay(0)
+
+	for _, opts := range [][]CallOption{nil, nil, {WithoutCache()}} {
+		if _, err := agg.Aggregate(context.Background(), 1, opts...); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("expected one cached and one bypassed call, got %d fetches", got)
+	}
+}
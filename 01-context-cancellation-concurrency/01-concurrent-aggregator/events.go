@@ -0,0 +1,63 @@
+package main
+
+import "time"
+
+// Event describes one completed fan-out, for publishing to an external
+// event system via WithEventSink. Tag is the call's WithTag label, empty
+// if it had none.
+type Event struct {
+	UserID   int
+	Tag      string
+	Success  bool
+	Err      error
+	Duration time.Duration
+	// Fetchers is in fetcher registration order
+	Fetchers []FetcherEvent
+}
+
+// FetcherEvent is one fetcher's part in an Event. Status is "ok", "failed",
+// or "skipped" for a fetcher cancelled because another one failed. Err is
+// the fetcher's own error, even if it was recovered by a fallback.
+type FetcherEvent struct {
+	Name     string
+	Status   string
+	Cached   bool
+	Duration time.Duration
+	Err      error
+}
+
+// WithEventSink calls sink with an Event after every fan-out, successful or
+// not. sink runs on its own goroutine so a slow sink never delays the
+// response; it must be safe for concurrent use. Aggregations served from
+// the result cache don't fan out and produce no event.
+func WithEventSink(sink func(Event)) Option {
+	return func(a *UserAggregator) {
+		a.eventSink = sink
+	}
+}
+
+// emitEvent hands the fan-out's Event to the sink, if one is set
+func (a *UserAggregator) emitEvent(id int, tag string, start time.Time, outcomes []fetchOutcome, err error) {
+	if a.eventSink == nil {
+		return
+	}
+
+	ev := Event{
+		UserID:   id,
+		Tag:      tag,
+		Success:  err == nil,
+		Err:      err,
+		Duration: time.Since(start),
+		Fetchers: make([]FetcherEvent, len(outcomes)),
+	}
+	for i, o := range outcomes {
+		ev.Fetchers[i] = FetcherEvent{
+			Name:     o.name,
+			Status:   o.status,
+			Cached:   o.cached,
+			Duration: o.duration,
+			Err:      o.fetchErr,
+		}
+	}
+	go a.eventSink(ev)
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAggregate_EventSinkReceivesOutcome(t *testing.T) {
+	events := make(chan Event, 2)
+	errOrders := errors.New("orders down")
+
+	agg := New(
+		WithTimeout(time.Second),
+		WithLogger(newTestLogger()),
+		WithEventSink(func(ev Event) { events <- ev }),
+		WithFetcher("profile", FetcherFunc(func(context.Context, int) (string, error) {
+			return "Name: Alice", nil
+		})),
+		WithOptionalFetcher("order", FetcherFunc(func(context.Context, int) (string, error) {
+			return "", errOrders
+		})),
+	)
+
+	if _, err := agg.Aggregate(context.Background(), 7); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var ev Event
+	select {
+	case ev = <-events:
+	case <-time.After(time.Second):
+		t.Fatal("no event delivered")
+	}
+
+	if ev.UserID != 7 || !ev.Success || ev.Err != nil {
+		t.Errorf("expected a successful event for user 7, got %+v", ev)
+	}
+	if ev.Duration <= 0 {
+		t.Errorf("expected a positive duration, got %v", ev.Duration)
+	}
+	if len(ev.Fetchers) != 2 {
+		t.Fatalf("expected 2 fetcher outcomes, got %d", len(ev.Fetchers))
+	}
+	if f := ev.Fetchers[0]; f.Name != "profile" || f.Status != fetchStatusOK || f.Err != nil {
+		t.Errorf("unexpected profile outcome %+v", f)
+	}
+	if f := ev.Fetchers[1]; f.Name != "order" || f.Status != fetchStatusOK || !errors.Is(f.Err, errOrders) {
+		t.Errorf("expected the recovered order error to be reported, got %+v", f)
+	}
+}
+
+func TestAggregate_EventSinkDoesNotBlock(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	failed := make(chan Event, 1)
+
+	agg := New(
+		WithTimeout(time.Second),
+		WithLogger(newTestLogger()),
+		WithEventSink(func(ev Event) {
+			if !ev.Success {
+				failed <- ev
+			}
+			<-release
+		}),
+		WithFetcher("profile", FetcherFunc(func(context.Context, int) (string, error) {
+			return "", errors.New("profile down")
+		})),
+	)
+	agg.order.WithDelay(0)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := agg.Aggregate(context.Background(), 1)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("expected the aggregation to fail")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Aggregate blocked on the event sink")
+	}
+	select {
+	case ev := <-failed:
+		if ev.Err == nil {
+			t.Error("expected the failure event to carry the error")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no failure event delivered")
+	}
+}
+
+func TestAggregate_EventCarriesCallTag(t *testing.T) {
+	events := make(chan Event, 3)
+
+	agg := New(
+		WithTimeout(time.Second),
+		WithLogger(newTestLogger()),
+		WithEventSink(func(ev Event) { events <- ev }),
+		WithFetcher("profile", FetcherFunc(func(context.Context, int) (string, error) {
+			return "Name: Alice", nil
+		})),
+	)
+
+	// Same id each time, so only the tag tells the events apart
+	for _, tag := range []string{"tenant-a", "tenant-b", ""} {
+		var opts []CallOption
+		if tag != "" {
+			opts = append(opts, WithTag(tag))
+		}
+		if _, err := agg.Aggregate(context.Background(), 1, opts...); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		select {
+		case ev := <-events:
+			if ev.Tag != tag {
+				t.Errorf("expected tag %q, got %q", tag, ev.Tag)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("no event delivered for tag %q", tag)
+		}
+	}
+}
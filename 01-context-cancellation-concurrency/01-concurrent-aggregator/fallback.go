@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"errors"
+)
+
+// Fallback supplies a substitute result when a fetcher fails.
+// Timeouts and ordinary errors often deserve different answers (a stale
+// value is fine when a service is slow, less so when it rejected the
+// request), so each has its own handler. A nil handler means that kind of
+// failure is not recovered.
+type Fallback struct {
+	// OnError handles failures other than deadline expiry
+	OnError func(id int, err error) (string, error)
+	// OnTimeout handles errors wrapping context.DeadlineExceeded
+	OnTimeout func(id int, err error) (string, error)
+}
+
+// handlerFor returns the handler matching err, or nil if none applies
+func (fb Fallback) handlerFor(err error) func(id int, err error) (string, error) {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fb.OnTimeout
+	}
+	return fb.OnError
+}
+
+// WithFallback sets the fallback used when the named fetcher fails
+func WithFallback(name string, fb Fallback) Option {
+	return func(a *UserAggregator) {
+		if a.fallbacks == nil {
+			a.fallbacks = make(map[string]Fallback)
+		}
+		a.fallbacks[name] = fb
+	}
+}
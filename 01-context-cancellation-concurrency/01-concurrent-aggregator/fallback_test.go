@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAggregate_TimeoutFallback(t *testing.T) {
+	var usedTimeout, usedError bool
+
+	agg := New(
+		WithTimeout(50*time.Millisecond),
+		WithLogger(newTestLogger()),
+		WithFallback("profile", Fallback{
+			OnError: func(int, error) (string, error) {
+				usedError = true
+				return "Name: (error fallback)", nil
+			},
+			OnTimeout: func(int, error) (string, error) {
+				usedTimeout = true
+				return "Name: (stale)", nil
+			},
+		}),
+	)
+	agg.profile.WithDelay(time.Second)
+	agg.order.WithDelay(0)
+
+	result, err := agg.Aggregate(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("expected timeout fallback to recover, got %v", err)
+	}
+	if want := "User: Name: (stale) | Orders: 5"; result != want {
+		t.Errorf("expected %q, got %q", want, result)
+	}
+	if !usedTimeout || usedError {
+		t.Errorf("expected only the timeout fallback, got timeout=%v error=%v", usedTimeout, usedError)
+	}
+}
+
+func TestAggregate_ErrorFallback(t *testing.T) {
+	var usedTimeout, usedError bool
+
+	agg := New(
+		WithTimeout(2*time.Second),
+		WithLogger(newTestLogger()),
+		WithFallback("profile", Fallback{
+			OnError: func(int, error) (string, error) {
+				usedError = true
+				return "Name: (error fallback)", nil
+			},
+			OnTimeout: func(int, error) (string, error) {
+				usedTimeout = true
+				return "Name: (stale)", nil
+			},
+		}),
+	)
+	agg.profile.WithError()
+	agg.order.WithDelay(0)
+
+	result, err := agg.Aggregate(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("expected error fallback to recover, got %v", err)
+	}
+	if want := "User: Name: (error fallback) | Orders: 5"; result != want {
+		t.Errorf("expected %q, got %q", want, result)
+	}
+	if !usedError || usedTimeout {
+		t.Errorf("expected only the error fallback, got timeout=%v error=%v", usedTimeout, usedError)
+	}
+}
+
+func TestAggregate_TimeoutWithoutTimeoutFallbackFails(t *testing.T) {
+	agg := New(
+		WithTimeout(50*time.Millisecond),
+		WithLogger(newTestLogger()),
+		WithFallback("profile", Fallback{
+			OnError: func(int, error) (string, error) {
+				return "Name: (error fallback)", nil
+			},
+		}),
+	)
+	agg.profile.WithDelay(time.Second)
+	agg.order.WithDelay(0)
+
+	_, err := agg.Aggregate(context.Background(), 1)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline error without a timeout fallback, got %v", err)
+	}
+}
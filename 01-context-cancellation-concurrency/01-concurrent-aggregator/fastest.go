@@ -0,0 +1,122 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+)
+
+// fastestWindow is how many recent latencies a WithFastestFirst group
+// averages per member
+const fastestWindow = 20
+
+// fastestFailurePenalty is added to a failed call's latency, so a source
+// that keeps failing ranks behind the ones that work however fast it fails
+const fastestFailurePenalty = time.Second
+
+// fastestGroup names redundant sources to try one at a time
+type fastestGroup struct {
+	name    string
+	members []string
+}
+
+// WithFastestFirst makes the named fetchers fallbacks for each other, tried
+// one at a time in order of their average latency over recent calls: the
+// historically fastest source is called first, and the next one only if it
+// fails. A failure counts as its latency plus a second, so failing sources
+// sink below working ones. Members with no calls yet are tried before the
+// rest, in registration order, so every source gets measured. Unlike
+// WithFirstSuccess, only one source is called at a time.
+//
+// Like a WithFirstSuccess group, the group takes the place of its members
+// in the result, at the position of the first registered one.
+func WithFastestFirst(group string, fetchers []string) Option {
+	return func(a *UserAggregator) {
+		a.fastest = append(a.fastest, fastestGroup{name: group, members: fetchers})
+	}
+}
+
+// applyFastestGroups replaces each group's members with a single fallback
+// fetcher, once all options are applied
+func (a *UserAggregator) applyFastestGroups() {
+	for _, g := range a.fastest {
+		a.replaceWithGroup(g.name, g.members, func(members []namedFetcher) Fetcher {
+			return &fastestFetcher{
+				group:     g.name,
+				members:   members,
+				latencies: newLatencyTracker(1, fastestWindow),
+				logger:    a.logger,
+			}
+		})
+	}
+}
+
+// fastestFetcher calls its members in turn, fastest first, until one succeeds
+type fastestFetcher struct {
+	group     string
+	members   []namedFetcher
+	latencies *latencyTracker
+	logger    *slog.Logger
+}
+
+// order returns the members in the order to try them
+func (f *fastestFetcher) order() []namedFetcher {
+	type ranked struct {
+		nf       namedFetcher
+		mean     time.Duration
+		measured bool
+	}
+	ranks := make([]ranked, len(f.members))
+	for i, m := range f.members {
+		mean, ok := f.latencies.mean(m.name)
+		ranks[i] = ranked{nf: m, mean: mean, measured: ok}
+	}
+	// Stable, so unmeasured members and ties keep registration order
+	slices.SortStableFunc(ranks, func(a, b ranked) int {
+		if a.measured != b.measured {
+			if !a.measured {
+				return -1
+			}
+			return 1
+		}
+		return cmp.Compare(a.mean, b.mean)
+	})
+
+	ordered := make([]namedFetcher, len(ranks))
+	for i, r := range ranks {
+		ordered[i] = r.nf
+	}
+	return ordered
+}
+
+func (f *fastestFetcher) Fetch(ctx context.Context, id int) (string, error) {
+	var errs []error
+	for _, m := range f.order() {
+		start := time.Now()
+		result, err := f.call(ctx, m, id)
+		if err == nil {
+			f.latencies.observe(m.name, time.Since(start))
+			return result, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", m.name, err))
+		if ctx.Err() != nil {
+			// No time left for the slower sources. Not the source's fault,
+			// so it isn't penalised
+			break
+		}
+		f.latencies.observe(m.name, time.Since(start)+fastestFailurePenalty)
+		f.logger.Warn("fastest-first source failed, trying next", "group", f.group,
+			"fetcher", m.name, "error", err, "user_id", id)
+	}
+	return "", errors.Join(errs...)
+}
+
+// call fetches from one member, recovering a panic into an error
+func (f *fastestFetcher) call(ctx context.Context, m namedFetcher, id int) (_ string, err error) {
+	defer recoverFetch(f.logger, m.name, id, &err)
+	return m.fetcher.Fetch(ctx, id)
+}
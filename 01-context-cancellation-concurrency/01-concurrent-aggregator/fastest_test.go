@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAggregate_FastestFirstAdaptsToLatency(t *testing.T) {
+	var mu sync.Mutex
+	var calls []string
+	var primaryDown bool
+	source := func(name, result string) Fetcher {
+		return FetcherFunc(func(context.Context, int) (string, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			calls = append(calls, name)
+			if name == "primary" && primaryDown {
+				return "", errors.New("primary down")
+			}
+			return result, nil
+		})
+	}
+
+	agg := New(
+		WithTimeout(time.Second),
+		WithLogger(newTestLogger()),
+		WithFastestFirst("orders", []string{"order", "replica"}),
+		WithFetcher("order", source("primary", "Orders: 5 (primary)")),
+		WithFetcher("replica", source("replica", "Orders: 5 (replica)")),
+	)
+	agg.profile.WithDelay(0)
+	group := agg.fetchers[1].fetcher.(*fastestFetcher)
+
+	aggregate := func() []string {
+		t.Helper()
+		mu.Lock()
+		calls = nil
+		mu.Unlock()
+		if _, err := agg.Aggregate(context.Background(), 1, WithoutCache()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Clone(calls)
+	}
+
+	// The replica has been the faster source so far
+	for i := 0; i < fastestWindow; i++ {
+		group.latencies.observe("order", 50*time.Millisecond)
+		group.latencies.observe("replica", 5*time.Millisecond)
+	}
+	if got := aggregate(); !slices.Equal(got, []string{"replica"}) {
+		t.Errorf("expected only the faster replica to be called, got %v", got)
+	}
+
+	// The replica slows down; its average overtakes the primary's
+	for i := 0; i < fastestWindow; i++ {
+		group.latencies.observe("replica", 200*time.Millisecond)
+	}
+	if got := aggregate(); !slices.Equal(got, []string{"primary"}) {
+		t.Errorf("expected the now faster primary to be called first, got %v", got)
+	}
+
+	// A failing fastest source falls back to the next
+	mu.Lock()
+	primaryDown = true
+	mu.Unlock()
+	if got := aggregate(); !slices.Equal(got, []string{"primary", "replica"}) {
+		t.Errorf("expected a fallback from primary to replica, got %v", got)
+	}
+}
+
+func TestAggregate_FastestFirstTriesUnmeasuredFirst(t *testing.T) {
+	fast := &countingFetcher{result: "Orders: 5 (replica)"}
+	slow := &countingFetcher{delay: 20 * time.Millisecond, result: "Orders: 5 (primary)"}
+
+	agg := New(
+		WithTimeout(time.Second),
+		WithLogger(newTestLogger()),
+		WithFastestFirst("orders", []string{"order", "replica"}),
+		WithFetcher("order", slow),
+		WithFetcher("replica", fast),
+	)
+	agg.profile.WithDelay(0)
+
+	// Registration order first, then the replica once only it is unmeasured,
+	// then whichever measured faster
+	for i := 0; i < 4; i++ {
+		if _, err := agg.Aggregate(context.Background(), i); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if got := slow.calls.Load(); got != 1 {
+		t.Errorf("expected the slower source to be called once, to measure it, got %d", got)
+	}
+	if got := fast.calls.Load(); got != 3 {
+		t.Errorf("expected the faster source to serve the other calls, got %d", got)
+	}
+}
+
+func TestAggregate_FastestFirstDemotesFailingSource(t *testing.T) {
+	var failing atomic.Int32
+	good := &countingFetcher{delay: 5 * time.Millisecond, result: "Orders: 5 (replica)"}
+
+	agg := New(
+		WithTimeout(time.Second),
+		WithLogger(newTestLogger()),
+		WithFastestFirst("orders", []string{"order", "replica"}),
+		WithFetcher("order", FetcherFunc(func(context.Context, int) (string, error) {
+			failing.Add(1)
+			return "", errors.New("primary down")
+		})),
+		WithFetcher("replica", good),
+	)
+	agg.profile.WithDelay(0)
+
+	// The failing source fails fast, but once measured must not be tried
+	// ahead of the one that works
+	for i := 0; i < 5; i++ {
+		if _, err := agg.Aggregate(context.Background(), i); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if got := failing.Load(); got != 1 {
+		t.Errorf("expected the failing source to be tried once, got %d", got)
+	}
+	if got := good.calls.Load(); got != 5 {
+		t.Errorf("expected the working source to serve every call, got %d", got)
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import "context"
+
+// Fetcher retrieves one piece of user data from a downstream service
+type Fetcher interface {
+	Fetch(ctx context.Context, id int) (string, error)
+}
+
+// FetcherFunc adapts an ordinary function to the Fetcher interface
+type FetcherFunc func(ctx context.Context, id int) (string, error)
+
+// Fetch calls f(ctx, id)
+func (f FetcherFunc) Fetch(ctx context.Context, id int) (string, error) {
+	return f(ctx, id)
+}
+
+// isNilFetcher reports whether f would panic when called: a nil interface
+// or a nil FetcherFunc
+func isNilFetcher(f Fetcher) bool {
+	fn, isFunc := f.(FetcherFunc)
+	return f == nil || isFunc && fn == nil
+}
+
+// namedFetcher pairs a fetcher with the name used in results, logs and errors.
+// An optional fetcher's failure leaves its field empty instead of failing
+// the aggregation.
+type namedFetcher struct {
+	name     string
+	fetcher  Fetcher
+	optional bool
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+)
+
+// firstSuccessGroup names fetchers that return the same data from
+// redundant sources
+type firstSuccessGroup struct {
+	name    string
+	members []string
+}
+
+// WithFirstSuccess races the named fetchers against each other: the first
+// to succeed provides the value and the rest are cancelled. Unlike hedging,
+// which duplicates one fetcher, the members are different sources.
+//
+// The group takes the place of its members in the result, under the group
+// name and at the position of its first registered member; per-fetcher
+// options such as caching or fallbacks apply to the group name.
+func WithFirstSuccess(group string, fetchers []string) Option {
+	return func(a *UserAggregator) {
+		a.raceGroups = append(a.raceGroups, firstSuccessGroup{name: group, members: fetchers})
+	}
+}
+
+// applyFirstSuccessGroups replaces each group's members with a single racing
+// fetcher. It runs once all options are applied, so members may be
+// registered before or after the group is declared.
+func (a *UserAggregator) applyFirstSuccessGroups() {
+	for _, g := range a.raceGroups {
+		a.replaceWithGroup(g.name, g.members, func(members []namedFetcher) Fetcher {
+			return &raceFetcher{group: g.name, members: members, logger: a.logger}
+		})
+	}
+}
+
+// replaceWithGroup swaps the registered fetchers named in members for a
+// single fetcher registered as name, built from them by newGroup. The group
+// takes the position of its first registered member.
+func (a *UserAggregator) replaceWithGroup(name string, members []string, newGroup func([]namedFetcher) Fetcher) {
+	inGroup := make(map[string]bool, len(members))
+	for _, m := range members {
+		inGroup[m] = true
+	}
+
+	var found []namedFetcher
+	slot := -1
+	kept := a.fetchers[:0]
+	for _, nf := range a.fetchers {
+		if !inGroup[nf.name] {
+			kept = append(kept, nf)
+			continue
+		}
+		if slot < 0 {
+			// Reserve the first member's slot for the group
+			slot = len(kept)
+			kept = append(kept, namedFetcher{name: name})
+		}
+		found = append(found, nf)
+	}
+	a.fetchers = kept
+
+	if slot < 0 {
+		a.logger.Warn("fetcher group has no registered fetchers", "group", name, "members", members)
+		return
+	}
+	a.fetchers[slot].fetcher = newGroup(found)
+}
+
+// raceFetcher calls every member at once and returns the first success
+type raceFetcher struct {
+	group   string
+	members []namedFetcher
+	logger  *slog.Logger
+}
+
+type raceResult struct {
+	name   string
+	result string
+	err    error
+}
+
+func (f *raceFetcher) Fetch(ctx context.Context, id int) (string, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel() // cancels the losers once a winner returns
+
+	// Buffered so losers finishing after the winner don't block
+	results := make(chan raceResult, len(f.members))
+	for _, m := range f.members {
+		go func() {
+			res := raceResult{name: m.name}
+			defer func() { results <- res }()
+			defer recoverFetch(f.logger, m.name, id, &res.err)
+			res.result, res.err = m.fetcher.Fetch(ctx, id)
+		}()
+	}
+
+	var errs []error
+	for range f.members {
+		res := <-results
+		if res.err == nil {
+			f.logger.Info("first-success group won", "group", f.group, "fetcher", res.name, "user_id", id)
+			return res.result, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", res.name, res.err))
+	}
+	return "", errors.Join(errs...)
+}
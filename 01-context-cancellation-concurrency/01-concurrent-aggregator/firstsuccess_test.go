@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAggregate_FirstSuccessCancelsSlower(t *testing.T) {
+	slowCancelled := make(chan error, 1)
+
+	fast := &countingFetcher{delay: 10 * time.Millisecond, result: "Orders: 5 (replica)"}
+	slow := FetcherFunc(func(ctx context.Context, id int) (string, error) {
+		select {
+		case <-time.After(time.Second):
+			slowCancelled <- nil
+			return "Orders: 5 (primary)", nil
+		case <-ctx.Done():
+			slowCancelled <- ctx.Err()
+			return "", ctx.Err()
+		}
+	})
+
+	var got *Result
+	agg := New(
+		WithTimeout(2*time.Second),
+		WithLogger(newTestLogger()),
+		// The primary replaces the default order fetcher, so the group
+		// takes its slot after the profile
+		WithFirstSuccess("orders", []string{"order", "order-replica"}),
+		WithFetcher("order", slow),
+		WithFetcher("order-replica", fast),
+		WithValidator(func(r *Result) error {
+			got = r
+			return nil
+		}),
+	)
+	agg.profile.WithDelay(0)
+
+	start := time.Now()
+	result, err := agg.Aggregate(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 200*time.Millisecond {
+		t.Errorf("expected the faster source to win, took %v", elapsed)
+	}
+	if want := "User: Name: Alice | Orders: 5 (replica)"; result != want {
+		t.Errorf("expected %q, got %q", want, result)
+	}
+	if len(got.Fields) != 2 || got.Fields[1].Name != "orders" {
+		t.Errorf("expected the group to replace its members with one field, got %+v", got.Fields)
+	}
+
+	select {
+	case err := <-slowCancelled:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected slower fetcher to be cancelled, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Error("slower fetcher was not cancelled")
+	}
+}
+
+func TestAggregate_FirstSuccessAllFail(t *testing.T) {
+	errPrimary := errors.New("primary down")
+	errReplica := errors.New("replica down")
+
+	agg := New(
+		WithTimeout(2*time.Second),
+		WithLogger(newTestLogger()),
+		WithFetcher("order", FetcherFunc(func(context.Context, int) (string, error) {
+			return "", errPrimary
+		})),
+		WithFetcher("order-replica", FetcherFunc(func(context.Context, int) (string, error) {
+			return "", errReplica
+		})),
+		WithFirstSuccess("orders", []string{"order", "order-replica"}),
+	)
+	agg.profile.WithDelay(0)
+
+	_, err := agg.Aggregate(context.Background(), 1)
+	if !errors.Is(err, errPrimary) || !errors.Is(err, errReplica) {
+		t.Errorf("expected every member's error when none succeed, got %v", err)
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// WithGraceExtension lets an aggregation that times out while making
+// progress run on for d: if, when the timeout fires, at least one fetcher
+// has succeeded and another is still running, the deadline is extended
+// once by d instead of failing the aggregation. The extension is visible to
+// fetchers through ctx.Deadline.
+func WithGraceExtension(d time.Duration) Option {
+	return func(a *UserAggregator) {
+		a.grace = d
+	}
+}
+
+// graceContext is a context whose deadline can be extended once it has been
+// reached. It has its own done channel rather than deriving from a
+// cancelCtx, so contexts derived from it report its Err, DeadlineExceeded,
+// instead of Canceled.
+type graceContext struct {
+	context.Context
+
+	done     chan struct{}
+	grace    time.Duration
+	extend   func() bool
+	mu       sync.Mutex
+	timer    *time.Timer
+	deadline time.Time
+	extended bool
+	err      error
+}
+
+// withGrace returns a context that expires after timeout, unless extend
+// returns true at that moment, in which case it expires grace later. The
+// cancel func must be called to release its resources.
+func withGrace(parent context.Context, timeout, grace time.Duration, extend func() bool) (context.Context, context.CancelFunc) {
+	c := &graceContext{
+		Context:  parent,
+		done:     make(chan struct{}),
+		grace:    grace,
+		extend:   extend,
+		deadline: time.Now().Add(timeout),
+	}
+
+	// Held so the timer can't fire before c.timer is set
+	c.mu.Lock()
+	c.timer = time.AfterFunc(timeout, c.onDeadline)
+	c.mu.Unlock()
+	stopParent := context.AfterFunc(parent, func() { c.expire(parent.Err()) })
+
+	return c, func() {
+		c.mu.Lock()
+		c.timer.Stop()
+		c.mu.Unlock()
+		stopParent()
+		c.expire(context.Canceled)
+	}
+}
+
+// onDeadline grants the one extension if extend allows it, and otherwise
+// expires the context
+func (c *graceContext) onDeadline() {
+	c.mu.Lock()
+	if c.err == nil && !c.extended && c.extend() {
+		c.extended = true
+		c.deadline = c.deadline.Add(c.grace)
+		c.timer.Reset(c.grace)
+		c.mu.Unlock()
+		return
+	}
+	c.mu.Unlock()
+	c.expire(context.DeadlineExceeded)
+}
+
+// expire ends the context with err, unless it has already ended
+func (c *graceContext) expire(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err == nil {
+		c.err = err
+		close(c.done)
+	}
+}
+
+func (c *graceContext) Done() <-chan struct{} {
+	return c.done
+}
+
+func (c *graceContext) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// Deadline reports the current deadline, or the parent's if it is earlier
+func (c *graceContext) Deadline() (time.Time, bool) {
+	c.mu.Lock()
+	deadline := c.deadline
+	c.mu.Unlock()
+	if parent, ok := c.Context.Deadline(); ok && parent.Before(deadline) {
+		return parent, true
+	}
+	return deadline, true
+}
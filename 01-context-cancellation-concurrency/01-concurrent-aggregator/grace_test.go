@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// lateFetcher succeeds after delay unless ctx ends first
+func lateFetcher(delay time.Duration, result string) Fetcher {
+	return FetcherFunc(func(ctx context.Context, _ int) (string, error) {
+		select {
+		case <-time.After(delay):
+			return result, nil
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	})
+}
+
+func TestAggregate_GraceExtensionLetsLateFetcherFinish(t *testing.T) {
+	const timeout = 200 * time.Millisecond
+	newAgg := func(opts ...Option) *UserAggregator {
+		agg := New(append([]Option{
+			WithTimeout(timeout),
+			WithLogger(newTestLogger()),
+			WithFetcher("order", lateFetcher(timeout+50*time.Millisecond, "Orders: 5")),
+		}, opts...)...)
+		agg.profile.WithDelay(0)
+		return agg
+	}
+
+	// Without the extension the slightly late fetcher misses the deadline
+	if _, err := newAgg().Aggregate(context.Background(), 1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected a deadline error without the extension, got %v", err)
+	}
+
+	result, err := newAgg(WithGraceExtension(200*time.Millisecond)).Aggregate(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("expected the extension to let the late fetcher finish, got %v", err)
+	}
+	if want := "User: Name: Alice | Orders: 5"; result != want {
+		t.Errorf("expected %q, got %q", want, result)
+	}
+}
+
+func TestAggregate_GraceExtensionNeedsProgress(t *testing.T) {
+	const timeout = 100 * time.Millisecond
+	agg := New(
+		WithTimeout(timeout),
+		WithLogger(newTestLogger()),
+		WithGraceExtension(time.Second),
+		WithFetcher("profile", lateFetcher(timeout+50*time.Millisecond, "Name: Alice")),
+		WithFetcher("order", lateFetcher(timeout+50*time.Millisecond, "Orders: 5")),
+	)
+
+	start := time.Now()
+	_, err := agg.Aggregate(context.Background(), 1)
+	elapsed := time.Since(start)
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected a deadline error when nothing has succeeded, got %v", err)
+	}
+	if elapsed > timeout+100*time.Millisecond {
+		t.Errorf("expected no extension without progress, took %v", elapsed)
+	}
+}
+
+func TestGraceContext_ExtendsDeadlineOnce(t *testing.T) {
+	ctx, cancel := withGrace(context.Background(), 20*time.Millisecond, 30*time.Millisecond, func() bool { return true })
+	defer cancel()
+
+	initial, _ := ctx.Deadline()
+	start := time.Now()
+	<-ctx.Done()
+
+	if elapsed := time.Since(start); elapsed < 45*time.Millisecond {
+		t.Errorf("expected one 30ms extension after the 20ms timeout, expired after %v", elapsed)
+	}
+	if final, _ := ctx.Deadline(); final.Sub(initial) != 30*time.Millisecond {
+		t.Errorf("expected the deadline to move by 30ms, moved by %v", final.Sub(initial))
+	}
+	if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		t.Errorf("expected DeadlineExceeded, got %v", ctx.Err())
+	}
+}
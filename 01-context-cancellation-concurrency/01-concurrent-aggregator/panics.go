@@ -0,0 +1,29 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+)
+
+// ErrFetcherPanic is returned, wrapped with the panic value, for a fetch
+// whose fetcher panicked
+var ErrFetcherPanic = errors.New("fetcher panicked")
+
+// recoverFetch turns a panic in the named fetcher into an ErrFetcherPanic
+// stored in *err, logging the stack, so one misbehaving fetcher fails its
+// own fetch instead of crashing the process. It must be deferred directly.
+func recoverFetch(logger *slog.Logger, name string, id int, err *error) {
+	if r := recover(); r != nil {
+		logger.Error("fetcher panicked", "fetcher", name, "panic", r, "stack", string(debug.Stack()), "user_id", id)
+		*err = fmt.Errorf("%w: %v", ErrFetcherPanic, r)
+	}
+}
+
+// safeFetch calls the fetcher, recovering a panic as an error
+func (a *UserAggregator) safeFetch(ctx context.Context, nf namedFetcher, id int) (_ string, err error) {
+	defer recoverFetch(a.logger, nf.name, id, &err)
+	return nf.fetcher.Fetch(ctx, id)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestAggregate_FetcherPanicBecomesError(t *testing.T) {
+	agg := New(
+		WithLogger(newTestLogger()),
+		WithFetcher("profile", FetcherFunc(func(context.Context, int) (string, error) {
+			var m map[string]int
+			m["boom"]++ // nil map write
+			return "", nil
+		})),
+	)
+	agg.order.WithDelay(0)
+
+	_, err := agg.Aggregate(context.Background(), 1)
+	if !errors.Is(err, ErrFetcherPanic) {
+		t.Fatalf("expected ErrFetcherPanic, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "profile") {
+		t.Errorf("expected the error to name the fetcher, got %v", err)
+	}
+}
+
+func TestAggregate_FetcherPanicInGroupMember(t *testing.T) {
+	agg := New(
+		WithLogger(newTestLogger()),
+		WithFetcher("order", FetcherFunc(func(context.Context, int) (string, error) {
+			panic("primary exploded")
+		})),
+		WithFetcher("order-replica", FetcherFunc(func(context.Context, int) (string, error) {
+			return "Orders: 5", nil
+		})),
+		WithFirstSuccess("orders", []string{"order", "order-replica"}),
+	)
+	agg.profile.WithDelay(0)
+
+	result, err := agg.Aggregate(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("expected the replica to win despite the panic, got %v", err)
+	}
+	if !strings.Contains(result, "Orders: 5") {
+		t.Errorf("expected the replica's result, got %q", result)
+	}
+}
+
+func TestTypedAggregate_FetcherPanicBecomesError(t *testing.T) {
+	agg := NewTypedAggregator(
+		WithTypedLogger[int](newTestLogger()),
+		WithTypedFetcher[int]("score", TypedFetcherFunc[int](func(context.Context, int) (int, error) {
+			panic("bad score")
+		})),
+	)
+
+	_, err := agg.Aggregate(context.Background(), 1)
+	if !errors.Is(err, ErrFetcherPanic) || !strings.Contains(err.Error(), "score") {
+		t.Errorf("expected ErrFetcherPanic naming score, got %v", err)
+	}
+}
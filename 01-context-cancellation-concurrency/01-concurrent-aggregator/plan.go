@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"strconv"
+)
+
+// FetchAction is what an aggregation would do with one fetcher
+type FetchAction string
+
+const (
+	// FetchCall means the fetcher would be called
+	FetchCall FetchAction = "call"
+	// FetchCached means the fetcher would be skipped in favour of a cache hit
+	FetchCached FetchAction = "cached"
+	// FetchShortCircuited means the fetcher's circuit breaker is open
+	FetchShortCircuited FetchAction = "short-circuited"
+)
+
+// FetchPlanEntry describes how one fetcher would be handled
+type FetchPlanEntry struct {
+	Name   string
+	Action FetchAction
+}
+
+// Plan reports, in registration order, what Aggregate would do with each
+// fetcher for id given the current cache and circuit-breaker state. No
+// fetcher is called and no state is changed, so the plan may be stale by
+// the time Aggregate runs.
+func (a *UserAggregator) Plan(ctx context.Context, id int) []FetchPlanEntry {
+	plan := make([]FetchPlanEntry, len(a.fetchers))
+	for i, nf := range a.fetchers {
+		plan[i] = FetchPlanEntry{Name: nf.name, Action: a.planFetcher(nf.name, id)}
+	}
+	return plan
+}
+
+// planFetcher mirrors the checks made by runFetcher, in the same order
+func (a *UserAggregator) planFetcher(name string, id int) FetchAction {
+	if _, cacheable := a.cacheTTLs[name]; cacheable {
+		if _, hit := a.cache.get(name, id); hit {
+			return FetchCached
+		}
+	}
+	if b := a.breakers[name]; b != nil && !b.wouldAllow() {
+		return FetchShortCircuited
+	}
+	return FetchCall
+}
+
+// Plan is an aggregation compiled once and executed repeatedly for different
+// ids: the options are applied, fetcher groups built and the per-call
+// settings resolved up front, so each Execute skips that setup. Unlike the
+// Plan method, which only reports what an aggregation would do, a Plan runs
+// the fan-out. It is safe for concurrent use.
+type Plan struct {
+	agg    *UserAggregator
+	cfg    callConfig
+	suffix string
+}
+
+// NewPlan compiles an aggregation configured by opts, as New would
+func NewPlan(opts ...Option) *Plan {
+	agg := New(opts...)
+	cfg := agg.callConfig(nil)
+	return &Plan{agg: agg, cfg: cfg, suffix: cfg.inflightSuffix()}
+}
+
+// Execute aggregates id, behaving exactly like Aggregate with no CallOptions
+func (p *Plan) Execute(ctx context.Context, id int) (string, error) {
+	return p.agg.execute(ctx, id, p.cfg, strconv.Itoa(id)+p.suffix)
+}
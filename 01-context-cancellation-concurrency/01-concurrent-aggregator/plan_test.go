@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestPlan_ReflectsCacheAndBreakerState(t *testing.T) {
+	profile := &countingFetcher{result: "Name: Alice"}
+	order := &failingFetcher{countingFetcher: countingFetcher{result: "Orders: 5"}}
+	extra := &countingFetcher{result: "extra"}
+
+	agg := New(
+		WithTimeout(2*time.Second),
+		WithLogger(newTestLogger()),
+		WithFetcher("profile", profile),
+		WithFetcher("order", order),
+		WithFetcher("extra", extra),
+		WithFetcherCache("profile", time.Minute),
+		WithCircuitBreaker("order", 1, time.Hour),
+	)
+
+	// Cache the profile, then open the order breaker
+	if _, err := agg.Aggregate(context.Background(), 1); err != nil {
+		t.Fatalf("warm-up aggregate failed: %v", err)
+	}
+	order.fail = true
+	if _, err := agg.Aggregate(context.Background(), 1); err == nil {
+		t.Fatal("expected failing order fetcher to fail the aggregation")
+	}
+	calls := profile.calls.Load() + order.calls.Load() + extra.calls.Load()
+
+	plan := agg.Plan(context.Background(), 1)
+
+	want := []FetchPlanEntry{
+		{Name: "profile", Action: FetchCached},
+		{Name: "order", Action: FetchShortCircuited},
+		{Name: "extra", Action: FetchCall},
+	}
+	if len(plan) != len(want) {
+		t.Fatalf("expected %d plan entries, got %+v", len(want), plan)
+	}
+	for i := range want {
+		if plan[i] != want[i] {
+			t.Errorf("entry %d: expected %+v, got %+v", i, want[i], plan[i])
+		}
+	}
+
+	if got := profile.calls.Load() + order.calls.Load() + extra.calls.Load(); got != calls {
+		t.Errorf("expected Plan not to call any fetcher, calls went from %d to %d", calls, got)
+	}
+
+	// Planning leaves the breaker as it was
+	if _, err := agg.Aggregate(context.Background(), 1); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("expected aggregation to match the plan, got %v", err)
+	}
+}
+
+func TestPlan_ExecuteMatchesAggregate(t *testing.T) {
+	opts := []Option{
+		WithLogger(newTestLogger()),
+		WithFetcher("profile", FetcherFunc(func(_ context.Context, id int) (string, error) {
+			return fmt.Sprintf("Name: user-%d", id), nil
+		})),
+		WithFetcher("order", FetcherFunc(func(context.Context, int) (string, error) {
+			return "Orders: 5", nil
+		})),
+	}
+	agg := New(opts...)
+	plan := NewPlan(opts...)
+
+	for _, id := range []int{1, 2, 3} {
+		want, err := agg.Aggregate(context.Background(), id)
+		if err != nil {
+			t.Fatalf("id %d: Aggregate failed: %v", id, err)
+		}
+		got, err := plan.Execute(context.Background(), id)
+		if err != nil {
+			t.Fatalf("id %d: Execute failed: %v", id, err)
+		}
+		if got != want {
+			t.Errorf("id %d: expected %q, got %q", id, want, got)
+		}
+	}
+}
+
+// benchmarkOptions sets up immediate fetchers and a quiet logger, so the
+// benchmarks measure per-call overhead rather than fetching or logging
+func benchmarkOptions() []Option {
+	immediate := FetcherFunc(func(context.Context, int) (string, error) { return "ok", nil })
+	return []Option{
+		WithLogger(slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError}))),
+		WithFetcher("profile", immediate),
+		WithFetcher("order", immediate),
+	}
+}
+
+func BenchmarkAggregate(b *testing.B) {
+	agg := New(benchmarkOptions()...)
+	ctx := context.Background()
+	b.ReportAllocs()
+	for i := 0; b.Loop(); i++ {
+		if _, err := agg.Aggregate(ctx, i); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPlanExecute(b *testing.B) {
+	plan := NewPlan(benchmarkOptions()...)
+	ctx := context.Background()
+	b.ReportAllocs()
+	for i := 0; b.Loop(); i++ {
+		if _, err := plan.Execute(ctx, i); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// retryPolicy re-runs a failed fetch up to attempts times in total, waiting
+// backoff between attempts. timeouts, when set, bounds each attempt.
+type retryPolicy struct {
+	attempts int
+	backoff  time.Duration
+	timeouts []time.Duration
+}
+
+// timeout returns the timeout for the given attempt, counted from 1, or 0
+// for none. Attempts past the end of timeouts reuse its last entry.
+func (p retryPolicy) timeout(attempt int) time.Duration {
+	if len(p.timeouts) == 0 {
+		return 0
+	}
+	return p.timeouts[min(attempt, len(p.timeouts))-1]
+}
+
+// WithRetry retries the named fetcher's failed fetches, making up to
+// attempts calls in total with backoff between them.
+//
+// Retries share the aggregation's deadline rather than extending it: a
+// retry is only started if the time left covers the backoff plus as long
+// again as the previous attempt took (or the next attempt's timeout, if
+// shorter), so the retries as a whole never outlast the aggregation timeout.
+func WithRetry(name string, attempts int, backoff time.Duration) Option {
+	return func(a *UserAggregator) {
+		if a.retries == nil {
+			a.retries = make(map[string]retryPolicy)
+		}
+		policy := a.retries[name]
+		policy.attempts, policy.backoff = max(attempts, 1), backoff
+		a.retries[name] = policy
+	}
+}
+
+// WithRetryTimeouts bounds each of the named fetcher's attempts: the first
+// attempt gets timeouts[0], the second timeouts[1], and so on, with the last
+// timeout reused for any further attempts. For example a generous first
+// attempt followed by quick retries is
+//
+//	WithRetryTimeouts("profile", 2*time.Second, 300*time.Millisecond)
+//
+// A zero timeout leaves that attempt bounded only by the aggregation
+// deadline. Combine with WithRetry to set the number of attempts.
+func WithRetryTimeouts(name string, timeouts ...time.Duration) Option {
+	return func(a *UserAggregator) {
+		if a.retries == nil {
+			a.retries = make(map[string]retryPolicy)
+		}
+		policy := a.retries[name]
+		policy.timeouts = timeouts
+		a.retries[name] = policy
+	}
+}
+
+// attempt makes one of a retried fetcher's calls, under its attempt timeout
+func (a *UserAggregator) attempt(ctx context.Context, nf namedFetcher, id int, timeout time.Duration) (string, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return a.limitedFetch(ctx, nf, id)
+}
+
+// retryingFetch calls the fetcher, retrying failures under its retry policy
+func (a *UserAggregator) retryingFetch(ctx context.Context, nf namedFetcher, id int) (string, error) {
+	policy, ok := a.retries[nf.name]
+	if !ok {
+		return a.limitedFetch(ctx, nf, id)
+	}
+
+	var result string
+	var err error
+	for attempt := 1; ; attempt++ {
+		start := time.Now()
+		result, err = a.attempt(ctx, nf, id, policy.timeout(attempt))
+		if err == nil || attempt >= policy.attempts || ctx.Err() != nil {
+			return result, err
+		}
+
+		// Abandon rather than start an attempt the deadline would cut short
+		if deadline, ok := ctx.Deadline(); ok {
+			expected := time.Since(start)
+			if next := policy.timeout(attempt + 1); next > 0 {
+				expected = min(expected, next)
+			}
+			needed := policy.backoff + expected
+			if remaining := time.Until(deadline); remaining < needed {
+				a.logger.Warn("retry abandoned, budget exhausted", "fetcher", nf.name,
+					"attempt", attempt, "remaining", remaining, "needed", needed, "error", err, "user_id", id)
+				return result, err
+			}
+		}
+
+		a.logger.Info("retrying fetch", "fetcher", nf.name, "attempt", attempt, "error", err, "user_id", id)
+		select {
+		case <-time.After(policy.backoff):
+		case <-ctx.Done():
+			return result, err
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAggregate_RetriesStayWithinBudget(t *testing.T) {
+	const budget = 500 * time.Millisecond
+	var calls atomic.Int32
+	// Ignores ctx, so only the retry budget can stop a late attempt
+	// from running past the deadline
+	slowFailure := FetcherFunc(func(context.Context, int) (string, error) {
+		calls.Add(1)
+		time.Sleep(150 * time.Millisecond)
+		return "", errors.New("profile unavailable")
+	})
+
+	agg := New(
+		WithTimeout(budget),
+		WithLogger(newTestLogger()),
+		WithFetcher("profile", slowFailure),
+		WithRetry("profile", 100, 10*time.Millisecond),
+	)
+	agg.order.WithDelay(0)
+
+	start := time.Now()
+	_, err := agg.Aggregate(context.Background(), 1)
+	elapsed := time.Since(start)
+
+	if err == nil {
+		t.Fatal("expected the aggregation to fail")
+	}
+	if elapsed > budget {
+		t.Errorf("expected retries to stay within %v, took %v", budget, elapsed)
+	}
+	if n := calls.Load(); n < 2 {
+		t.Errorf("expected at least one retry, got %d calls", n)
+	}
+}
+
+func TestAggregate_RetrySucceedsAfterFailures(t *testing.T) {
+	var calls atomic.Int32
+	flaky := FetcherFunc(func(context.Context, int) (string, error) {
+		if calls.Add(1) < 3 {
+			return "", errors.New("transient")
+		}
+		return "Name: Alice", nil
+	})
+
+	agg := New(
+		WithTimeout(time.Second),
+		WithLogger(newTestLogger()),
+		WithFetcher("profile", flaky),
+		WithRetry("profile", 3, time.Millisecond),
+	)
+	agg.order.WithDelay(0)
+
+	if _, err := agg.Aggregate(context.Background(), 1); err != nil {
+		t.Fatalf("expected the third attempt to succeed, got %v", err)
+	}
+	if n := calls.Load(); n != 3 {
+		t.Errorf("expected 3 calls, got %d", n)
+	}
+}
+
+func TestAggregate_RetryTimeoutsPerAttempt(t *testing.T) {
+	var mu sync.Mutex
+	var allowed []time.Duration
+	failing := FetcherFunc(func(ctx context.Context, _ int) (string, error) {
+		deadline, _ := ctx.Deadline()
+		mu.Lock()
+		allowed = append(allowed, time.Until(deadline))
+		mu.Unlock()
+		return "", errors.New("profile unavailable")
+	})
+
+	agg := New(
+		WithTimeout(5*time.Second),
+		WithLogger(newTestLogger()),
+		WithFetcher("profile", failing),
+		WithRetry("profile", 3, time.Millisecond),
+		WithRetryTimeouts("profile", time.Second, 100*time.Millisecond),
+	)
+	agg.order.WithDelay(0)
+
+	if _, err := agg.Aggregate(context.Background(), 1); err == nil {
+		t.Fatal("expected the aggregation to fail")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(allowed) != 3 {
+		t.Fatalf("expected 3 attempts, got %d", len(allowed))
+	}
+	if allowed[0] <= 500*time.Millisecond || allowed[0] > time.Second {
+		t.Errorf("expected the first attempt to get the 1s timeout, it had %v", allowed[0])
+	}
+	for i, d := range allowed[1:] {
+		if d > 100*time.Millisecond {
+			t.Errorf("expected retry %d to get the 100ms timeout, it had %v", i+1, d)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+)
+
+// ScoredFetcher is a Fetcher whose results carry a confidence score, for
+// choosing between fetchers that provide the same field
+type ScoredFetcher interface {
+	Fetcher
+	FetchScored(ctx context.Context, id int) (value string, score float64, err error)
+}
+
+// ScoredFetcherFunc adapts an ordinary function to the ScoredFetcher interface
+type ScoredFetcherFunc func(ctx context.Context, id int) (string, float64, error)
+
+// FetchScored calls f(ctx, id)
+func (f ScoredFetcherFunc) FetchScored(ctx context.Context, id int) (string, float64, error) {
+	return f(ctx, id)
+}
+
+// Fetch calls f(ctx, id) and drops the score
+func (f ScoredFetcherFunc) Fetch(ctx context.Context, id int) (string, error) {
+	value, _, err := f(ctx, id)
+	return value, err
+}
+
+// scoreGroup names fetchers that are candidates for the same field
+type scoreGroup struct {
+	field   string
+	members []string
+}
+
+// WithHighestScore makes the named fetchers candidates for one field: all
+// of them are called and the successful result with the highest score
+// provides the value. Fetchers that aren't ScoredFetchers score 0, and ties
+// go to the earlier registered fetcher. The field only fails if every
+// candidate does.
+//
+// Like a WithFirstSuccess group, the field takes the place of its
+// candidates in the result, at the position of the first registered one.
+func WithHighestScore(field string, fetchers []string) Option {
+	return func(a *UserAggregator) {
+		a.scoring = append(a.scoring, scoreGroup{field: field, members: fetchers})
+	}
+}
+
+// applyScoreGroups replaces each group's candidates with a single selecting
+// fetcher, once all options are applied
+func (a *UserAggregator) applyScoreGroups() {
+	for _, g := range a.scoring {
+		a.replaceWithGroup(g.field, g.members, func(members []namedFetcher) Fetcher {
+			return &scoreFetcher{field: g.field, members: members, logger: a.logger}
+		})
+	}
+}
+
+// scoreFetcher calls every candidate and returns the highest-scored success
+type scoreFetcher struct {
+	field   string
+	members []namedFetcher
+	logger  *slog.Logger
+}
+
+type scoredResult struct {
+	value string
+	score float64
+	err   error
+}
+
+func (f *scoreFetcher) Fetch(ctx context.Context, id int) (string, error) {
+	// Each goroutine writes only its own slot, so no locking is needed
+	results := make([]scoredResult, len(f.members))
+	done := make(chan struct{}, len(f.members))
+	for i, m := range f.members {
+		go func() {
+			defer func() { done <- struct{}{} }()
+			defer recoverFetch(f.logger, m.name, id, &results[i].err)
+			if sf, ok := m.fetcher.(ScoredFetcher); ok {
+				results[i].value, results[i].score, results[i].err = sf.FetchScored(ctx, id)
+				return
+			}
+			results[i].value, results[i].err = m.fetcher.Fetch(ctx, id)
+		}()
+	}
+	for range f.members {
+		<-done
+	}
+
+	best := -1
+	var errs []error
+	for i, res := range results {
+		if res.err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", f.members[i].name, res.err))
+			continue
+		}
+		if best < 0 || res.score > results[best].score {
+			best = i
+		}
+	}
+	if best < 0 {
+		return "", errors.Join(errs...)
+	}
+
+	f.logger.Info("highest-scored fetcher selected", "field", f.field,
+		"fetcher", f.members[best].name, "score", results[best].score, "user_id", id)
+	return results[best].value, nil
+}
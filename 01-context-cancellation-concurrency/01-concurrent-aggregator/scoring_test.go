@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAggregate_HighestScoreWins(t *testing.T) {
+	agg := New(
+		WithTimeout(time.Second),
+		WithLogger(newTestLogger()),
+		WithFetcher("crm", ScoredFetcherFunc(func(context.Context, int) (string, float64, error) {
+			return "Email: old@example.com", 0.4, nil
+		})),
+		WithFetcher("sso", ScoredFetcherFunc(func(context.Context, int) (string, float64, error) {
+			return "Email: alice@example.com", 0.9, nil
+		})),
+		WithHighestScore("email", []string{"crm", "sso"}),
+	)
+	agg.order.WithDelay(0)
+
+	got, err := agg.Aggregate(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "User: Name: Alice | Orders: 5 | Email: alice@example.com"
+	if got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestAggregate_HighestScoreSkipsFailures(t *testing.T) {
+	agg := New(
+		WithTimeout(time.Second),
+		WithLogger(newTestLogger()),
+		WithFetcher("crm", ScoredFetcherFunc(func(context.Context, int) (string, float64, error) {
+			return "Email: old@example.com", 0.4, nil
+		})),
+		WithFetcher("sso", ScoredFetcherFunc(func(context.Context, int) (string, float64, error) {
+			return "", 0.9, errors.New("sso down")
+		})),
+		WithHighestScore("email", []string{"crm", "sso"}),
+		WithComposer(func(r *Result) (string, error) {
+			v, _ := r.Get("email")
+			return v, nil
+		}),
+	)
+	agg.order.WithDelay(0)
+
+	got, err := agg.Aggregate(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "Email: old@example.com" {
+		t.Errorf("expected the lower-scored success, got %q", got)
+	}
+}
@@ -10,6 +10,7 @@ import (
 type ProfileService struct {
 	delay   time.Duration
 	willErr bool
+	result  string
 }
 
 // NewProfileService creates a new ProfileService
@@ -17,6 +18,7 @@ func NewProfileService() *ProfileService {
 	return &ProfileService{
 		delay:   100 * time.Millisecond,
 		willErr: false,
+		result:  "Name: Alice",
 	}
 }
 
@@ -32,6 +34,12 @@ func (s *ProfileService) WithError() *ProfileService {
 	return s
 }
 
+// WithResult sets the data the service returns (for testing)
+func (s *ProfileService) WithResult(result string) *ProfileService {
+	s.result = result
+	return s
+}
+
 // Fetch retrieves user profile data
 func (s *ProfileService) Fetch(ctx context.Context, id int) (string, error) {
 	if s.willErr {
@@ -41,7 +49,7 @@ func (s *ProfileService) Fetch(ctx context.Context, id int) (string, error) {
 	// Simulate network delay
 	select {
 	case <-time.After(s.delay):
-		return fmt.Sprintf("Name: Alice"), nil
+		return s.result, nil
 	case <-ctx.Done():
 		return "", ctx.Err()
 	}
@@ -51,6 +59,7 @@ func (s *ProfileService) Fetch(ctx context.Context, id int) (string, error) {
 type OrderService struct {
 	delay   time.Duration
 	willErr bool
+	result  string
 }
 
 // NewOrderService creates a new OrderService
@@ -58,6 +67,7 @@ func NewOrderService() *OrderService {
 	return &OrderService{
 		delay:   150 * time.Millisecond,
 		willErr: false,
+		result:  "Orders: 5",
 	}
 }
 
@@ -73,6 +83,12 @@ func (s *OrderService) WithError() *OrderService {
 	return s
 }
 
+// WithResult sets the data the service returns (for testing)
+func (s *OrderService) WithResult(result string) *OrderService {
+	s.result = result
+	return s
+}
+
 // Fetch retrieves user order data
 func (s *OrderService) Fetch(ctx context.Context, id int) (string, error) {
 	if s.willErr {
@@ -82,7 +98,7 @@ func (s *OrderService) Fetch(ctx context.Context, id int) (string, error) {
 	// Simulate network delay
 	select {
 	case <-time.After(s.delay):
-		return fmt.Sprintf("Orders: 5"), nil
+		return s.result, nil
 	case <-ctx.Done():
 		return "", ctx.Err()
 	}
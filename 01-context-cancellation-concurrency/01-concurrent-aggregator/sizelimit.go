@@ -0,0 +1,54 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"unicode/utf8"
+)
+
+// ErrResponseTooLarge is returned for a fetch whose result exceeds the
+// fetcher's WithMaxResponseSize limit
+var ErrResponseTooLarge = errors.New("response too large")
+
+// sizeLimit caps the length of one fetcher's result
+type sizeLimit struct {
+	bytes    int
+	truncate bool
+}
+
+// WithMaxResponseSize fails the named fetcher's fetch with
+// ErrResponseTooLarge when its result is longer than bytes
+func WithMaxResponseSize(name string, bytes int) Option {
+	return withSizeLimit(name, sizeLimit{bytes: bytes})
+}
+
+// WithTruncatedResponseSize cuts the named fetcher's result down to at most
+// bytes instead of failing. The cut never splits a UTF-8 character.
+func WithTruncatedResponseSize(name string, bytes int) Option {
+	return withSizeLimit(name, sizeLimit{bytes: bytes, truncate: true})
+}
+
+func withSizeLimit(name string, limit sizeLimit) Option {
+	return func(a *UserAggregator) {
+		if a.sizeLimits == nil {
+			a.sizeLimits = make(map[string]sizeLimit)
+		}
+		a.sizeLimits[name] = limit
+	}
+}
+
+// apply enforces the limit on result
+func (l sizeLimit) apply(result string) (string, error) {
+	if len(result) <= l.bytes {
+		return result, nil
+	}
+	if !l.truncate {
+		return "", fmt.Errorf("%w: %d bytes exceeds limit of %d", ErrResponseTooLarge, len(result), l.bytes)
+	}
+
+	n := max(l.bytes, 0)
+	for n > 0 && !utf8.RuneStart(result[n]) {
+		n--
+	}
+	return result[:n], nil
+}
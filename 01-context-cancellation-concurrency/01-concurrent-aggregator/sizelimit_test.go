@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAggregate_MaxResponseSizeRejectsOversized(t *testing.T) {
+	huge := strings.Repeat("x", 1<<20)
+	agg := New(
+		WithTimeout(time.Second),
+		WithLogger(newTestLogger()),
+		WithFetcher("profile", FetcherFunc(func(context.Context, int) (string, error) {
+			return huge, nil
+		})),
+		WithMaxResponseSize("profile", 1024),
+	)
+	agg.order.WithDelay(0)
+
+	_, err := agg.Aggregate(context.Background(), 1)
+	if !errors.Is(err, ErrResponseTooLarge) {
+		t.Fatalf("expected ErrResponseTooLarge, got %v", err)
+	}
+}
+
+func TestAggregate_TruncatedResponseSize(t *testing.T) {
+	agg := New(
+		WithTimeout(time.Second),
+		WithLogger(newTestLogger()),
+		WithFetcher("profile", FetcherFunc(func(context.Context, int) (string, error) {
+			// "é" is bytes 6 and 7, so a 7 byte cut would split it
+			return "Name: élodie", nil
+		})),
+		WithTruncatedResponseSize("profile", 7),
+		WithComposer(func(r *Result) (string, error) {
+			v, _ := r.Get("profile")
+			return v, nil
+		}),
+	)
+	agg.order.WithDelay(0)
+
+	got, err := agg.Aggregate(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "Name: " {
+		t.Errorf("expected truncation before the split character, got %q", got)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// Fetch statuses reported in the aggregation summary
+const (
+	fetchStatusOK      = "ok"
+	fetchStatusFailed  = "failed"
+	fetchStatusSkipped = "skipped"
+)
+
+// fetchOutcome records how one fetcher fared during a fan-out
+type fetchOutcome struct {
+	name     string
+	status   string
+	cached   bool
+	duration time.Duration
+	// fetchErr is the fetcher's own error, even if it was then recovered
+	fetchErr error
+}
+
+// finish fills in the outcome once the fetcher has returned. A fetcher
+// cancelled because another one failed is skipped rather than failed.
+func (o *fetchOutcome) finish(start time.Time, err error) {
+	o.duration = time.Since(start)
+	switch {
+	case err == nil:
+		o.status = fetchStatusOK
+	case errors.Is(err, context.Canceled):
+		o.status = fetchStatusSkipped
+	default:
+		o.status = fetchStatusFailed
+	}
+}
+
+// logSummary writes the single line describing a whole fan-out: the overall
+// outcome, its duration, the call's tag if it has one and, grouped by
+// fetcher name, each fetcher's status, duration and whether it was served
+// from the cache
+func (a *UserAggregator) logSummary(id int, tag string, start time.Time, outcomes []fetchOutcome, err error) {
+	status := fetchStatusOK
+	if err != nil {
+		status = fetchStatusFailed
+	}
+
+	attrs := make([]any, 0, len(outcomes)+6)
+	attrs = append(attrs,
+		"user_id", id,
+		"status", status,
+		"total_duration", time.Since(start),
+	)
+	if tag != "" {
+		attrs = append(attrs, "tag", tag)
+	}
+	if err != nil {
+		attrs = append(attrs, "error", err)
+	}
+	for _, o := range outcomes {
+		attrs = append(attrs, slog.Group(o.name,
+			"status", o.status,
+			"duration", o.duration,
+			"cached", o.cached,
+		))
+	}
+
+	a.logger.Info("aggregation summary", attrs...)
+}
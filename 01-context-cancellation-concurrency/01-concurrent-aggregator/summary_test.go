@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// lockedBuffer is a goroutine-safe buffer for capturing log output
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// line returns the first captured log line containing msg
+func (b *lockedBuffer) line(msg string) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, l := range strings.Split(b.buf.String(), "\n") {
+		if strings.Contains(l, `msg="`+msg+`"`) {
+			return l
+		}
+	}
+	return ""
+}
+
+func TestAggregate_SummaryLogsEachFetcher(t *testing.T) {
+	var logs lockedBuffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+
+	// Blocks until the aggregation is cancelled by the failing fetcher
+	slow := FetcherFunc(func(ctx context.Context, id int) (string, error) {
+		<-ctx.Done()
+		return "", ctx.Err()
+	})
+
+	agg := New(
+		WithTimeout(2*time.Second),
+		WithLogger(logger),
+		WithFetcher("slow", slow),
+		WithFetcherCache("profile", time.Minute),
+	)
+	agg.profile.WithDelay(0)
+	agg.order.WithDelay(0).WithError()
+
+	// Warm the profile cache through a separate aggregator step
+	if _, err := agg.runFetcher(context.Background(), agg.fetchers[0], 1, agg.callConfig(nil), &fetchOutcome{}); err != nil {
+		t.Fatalf("warm-up fetch failed: %v", err)
+	}
+
+	if _, err := agg.Aggregate(context.Background(), 1); err == nil {
+		t.Fatal("expected the failing order fetcher to fail the aggregation")
+	}
+
+	line := logs.line("aggregation summary")
+	if line == "" {
+		t.Fatal("no aggregation summary logged")
+	}
+	for _, want := range []string{
+		"level=INFO",
+		"user_id=1",
+		"status=failed",
+		"total_duration=",
+		"profile.status=ok",
+		"profile.cached=true",
+		"order.status=failed",
+		"order.cached=false",
+		"order.duration=",
+		"slow.status=skipped",
+	} {
+		if !strings.Contains(line, want) {
+			t.Errorf("expected summary to contain %s, got %q", want, line)
+		}
+	}
+}
+
+func TestAggregate_SummaryLoggedOnSuccess(t *testing.T) {
+	var logs lockedBuffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+
+	agg := New(WithTimeout(2*time.Second), WithLogger(logger))
+	agg.profile.WithDelay(0)
+	agg.order.WithDelay(0)
+
+	if _, err := agg.Aggregate(context.Background(), 3); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	line := logs.line("aggregation summary")
+	for _, want := range []string{"status=ok", "profile.status=ok", "order.status=ok"} {
+		if !strings.Contains(line, want) {
+			t.Errorf("expected summary to contain %s, got %q", want, line)
+		}
+	}
+	if strings.Contains(line, "error=") {
+		t.Errorf("expected no error on a successful summary, got %q", line)
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// TypedFetcher retrieves one piece of user data as a T
+type TypedFetcher[T any] interface {
+	Fetch(ctx context.Context, id int) (T, error)
+}
+
+// TypedFetcherFunc adapts an ordinary function to the TypedFetcher interface
+type TypedFetcherFunc[T any] func(ctx context.Context, id int) (T, error)
+
+// Fetch calls f(ctx, id)
+func (f TypedFetcherFunc[T]) Fetch(ctx context.Context, id int) (T, error) {
+	return f(ctx, id)
+}
+
+// typedNamedFetcher is the TypedAggregator counterpart of namedFetcher
+type typedNamedFetcher[T any] struct {
+	name     string
+	fetcher  TypedFetcher[T]
+	optional bool
+}
+
+// TypedAggregator fans out to fetchers that return structured values and
+// collects them by name, with no string composition step. Like
+// UserAggregator it is bounded by a timeout, fails fast when a required
+// fetcher fails, and leaves failed optional fetchers out of the result.
+type TypedAggregator[T any] struct {
+	timeout  time.Duration
+	logger   *slog.Logger
+	fetchers []typedNamedFetcher[T]
+	parallel int
+}
+
+// TypedOption configures a TypedAggregator
+type TypedOption[T any] func(*TypedAggregator[T])
+
+// WithTypedTimeout sets the timeout for aggregation operations. As with
+// WithTimeout, a zero or negative d restores the default.
+func WithTypedTimeout[T any](d time.Duration) TypedOption[T] {
+	return func(a *TypedAggregator[T]) {
+		if d <= 0 {
+			d = defaultTimeout
+		}
+		a.timeout = d
+	}
+}
+
+// WithTypedLogger sets a custom logger
+func WithTypedLogger[T any](logger *slog.Logger) TypedOption[T] {
+	return func(a *TypedAggregator[T]) {
+		a.logger = logger
+	}
+}
+
+// WithTypedFetcher registers a fetcher under name, replacing any fetcher
+// already registered with that name. A nil f is dropped with a warning by
+// NewTypedAggregator, leaving name unregistered.
+func WithTypedFetcher[T any](name string, f TypedFetcher[T]) TypedOption[T] {
+	return withTypedFetcher(typedNamedFetcher[T]{name: name, fetcher: f})
+}
+
+// WithTypedOptionalFetcher registers a fetcher whose failure leaves its
+// name out of the result instead of failing the aggregation
+func WithTypedOptionalFetcher[T any](name string, f TypedFetcher[T]) TypedOption[T] {
+	return withTypedFetcher(typedNamedFetcher[T]{name: name, fetcher: f, optional: true})
+}
+
+func withTypedFetcher[T any](nf typedNamedFetcher[T]) TypedOption[T] {
+	return func(a *TypedAggregator[T]) {
+		for i := range a.fetchers {
+			if a.fetchers[i].name == nf.name {
+				a.fetchers[i] = nf
+				return
+			}
+		}
+		a.fetchers = append(a.fetchers, nf)
+	}
+}
+
+// WithTypedFetchParallelism limits how many fetchers run at once.
+// Zero or negative means no limit.
+func WithTypedFetchParallelism[T any](n int) TypedOption[T] {
+	return func(a *TypedAggregator[T]) {
+		a.parallel = n
+	}
+}
+
+// NewTypedAggregator creates a TypedAggregator with the provided options.
+// Unlike New it has no default fetchers.
+func NewTypedAggregator[T any](opts ...TypedOption[T]) *TypedAggregator[T] {
+	agg := &TypedAggregator[T]{
+		timeout: defaultTimeout,
+		logger:  slog.Default(),
+	}
+	for _, opt := range opts {
+		opt(agg)
+	}
+	agg.fetchers = slices.DeleteFunc(agg.fetchers, func(nf typedNamedFetcher[T]) bool {
+		fn, isFunc := nf.fetcher.(TypedFetcherFunc[T])
+		if nf.fetcher == nil || isFunc && fn == nil {
+			agg.logger.Warn("skipping nil fetcher", "fetcher", nf.name)
+			return true
+		}
+		return false
+	})
+	return agg
+}
+
+// Aggregate fetches from all registered fetchers concurrently and returns
+// their values keyed by fetcher name
+func (a *TypedAggregator[T]) Aggregate(ctx context.Context, id int) (map[string]T, error) {
+	ctx, cancel := context.WithTimeout(ctx, a.timeout)
+	defer cancel()
+
+	g, gCtx := errgroup.WithContext(ctx)
+	if a.parallel > 0 {
+		g.SetLimit(a.parallel)
+	}
+
+	// Each goroutine writes only its own slot, so no locking is needed
+	values := make([]T, len(a.fetchers))
+	ok := make([]bool, len(a.fetchers))
+
+	for i, nf := range a.fetchers {
+		g.Go(func() error {
+			a.logger.Info("fetching", "fetcher", nf.name, "user_id", id)
+			value, err := a.fetch(gCtx, nf, id)
+			if err != nil {
+				if nf.optional {
+					a.logger.Warn("optional fetch failed, leaving it out", "fetcher", nf.name, "error", err, "user_id", id)
+					return nil
+				}
+				a.logger.Error("fetch failed", "fetcher", nf.name, "error", err, "user_id", id)
+				return fmt.Errorf("%s service: %w", nf.name, err)
+			}
+			values[i], ok[i] = value, true
+			return nil
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]T, len(a.fetchers))
+	for i, nf := range a.fetchers {
+		if ok[i] {
+			result[nf.name] = values[i]
+		}
+	}
+	a.logger.Info("aggregation completed", "user_id", id, "fields", len(result))
+	return result, nil
+}
+
+// fetch calls the fetcher, recovering a panic as an error
+func (a *TypedAggregator[T]) fetch(ctx context.Context, nf typedNamedFetcher[T], id int) (_ T, err error) {
+	defer recoverFetch(a.logger, nf.name, id, &err)
+	return nf.fetcher.Fetch(ctx, id)
+}
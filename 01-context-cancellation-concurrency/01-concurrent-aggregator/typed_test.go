@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// account is a structured fetch result, to check nothing goes through strings
+type account struct {
+	Name   string
+	Orders []int
+}
+
+func TestTypedAggregate_StructValues(t *testing.T) {
+	orders := []int{7, 9}
+	agg := NewTypedAggregator(
+		WithTypedTimeout[account](time.Second),
+		WithTypedLogger[account](newTestLogger()),
+		WithTypedFetcher("profile", TypedFetcherFunc[account](func(_ context.Context, id int) (account, error) {
+			return account{Name: "Alice"}, nil
+		})),
+		WithTypedFetcher("orders", TypedFetcherFunc[account](func(context.Context, int) (account, error) {
+			return account{Orders: orders}, nil
+		})),
+		WithTypedOptionalFetcher("loyalty", TypedFetcherFunc[account](func(context.Context, int) (account, error) {
+			return account{}, errors.New("loyalty service down")
+		})),
+	)
+
+	got, err := agg.Aggregate(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got["profile"].Name != "Alice" {
+		t.Errorf("expected profile name Alice, got %+v", got["profile"])
+	}
+	// The same slice, not a decoded copy
+	if o := got["orders"].Orders; len(o) != 2 || &o[0] != &orders[0] {
+		t.Errorf("expected the fetcher's orders slice, got %v", o)
+	}
+	if _, ok := got["loyalty"]; ok {
+		t.Error("expected the failed optional fetcher to be left out")
+	}
+}
+
+func TestTypedAggregate_FailFast(t *testing.T) {
+	errDown := errors.New("profile service down")
+	agg := NewTypedAggregator(
+		WithTypedTimeout[int](2*time.Second),
+		WithTypedLogger[int](newTestLogger()),
+		WithTypedFetcher("profile", TypedFetcherFunc[int](func(context.Context, int) (int, error) {
+			return 0, errDown
+		})),
+		WithTypedFetcher("slow", TypedFetcherFunc[int](func(ctx context.Context, _ int) (int, error) {
+			select {
+			case <-time.After(time.Second):
+				return 1, nil
+			case <-ctx.Done():
+				return 0, ctx.Err()
+			}
+		})),
+	)
+
+	start := time.Now()
+	_, err := agg.Aggregate(context.Background(), 1)
+	if !errors.Is(err, errDown) {
+		t.Fatalf("expected the profile failure, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("expected the slow fetcher to be cancelled, took %v", elapsed)
+	}
+}
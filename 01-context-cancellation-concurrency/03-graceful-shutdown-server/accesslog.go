@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http"
+	"time"
+)
+
+// statusWriter records the response status for the access log
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (sw *statusWriter) WriteHeader(status int) {
+	if sw.status == 0 {
+		sw.status = status
+	}
+	sw.ResponseWriter.WriteHeader(status)
+}
+
+func (sw *statusWriter) Write(p []byte) (int, error) {
+	if sw.status == 0 {
+		sw.status = http.StatusOK
+	}
+	return sw.ResponseWriter.Write(p)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (sw *statusWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
+
+// accessLog logs one line per completed request, subject to sampling:
+// every AccessLogSampleRate-th request is logged, and so is any request
+// slower than AccessLogSlowThreshold
+func (s *Server) accessLog(next http.Handler) http.Handler {
+	rate := uint64(max(s.config.AccessLogSampleRate, 1))
+	slow := s.config.AccessLogSlowThreshold
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, r)
+		elapsed := time.Since(start)
+
+		sampled := s.accessSeq.Add(1)%rate == 0
+		isSlow := slow > 0 && elapsed >= slow
+		if !sampled && !isSlow {
+			return
+		}
+
+		status := sw.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		s.config.Logger.Info("access",
+			"request_id", w.Header().Get("X-Request-ID"),
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", status,
+			"duration", elapsed,
+			"slow", isSlow,
+		)
+	})
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// accessLines returns the access log lines in logs
+func accessLines(logs string) []string {
+	var lines []string
+	for _, l := range strings.Split(logs, "\n") {
+		if strings.Contains(l, "msg=access") {
+			lines = append(lines, l)
+		}
+	}
+	return lines
+}
+
+func TestServer_AccessLogSampling(t *testing.T) {
+	var logs syncBuffer
+	config := validConfig()
+	config.Logger = slog.New(slog.NewTextHandler(&logs, nil))
+	config.AccessLog = true
+	config.AccessLogSampleRate = 10
+
+	server := NewServer(config)
+	handler := server.accessLog(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	const numRequests = 200
+	for i := 0; i < numRequests; i++ {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/fast", nil))
+	}
+
+	lines := accessLines(logs.String())
+	if len(lines) != numRequests/10 {
+		t.Errorf("expected 1 in 10 of %d requests logged, got %d", numRequests, len(lines))
+	}
+	if len(lines) > 0 && !strings.Contains(lines[0], "status=204") {
+		t.Errorf("expected the access line to record the status, got %q", lines[0])
+	}
+}
+
+func TestServer_AccessLogAlwaysLogsSlowRequests(t *testing.T) {
+	var logs syncBuffer
+	config := validConfig()
+	config.Logger = slog.New(slog.NewTextHandler(&logs, nil))
+	config.AccessLog = true
+	config.AccessLogSampleRate = 1000
+	config.AccessLogSlowThreshold = 20 * time.Millisecond
+
+	server := NewServer(config)
+	handler := server.accessLog(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(30 * time.Millisecond)
+		}
+	}))
+
+	for i := 0; i < 20; i++ {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/fast", nil))
+	}
+	for i := 0; i < 3; i++ {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/slow", nil))
+	}
+
+	lines := accessLines(logs.String())
+	if len(lines) != 3 {
+		t.Fatalf("expected only the 3 slow requests logged, got %d:\n%s", len(lines), logs.String())
+	}
+	for _, l := range lines {
+		if !strings.Contains(l, "path=/slow") || !strings.Contains(l, "slow=true") {
+			t.Errorf("expected a slow request line, got %q", l)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"time"
+)
+
+// startAdminServer serves operational endpoints on ln.
+// It is kept off the public port so profiling data is never exposed there.
+func (s *Server) startAdminServer(ln net.Listener) {
+	mux := http.NewServeMux()
+
+	if s.config.EnablePprof {
+		s.registerPprof(mux)
+	}
+	if s.config.Build != (BuildInfo{}) {
+		mux.HandleFunc("/version", s.handleVersion)
+	}
+
+	// WriteTimeout is generous because profiles such as /debug/pprof/profile
+	// stream for several seconds
+	s.adminServer = &http.Server{
+		Addr:         ":" + s.config.AdminPort,
+		Handler:      mux,
+		ReadTimeout:  s.config.RequestTimeout,
+		WriteTimeout: 60 * time.Second,
+		IdleTimeout:  60 * time.Second,
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer s.recoverServe("admin server")
+		s.config.Logger.Info("admin server listening", "addr", s.adminServer.Addr)
+		if err := s.adminServer.Serve(ln); err != nil && err != http.ErrServerClosed {
+			s.config.Logger.Error("admin server error", "error", err)
+		}
+	}()
+}
+
+// registerPprof mounts the net/http/pprof handlers on mux, guarded by basic
+// auth when credentials are configured
+func (s *Server) registerPprof(mux *http.ServeMux) {
+	wrap := func(h http.HandlerFunc) http.Handler {
+		if s.config.PprofUsername == "" {
+			return h
+		}
+		return basicAuth(h, s.config.PprofUsername, s.config.PprofPassword)
+	}
+
+	mux.Handle("/debug/pprof/", wrap(pprof.Index))
+	mux.Handle("/debug/pprof/cmdline", wrap(pprof.Cmdline))
+	mux.Handle("/debug/pprof/profile", wrap(pprof.Profile))
+	mux.Handle("/debug/pprof/symbol", wrap(pprof.Symbol))
+	mux.Handle("/debug/pprof/trace", wrap(pprof.Trace))
+}
+
+// basicAuth rejects requests whose credentials don't match username/password
+func basicAuth(next http.Handler, username, password string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		// Constant-time comparison avoids leaking credentials via timing
+		if !ok ||
+			subtle.ConstantTimeCompare([]byte(user), []byte(username)) != 1 ||
+			subtle.ConstantTimeCompare([]byte(pass), []byte(password)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="pprof"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
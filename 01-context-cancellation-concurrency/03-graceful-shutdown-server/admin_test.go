@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAdmin_PprofOnlyOnAdminPort(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelError,
+	}))
+
+	config := Config{
+		Port:            "8084",
+		WorkerPoolSize:  2,
+		RequestTimeout:  5 * time.Second,
+		ShutdownTimeout: 5 * time.Second,
+		Logger:          logger,
+		AdminPort:       "8085",
+		EnablePprof:     true,
+	}
+
+	server := NewServer(config)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start server: %v", err)
+	}
+	defer server.Stop(context.Background())
+
+	waitReady(t, server)
+
+	// Admin port serves the pprof index
+	resp, err := http.Get(fmt.Sprintf("http://localhost:%s/debug/pprof/", config.AdminPort))
+	if err != nil {
+		t.Fatalf("admin request failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected 200 from admin pprof, got %d", resp.StatusCode)
+	}
+	if !strings.Contains(string(body), "goroutine") {
+		t.Errorf("expected pprof index listing profiles, got %q", body)
+	}
+
+	// Main port must not expose profiling data
+	resp, err = http.Get(fmt.Sprintf("http://localhost:%s/debug/pprof/", config.Port))
+	if err != nil {
+		t.Fatalf("main request failed: %v", err)
+	}
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if strings.Contains(string(body), "goroutine") {
+		t.Errorf("main port exposed pprof data: %q", body)
+	}
+}
+
+func TestAdmin_PprofBasicAuth(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelError,
+	}))
+
+	config := Config{
+		Port:            "8086",
+		WorkerPoolSize:  2,
+		RequestTimeout:  5 * time.Second,
+		ShutdownTimeout: 5 * time.Second,
+		Logger:          logger,
+		AdminPort:       "8087",
+		EnablePprof:     true,
+		PprofUsername:   "admin",
+		PprofPassword:   "secret",
+	}
+
+	server := NewServer(config)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start server: %v", err)
+	}
+	defer server.Stop(context.Background())
+
+	waitReady(t, server)
+
+	url := fmt.Sprintf("http://localhost:%s/debug/pprof/", config.AdminPort)
+
+	// Without credentials
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("admin request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected 401 without credentials, got %d", resp.StatusCode)
+	}
+
+	// With credentials
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	req.SetBasicAuth("admin", "secret")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("admin request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected 200 with credentials, got %d", resp.StatusCode)
+	}
+}
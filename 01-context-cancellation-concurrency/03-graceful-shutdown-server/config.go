@@ -0,0 +1,85 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// queueSizePerWorker sizes a pool's queue when QueueSize is left unset, as
+// a multiple of its worker count
+const queueSizePerWorker = 2
+
+// withDefaults returns a copy of c with unset optional fields filled in.
+// Required fields are left for Validate to report.
+func (c Config) withDefaults() Config {
+	if c.QueueSize == 0 && c.WorkerPoolSize > 0 {
+		c.QueueSize = c.WorkerPoolSize * queueSizePerWorker
+	}
+	if c.DrainProgressInterval == 0 {
+		c.DrainProgressInterval = defaultDrainProgressInterval
+	}
+	if c.WarmTimeout == 0 {
+		c.WarmTimeout = defaultWarmTimeout
+	}
+	if len(c.Pools) > 0 {
+		// Copied so the caller's slice isn't modified
+		pools := make([]PoolConfig, len(c.Pools))
+		copy(pools, c.Pools)
+		for i := range pools {
+			if pools[i].QueueSize == 0 && pools[i].Size > 0 {
+				pools[i].QueueSize = pools[i].Size * queueSizePerWorker
+			}
+		}
+		c.Pools = pools
+	}
+	return c
+}
+
+// Validate reports every invalid Config field at once, so a misconfigured
+// server fails at Start instead of, say, starting a pool with no workers
+// that silently hangs every submit
+func (c Config) Validate() error {
+	var errs []error
+
+	if c.Port == "" {
+		errs = append(errs, errors.New("Port is required"))
+	}
+	if c.WorkerPoolSize < 1 {
+		errs = append(errs, fmt.Errorf("WorkerPoolSize must be at least 1, got %d", c.WorkerPoolSize))
+	}
+	if c.RequestTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("RequestTimeout must be positive, got %v", c.RequestTimeout))
+	}
+	if c.ShutdownTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("ShutdownTimeout must be positive, got %v", c.ShutdownTimeout))
+	}
+	if c.Logger == nil {
+		errs = append(errs, errors.New("Logger is required"))
+	}
+	if c.LameDuckPeriod < 0 {
+		errs = append(errs, fmt.Errorf("LameDuckPeriod must not be negative, got %v", c.LameDuckPeriod))
+	}
+	if c.QueueSize < 0 {
+		errs = append(errs, fmt.Errorf("QueueSize must not be negative, got %d", c.QueueSize))
+	}
+	if c.AccessLogSampleRate < 0 {
+		errs = append(errs, fmt.Errorf("AccessLogSampleRate must not be negative, got %d", c.AccessLogSampleRate))
+	}
+	if c.DrainProgressInterval < 0 {
+		errs = append(errs, fmt.Errorf("DrainProgressInterval must not be negative, got %v", c.DrainProgressInterval))
+	}
+	if c.WarmTimeout < 0 {
+		errs = append(errs, fmt.Errorf("WarmTimeout must not be negative, got %v", c.WarmTimeout))
+	}
+	if c.ShutdownOrder != StopCacheWarmerFirst && c.ShutdownOrder != CloseDBFirst {
+		errs = append(errs, fmt.Errorf("ShutdownOrder %d is not a known order", c.ShutdownOrder))
+	}
+	if err := c.validateRoutes(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid config: %w", errors.Join(errs...))
+	}
+	return nil
+}
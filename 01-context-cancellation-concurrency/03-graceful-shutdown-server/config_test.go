@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// validConfig returns a Config that passes Validate
+func validConfig() Config {
+	return Config{
+		Port:            "8098",
+		WorkerPoolSize:  2,
+		RequestTimeout:  5 * time.Second,
+		ShutdownTimeout: 5 * time.Second,
+		Logger: slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+			Level: slog.LevelError,
+		})),
+	}
+}
+
+func TestConfig_ValidateAcceptsValidConfig(t *testing.T) {
+	if err := validConfig().Validate(); err != nil {
+		t.Errorf("expected valid config, got %v", err)
+	}
+}
+
+func TestConfig_ValidateReportsEachField(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(*Config)
+		want   string
+	}{
+		{"empty port", func(c *Config) { c.Port = "" }, "Port"},
+		{"zero workers", func(c *Config) { c.WorkerPoolSize = 0 }, "WorkerPoolSize"},
+		{"negative workers", func(c *Config) { c.WorkerPoolSize = -1 }, "WorkerPoolSize"},
+		{"zero request timeout", func(c *Config) { c.RequestTimeout = 0 }, "RequestTimeout"},
+		{"zero shutdown timeout", func(c *Config) { c.ShutdownTimeout = 0 }, "ShutdownTimeout"},
+		{"negative shutdown timeout", func(c *Config) { c.ShutdownTimeout = -time.Second }, "ShutdownTimeout"},
+		{"nil logger", func(c *Config) { c.Logger = nil }, "Logger"},
+		{"unknown shutdown order", func(c *Config) { c.ShutdownOrder = 7 }, "ShutdownOrder"},
+		{"negative queue size", func(c *Config) { c.QueueSize = -1 }, "QueueSize"},
+		{"negative access log sample rate", func(c *Config) { c.AccessLogSampleRate = -1 }, "AccessLogSampleRate"},
+		{"unknown route pool", func(c *Config) { c.Routes = []Route{{Pattern: "/io/", Pool: "missing"}} }, "unknown worker pool"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := validConfig()
+			tt.mutate(&config)
+
+			err := config.Validate()
+			if err == nil {
+				t.Fatal("expected a validation error")
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected error to mention %s, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestConfig_ValidateReportsAllFieldsAtOnce(t *testing.T) {
+	err := Config{}.Validate()
+	if err == nil {
+		t.Fatal("expected the zero Config to be invalid")
+	}
+	for _, field := range []string{"Port", "WorkerPoolSize", "RequestTimeout", "ShutdownTimeout", "Logger"} {
+		if !strings.Contains(err.Error(), field) {
+			t.Errorf("expected error to mention %s, got %v", field, err)
+		}
+	}
+}
+
+func TestServer_StartRejectsInvalidConfig(t *testing.T) {
+	config := validConfig()
+	config.WorkerPoolSize = 0
+
+	server := NewServer(config)
+	if err := server.Start(context.Background()); err == nil {
+		server.Stop(context.Background())
+		t.Fatal("expected Start to reject WorkerPoolSize 0")
+	}
+}
+
+func TestServer_ConfigReportsDefaults(t *testing.T) {
+	config := validConfig()
+	config.Pools = []PoolConfig{{Name: "io", Size: 3}, {Name: "batch", Size: 1, QueueSize: 10}}
+
+	server := NewServer(config)
+	got := server.Config()
+
+	if got.WorkerPoolSize != 2 || got.RequestTimeout != 5*time.Second || got.ShutdownTimeout != 5*time.Second {
+		t.Errorf("expected explicit settings to be kept, got %+v", got)
+	}
+	if got.QueueSize != 4 {
+		t.Errorf("expected default QueueSize of twice the workers (4), got %d", got.QueueSize)
+	}
+	if got.DrainProgressInterval != defaultDrainProgressInterval {
+		t.Errorf("expected default DrainProgressInterval %v, got %v", defaultDrainProgressInterval, got.DrainProgressInterval)
+	}
+	if got.WarmTimeout != defaultWarmTimeout {
+		t.Errorf("expected default WarmTimeout %v, got %v", defaultWarmTimeout, got.WarmTimeout)
+	}
+	if got.Pools[0].QueueSize != 6 {
+		t.Errorf("expected pool io to default to queue size 6, got %d", got.Pools[0].QueueSize)
+	}
+	if got.Pools[1].QueueSize != 10 {
+		t.Errorf("expected pool batch to keep queue size 10, got %d", got.Pools[1].QueueSize)
+	}
+	if config.Pools[0].QueueSize != 0 {
+		t.Error("expected NewServer not to modify the caller's pools")
+	}
+
+	// The result is a copy
+	got.Pools[0].Size = 99
+	if server.Config().Pools[0].Size != 3 {
+		t.Error("expected changes to the returned config not to affect the server")
+	}
+}
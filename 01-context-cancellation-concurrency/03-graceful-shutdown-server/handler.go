@@ -0,0 +1,45 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// HandlerFunc is a route handler run on a worker. Instead of writing an
+// error response itself it returns the error, and the pool answers for it:
+// with the status from a StatusCode() method if the error has one, and 500
+// otherwise. A handler that returns an error must not have written anything.
+type HandlerFunc func(w http.ResponseWriter, r *http.Request) error
+
+// statusCoder is implemented by errors that carry their own HTTP status
+type statusCoder interface {
+	StatusCode() int
+}
+
+// runHandler runs the request's handler and turns a returned error into an
+// error response
+func (wp *workerPool) runHandler(req *request, logger *slog.Logger) {
+	workStart := time.Now()
+	wp.setWriteDeadline(req.w, logger)
+	err := req.handler(req.w, req.r)
+	wp.observeProcessing(time.Since(workStart))
+	if err == nil {
+		return
+	}
+
+	// Only errors that chose their status are assumed safe to show clients
+	status, msg := http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError)
+	var sc statusCoder
+	if errors.As(err, &sc) {
+		status, msg = sc.StatusCode(), err.Error()
+	}
+
+	if status >= http.StatusInternalServerError {
+		logger.Error("handler returned error", "status", status, "error", err)
+	} else {
+		logger.Warn("handler returned error", "status", status, "error", err)
+	}
+	writeError(req.w, wp.jsonErrors, msg, status)
+}
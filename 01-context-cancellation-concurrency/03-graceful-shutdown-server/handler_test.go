@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// statusError is a handler error that picks its own response status
+type statusError struct {
+	code int
+	msg  string
+}
+
+func (e *statusError) Error() string   { return e.msg }
+func (e *statusError) StatusCode() int { return e.code }
+
+func TestServer_HandlerErrors(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelError,
+	}))
+
+	config := Config{
+		Port:            "8100",
+		WorkerPoolSize:  2,
+		RequestTimeout:  5 * time.Second,
+		ShutdownTimeout: 5 * time.Second,
+		Logger:          logger,
+		Routes: []Route{
+			{Pattern: "/ok", Handler: func(w http.ResponseWriter, r *http.Request) error {
+				fmt.Fprintln(w, "fine")
+				return nil
+			}},
+			{Pattern: "/missing", Handler: func(w http.ResponseWriter, r *http.Request) error {
+				// Wrapped, to check the status is found through the chain
+				return fmt.Errorf("lookup: %w", &statusError{code: http.StatusNotFound, msg: "no such widget"})
+			}},
+			{Pattern: "/broken", Handler: func(w http.ResponseWriter, r *http.Request) error {
+				return errors.New("database password is hunter2")
+			}},
+		},
+	}
+
+	server := NewServer(config)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start server: %v", err)
+	}
+	defer server.Stop(context.Background())
+	waitReady(t, server)
+
+	tests := []struct {
+		path     string
+		wantCode int
+		wantBody string
+	}{
+		{"/ok", http.StatusOK, "fine"},
+		{"/missing", http.StatusNotFound, "lookup: no such widget"},
+		// Errors without a status must not leak their message
+		{"/broken", http.StatusInternalServerError, "Internal Server Error"},
+	}
+	for _, tt := range tests {
+		resp, err := http.Get(fmt.Sprintf("http://localhost:%s%s", config.Port, tt.path))
+		if err != nil {
+			t.Fatalf("%s: request failed: %v", tt.path, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode != tt.wantCode {
+			t.Errorf("%s: expected status %d, got %d", tt.path, tt.wantCode, resp.StatusCode)
+		}
+		if got := strings.TrimSpace(string(body)); got != tt.wantBody {
+			t.Errorf("%s: expected body %q, got %q", tt.path, tt.wantBody, got)
+		}
+	}
+}
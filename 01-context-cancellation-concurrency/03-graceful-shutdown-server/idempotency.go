@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"hash/fnv"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// idempotencyShards is the number of shards in the idempotency store; keys
+// are spread across shards so concurrent requests rarely share a lock
+const idempotencyShards = 32
+
+// responseSnapshot is a recorded response that can be replayed
+type responseSnapshot struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+// idempotencyEntry is the response for one key. done is closed once
+// snapshot is set, so duplicates arriving while the first request is still
+// in flight wait for its response rather than running it again.
+type idempotencyEntry struct {
+	done      chan struct{}
+	snapshot  *responseSnapshot
+	expiresAt time.Time
+}
+
+type idempotencyShard struct {
+	mu      sync.Mutex
+	entries map[string]*idempotencyEntry
+}
+
+// idempotencyStore maps Idempotency-Key values to recorded responses using
+// the same sharded-lock layout as the sharded map kata
+type idempotencyStore struct {
+	ttl    time.Duration
+	shards [idempotencyShards]idempotencyShard
+}
+
+func newIdempotencyStore(ttl time.Duration) *idempotencyStore {
+	st := &idempotencyStore{ttl: ttl}
+	for i := range st.shards {
+		st.shards[i].entries = make(map[string]*idempotencyEntry)
+	}
+	return st
+}
+
+func (st *idempotencyStore) shard(key string) *idempotencyShard {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return &st.shards[h.Sum64()%idempotencyShards]
+}
+
+// claim returns the live entry for key and whether it already existed. A
+// new entry is pending: the caller must complete or abandon it.
+func (st *idempotencyStore) claim(key string) (*idempotencyEntry, bool) {
+	sh := st.shard(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	if e, ok := sh.entries[key]; ok {
+		select {
+		case <-e.done:
+			if time.Now().Before(e.expiresAt) {
+				return e, true
+			}
+		default:
+			return e, true // still in flight
+		}
+	}
+
+	e := &idempotencyEntry{done: make(chan struct{})}
+	sh.entries[key] = e
+	return e, false
+}
+
+// complete records the response for a claimed entry and releases waiters
+func (st *idempotencyStore) complete(e *idempotencyEntry, snap *responseSnapshot) {
+	e.snapshot = snap
+	e.expiresAt = time.Now().Add(st.ttl)
+	close(e.done)
+}
+
+// abandon removes a claimed entry without recording a response, so the next
+// request with the key is processed afresh; waiters are released and retry
+func (st *idempotencyStore) abandon(key string, e *idempotencyEntry) {
+	sh := st.shard(key)
+	sh.mu.Lock()
+	if sh.entries[key] == e {
+		delete(sh.entries, key)
+	}
+	sh.mu.Unlock()
+	close(e.done)
+}
+
+// sweep drops expired entries, one shard at a time
+func (st *idempotencyStore) sweep() {
+	now := time.Now()
+	for i := range st.shards {
+		sh := &st.shards[i]
+		sh.mu.Lock()
+		for key, e := range sh.entries {
+			select {
+			case <-e.done:
+				if now.After(e.expiresAt) {
+					delete(sh.entries, key)
+				}
+			default:
+			}
+		}
+		sh.mu.Unlock()
+	}
+}
+
+// runSweeper sweeps the store every ttl until ctx is done
+func (st *idempotencyStore) runSweeper(ctx context.Context) {
+	ticker := time.NewTicker(st.ttl)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			st.sweep()
+		}
+	}
+}
+
+// recordingWriter passes a response through to the client while keeping a
+// copy of it
+type recordingWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rw *recordingWriter) WriteHeader(status int) {
+	if rw.status == 0 {
+		rw.status = status
+	}
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *recordingWriter) Write(p []byte) (int, error) {
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+	rw.body.Write(p)
+	return rw.ResponseWriter.Write(p)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (rw *recordingWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// idempotent replays the recorded response for a repeated Idempotency-Key
+// instead of calling next again. Keys are scoped to the request's method and
+// path, so a key reused on another endpoint isn't answered with this one's
+// response. Responses that ask to be retried are not recorded, so the retry
+// can succeed with the same key; see retryable.
+func (s *Server) idempotent(next http.Handler, st *idempotencyStore) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		key = r.Method + " " + r.URL.Path + " " + key
+
+		for {
+			e, existed := st.claim(key)
+			if !existed {
+				s.recordResponse(w, r, next, st, key, e)
+				return
+			}
+
+			select {
+			case <-e.done:
+			case <-r.Context().Done():
+				return
+			}
+			if e.snapshot != nil {
+				replay(w, e.snapshot)
+				return
+			}
+			// The first request was abandoned; try to claim the key again
+		}
+	})
+}
+
+// recordResponse serves r through next and records the response under key
+func (s *Server) recordResponse(w http.ResponseWriter, r *http.Request, next http.Handler, st *idempotencyStore, key string, e *idempotencyEntry) {
+	rw := &recordingWriter{ResponseWriter: w}
+	defer func() {
+		if retryable(rw.status, w.Header()) {
+			st.abandon(key, e)
+			return
+		}
+		st.complete(e, &responseSnapshot{
+			status: rw.status,
+			header: w.Header().Clone(),
+			body:   bytes.Clone(rw.body.Bytes()),
+		})
+	}()
+	next.ServeHTTP(rw, r)
+}
+
+// retryable reports whether a response is transient, so replaying it for
+// the key's TTL would stop the client ever succeeding: no response at all,
+// server errors, 408 and 429, and anything carrying Retry-After
+func retryable(status int, header http.Header) bool {
+	switch {
+	case status == 0 || status >= http.StatusInternalServerError:
+		return true
+	case status == http.StatusRequestTimeout || status == http.StatusTooManyRequests:
+		return true
+	}
+	return header.Get("Retry-After") != ""
+}
+
+// replay writes a recorded response, marking it as a replay
+func replay(w http.ResponseWriter, snap *responseSnapshot) {
+	h := w.Header()
+	for k, v := range snap.header {
+		h[k] = v
+	}
+	h.Set("Idempotent-Replayed", "true")
+	w.WriteHeader(snap.status)
+	w.Write(snap.body)
+}
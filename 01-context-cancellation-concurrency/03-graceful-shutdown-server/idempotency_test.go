@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// postWithKey sends a POST carrying an Idempotency-Key and returns the
+// response with its body read
+func postWithKey(t *testing.T, url, key string) (*http.Response, string) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodPost, url, nil)
+	req.Header.Set("Idempotency-Key", key)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	return resp, string(body)
+}
+
+func TestServer_IdempotencyKeyReplaysResponse(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelError,
+	}))
+
+	config := Config{
+		Port:            "8097",
+		WorkerPoolSize:  2,
+		RequestTimeout:  5 * time.Second,
+		ShutdownTimeout: 5 * time.Second,
+		Logger:          logger,
+		IdempotencyTTL:  time.Minute,
+	}
+
+	server := NewServer(config)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start server: %v", err)
+	}
+	defer server.Stop(context.Background())
+	waitReady(t, server)
+
+	url := fmt.Sprintf("http://localhost:%s/payments", config.Port)
+
+	first, firstBody := postWithKey(t, url, "pay-1")
+	if first.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", first.StatusCode)
+	}
+
+	start := time.Now()
+	second, secondBody := postWithKey(t, url, "pay-1")
+	elapsed := time.Since(start)
+
+	if second.StatusCode != first.StatusCode || secondBody != firstBody {
+		t.Errorf("expected replay of %d %q, got %d %q", first.StatusCode, firstBody, second.StatusCode, secondBody)
+	}
+	if second.Header.Get("Idempotent-Replayed") != "true" {
+		t.Error("expected replayed response to be marked Idempotent-Replayed")
+	}
+	// The request ID is assigned by dispatch, so a matching ID means the
+	// second request never reached a worker
+	if got, want := second.Header.Get("X-Request-ID"), first.Header.Get("X-Request-ID"); got != want {
+		t.Errorf("expected replayed request ID %q, got %q", want, got)
+	}
+	if elapsed > 50*time.Millisecond {
+		t.Errorf("expected replay without the 100ms processing, took %v", elapsed)
+	}
+
+	// A different key is processed normally
+	third, _ := postWithKey(t, url, "pay-2")
+	if third.Header.Get("Idempotent-Replayed") != "" {
+		t.Error("expected a new key not to be replayed")
+	}
+	if third.Header.Get("X-Request-ID") == first.Header.Get("X-Request-ID") {
+		t.Error("expected a new key to be processed as a new request")
+	}
+}
+
+func TestIdempotent_ConcurrentDuplicatesRunOnce(t *testing.T) {
+	var calls atomic.Int32
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		time.Sleep(50 * time.Millisecond)
+		fmt.Fprint(w, "created")
+	})
+
+	s := &Server{}
+	handler := s.idempotent(next, newIdempotencyStore(time.Minute))
+
+	var wg sync.WaitGroup
+	bodies := make([]string, 5)
+	for i := range bodies {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			r := httptest.NewRequest(http.MethodPost, "/orders", nil)
+			r.Header.Set("Idempotency-Key", "order-1")
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			bodies[i] = w.Body.String()
+		}(i)
+	}
+	wg.Wait()
+
+	if got := calls.Load(); got != 1 {
+		t.Errorf("expected the handler to run once, ran %d times", got)
+	}
+	for i, body := range bodies {
+		if body != "created" {
+			t.Errorf("request %d: expected %q, got %q", i, "created", body)
+		}
+	}
+}
+
+func TestIdempotent_ServerErrorNotRecorded(t *testing.T) {
+	var calls atomic.Int32
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			http.Error(w, "try again", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, "ok")
+	})
+
+	s := &Server{}
+	handler := s.idempotent(next, newIdempotencyStore(time.Minute))
+
+	for i := 0; i < 2; i++ {
+		r := httptest.NewRequest(http.MethodPost, "/orders", nil)
+		r.Header.Set("Idempotency-Key", "order-2")
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	if got := calls.Load(); got != 2 {
+		t.Errorf("expected a 503 to be retried rather than replayed, handler ran %d times", got)
+	}
+}
+
+func TestIdempotent_TransientResponsesNotRecorded(t *testing.T) {
+	tests := []struct {
+		name  string
+		first func(w http.ResponseWriter)
+	}{
+		{"too many requests", func(w http.ResponseWriter) {
+			http.Error(w, "slow down", http.StatusTooManyRequests)
+		}},
+		{"request timeout", func(w http.ResponseWriter) {
+			http.Error(w, "timed out", http.StatusRequestTimeout)
+		}},
+		{"retry after", func(w http.ResponseWriter) {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusAccepted)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if calls.Add(1) == 1 {
+					tt.first(w)
+					return
+				}
+				fmt.Fprint(w, "created")
+			})
+
+			s := &Server{}
+			handler := s.idempotent(next, newIdempotencyStore(time.Minute))
+
+			bodies := make([]string, 3)
+			for i := range bodies {
+				r := httptest.NewRequest(http.MethodPost, "/orders", nil)
+				r.Header.Set("Idempotency-Key", "order-3")
+				w := httptest.NewRecorder()
+				handler.ServeHTTP(w, r)
+				bodies[i] = w.Body.String()
+			}
+
+			if got := calls.Load(); got != 2 {
+				t.Errorf("expected the retry to reach the handler and then be replayed, handler ran %d times", got)
+			}
+			if bodies[1] != "created" || bodies[2] != "created" {
+				t.Errorf("expected the retry's response to be recorded, got %q", bodies[1:])
+			}
+		})
+	}
+}
+
+func TestIdempotent_KeyScopedToEndpoint(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.Method+" "+r.URL.Path)
+	})
+
+	s := &Server{}
+	handler := s.idempotent(next, newIdempotencyStore(time.Minute))
+
+	for _, target := range []struct{ method, path string }{
+		{http.MethodPost, "/orders"},
+		{http.MethodPost, "/payments"},
+		{http.MethodPut, "/orders"},
+	} {
+		r := httptest.NewRequest(target.method, target.path, nil)
+		r.Header.Set("Idempotency-Key", "shared-key")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		if want := target.method + " " + target.path; w.Body.String() != want {
+			t.Errorf("expected %q, got %q", want, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"log/slog"
+	"net"
+	"sync"
+)
+
+// limitListener caps the number of simultaneously open connections.
+// Connections accepted past the limit are closed immediately, so the cap
+// holds for idle keep-alive connections too, not just in-flight requests.
+type limitListener struct {
+	net.Listener
+	sem    chan struct{}
+	logger *slog.Logger
+}
+
+func newLimitListener(ln net.Listener, max int, logger *slog.Logger) *limitListener {
+	return &limitListener{
+		Listener: ln,
+		sem:      make(chan struct{}, max),
+		logger:   logger,
+	}
+}
+
+// Accept waits for the next connection that fits under the limit
+func (l *limitListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+
+		select {
+		case l.sem <- struct{}{}:
+			return &limitConn{Conn: conn, release: func() { <-l.sem }}, nil
+		default:
+			l.logger.Warn("connection limit reached, refusing connection",
+				"remote_addr", conn.RemoteAddr().String(),
+				"max_connections", cap(l.sem),
+			)
+			conn.Close()
+		}
+	}
+}
+
+// limitConn frees its slot in the limitListener when closed
+type limitConn struct {
+	net.Conn
+	releaseOnce sync.Once
+	release     func()
+}
+
+func (c *limitConn) Close() error {
+	err := c.Conn.Close()
+	c.releaseOnce.Do(c.release)
+	return err
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+)
+
+// keepAliveGet sends a keep-alive GET over conn and reads the response
+func keepAliveGet(conn net.Conn, rd *bufio.Reader) (*http.Response, error) {
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+	if _, err := fmt.Fprint(conn, "GET / HTTP/1.1\r\nHost: localhost\r\n\r\n"); err != nil {
+		return nil, err
+	}
+	resp, err := http.ReadResponse(rd, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return resp, nil
+}
+
+func TestServer_MaxConnectionsRefusesExcess(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelError,
+	}))
+
+	config := Config{
+		Port:            "8093",
+		WorkerPoolSize:  4,
+		RequestTimeout:  5 * time.Second,
+		ShutdownTimeout: 5 * time.Second,
+		Logger:          logger,
+		MaxConnections:  2,
+	}
+
+	server := NewServer(config)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start server: %v", err)
+	}
+	defer server.Stop(context.Background())
+	waitReady(t, server)
+
+	addr := "localhost:" + config.Port
+
+	// Fill the limit with keep-alive connections that stay open
+	var held []net.Conn
+	for i := 0; i < config.MaxConnections; i++ {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatalf("dial %d failed: %v", i, err)
+		}
+		defer conn.Close()
+		resp, err := keepAliveGet(conn, bufio.NewReader(conn))
+		if err != nil {
+			t.Fatalf("connection %d within limit failed: %v", i, err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("connection %d: expected 200, got %d", i, resp.StatusCode)
+		}
+		held = append(held, conn)
+	}
+
+	// One more connection must be refused
+	extra, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	if _, err := keepAliveGet(extra, bufio.NewReader(extra)); err == nil {
+		t.Error("expected connection past MaxConnections to be refused")
+	}
+	extra.Close()
+
+	// Closing a held connection frees a slot
+	held[0].Close()
+	time.Sleep(100 * time.Millisecond)
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer conn.Close()
+	if _, err := keepAliveGet(conn, bufio.NewReader(conn)); err != nil {
+		t.Errorf("expected connection to be accepted after a slot was freed: %v", err)
+	}
+}
+
+// panicListener panics on Accept, standing in for a buggy custom listener
+type panicListener struct {
+	net.Listener
+}
+
+func (l panicListener) Accept() (net.Conn, error) {
+	panic("listener bug")
+}
+
+func TestServer_AcceptPanicShutsDown(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	config := Config{
+		Port:            "8103",
+		WorkerPoolSize:  1,
+		RequestTimeout:  5 * time.Second,
+		ShutdownTimeout: 5 * time.Second,
+		Logger:          logger,
+	}
+
+	server := NewServer(config)
+	server.wrapListener = func(ln net.Listener) net.Listener {
+		return panicListener{Listener: ln}
+	}
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start server: %v", err)
+	}
+
+	// Reaching the select at all means the panic didn't crash the process
+	select {
+	case <-server.Done():
+	case <-time.After(config.ShutdownTimeout):
+		t.Fatal("server did not shut down after the accept goroutine panicked")
+	}
+
+	if _, err := net.DialTimeout("tcp", "localhost:"+config.Port, time.Second); err == nil {
+		t.Error("expected the listener to be closed after shutdown")
+	}
+}
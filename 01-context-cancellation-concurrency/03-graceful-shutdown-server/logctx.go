@@ -0,0 +1,24 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+)
+
+// loggerCtxKey is the context key for the request-scoped logger
+type loggerCtxKey struct{}
+
+// contextWithLogger returns a copy of ctx carrying logger, so every log line
+// written while handling the request picks up its fields automatically
+func contextWithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerCtxKey{}, logger)
+}
+
+// loggerFromContext returns the logger stored by contextWithLogger, or
+// fallback when ctx has none
+func loggerFromContext(ctx context.Context, fallback *slog.Logger) *slog.Logger {
+	if logger, ok := ctx.Value(loggerCtxKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return fallback
+}
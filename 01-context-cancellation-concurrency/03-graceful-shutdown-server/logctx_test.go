@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServer_WorkerLogsCarryRequestFields(t *testing.T) {
+	var logs syncBuffer
+	logger := slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{
+		Level: slog.LevelInfo,
+	}))
+
+	config := Config{
+		Port:            "8095",
+		WorkerPoolSize:  1,
+		RequestTimeout:  5 * time.Second,
+		ShutdownTimeout: 5 * time.Second,
+		Logger:          logger,
+	}
+
+	server := NewServer(config)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start server: %v", err)
+	}
+	defer server.Stop(context.Background())
+	waitReady(t, server)
+
+	req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("http://localhost:%s/orders/7", config.Port), nil)
+	req.Header.Set("X-Request-ID", "trace-123")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	var line string
+	for _, l := range strings.Split(logs.String(), "\n") {
+		if strings.Contains(l, `msg="processing request"`) {
+			line = l
+			break
+		}
+	}
+	if line == "" {
+		t.Fatalf("no worker log line found in:\n%s", logs.String())
+	}
+
+	for _, want := range []string{"request_id=trace-123", "method=GET", "path=/orders/7", "worker_id=0"} {
+		if !strings.Contains(line, want) {
+			t.Errorf("expected worker log line to contain %s, got %q", want, line)
+		}
+	}
+}
+
+func TestLoggerFromContext_Fallback(t *testing.T) {
+	fallback := slog.Default()
+	if got := loggerFromContext(context.Background(), fallback); got != fallback {
+		t.Error("expected fallback logger when the context carries none")
+	}
+
+	scoped := fallback.With("request_id", "req-1")
+	ctx := contextWithLogger(context.Background(), scoped)
+	if got := loggerFromContext(ctx, fallback); got != scoped {
+		t.Error("expected the request-scoped logger from the context")
+	}
+}
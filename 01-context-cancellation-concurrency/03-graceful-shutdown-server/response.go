@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// errorResponse is the body of an error when JSON errors are enabled
+type errorResponse struct {
+	Error string `json:"error"`
+	Code  int    `json:"code"`
+	// RetryAfter is the suggested wait in seconds, mirroring Retry-After
+	RetryAfter int `json:"retry_after_seconds,omitempty"`
+}
+
+// writeError replies with msg and status, as JSON when asJSON is set and as
+// plain text (like http.Error) otherwise
+func writeError(w http.ResponseWriter, asJSON bool, msg string, status int) {
+	writeErrorResponse(w, asJSON, errorResponse{Error: msg, Code: status})
+}
+
+// writeUnavailable replies 503 with retryAfter, rounded up to whole seconds,
+// in the Retry-After header and, for JSON errors, in the body
+func writeUnavailable(w http.ResponseWriter, asJSON bool, msg string, retryAfter time.Duration) {
+	secs := max(int(math.Ceil(retryAfter.Seconds())), 1)
+	w.Header().Set("Retry-After", strconv.Itoa(secs))
+	writeErrorResponse(w, asJSON, errorResponse{
+		Error:      msg,
+		Code:       http.StatusServiceUnavailable,
+		RetryAfter: secs,
+	})
+}
+
+func writeErrorResponse(w http.ResponseWriter, asJSON bool, resp errorResponse) {
+	if !asJSON {
+		http.Error(w, resp.Error, resp.Code)
+		return
+	}
+
+	h := w.Header()
+	// Drop headers that may describe a body we're not sending, as http.Error does
+	h.Del("Content-Length")
+	h.Set("Content-Type", "application/json")
+	h.Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(resp.Code)
+	json.NewEncoder(w).Encode(resp)
+}
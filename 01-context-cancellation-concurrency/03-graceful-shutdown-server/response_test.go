@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestServer_JSONErrorDuringShutdown(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelError,
+	}))
+
+	config := Config{
+		Port:            "8090",
+		WorkerPoolSize:  2,
+		RequestTimeout:  5 * time.Second,
+		ShutdownTimeout: 5 * time.Second,
+		Logger:          logger,
+		JSONErrors:      true,
+	}
+
+	server := NewServer(config)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start server: %v", err)
+	}
+	waitReady(t, server)
+
+	if err := server.Stop(context.Background()); err != nil {
+		t.Fatalf("failed to stop server: %v", err)
+	}
+
+	// The listener is closed now, so drive the handler directly
+	rec := httptest.NewRecorder()
+	server.handleRequest(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503, got %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("expected application/json content type, got %q", ct)
+	}
+
+	var body errorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("response is not valid JSON: %v (%q)", err, rec.Body.String())
+	}
+	if body.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected code 503 in body, got %d", body.Code)
+	}
+	if body.Error == "" {
+		t.Error("expected non-empty error message in body")
+	}
+}
+
+func TestWriteError_PlainText(t *testing.T) {
+	rec := httptest.NewRecorder()
+	writeError(rec, false, "Service unavailable", http.StatusServiceUnavailable)
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503, got %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "text/plain; charset=utf-8" {
+		t.Errorf("expected plain text content type, got %q", ct)
+	}
+}
+
+func TestWorkerPool_RetryAfterTracksBacklog(t *testing.T) {
+	wp := newWorkerPool(2, slog.Default())
+	wp.observeProcessing(500 * time.Millisecond)
+
+	if got, want := wp.retryAfter(), 500*time.Millisecond; got != want {
+		t.Errorf("empty queue: expected %v, got %v", want, got)
+	}
+
+	// 10 waiting requests over 2 workers is 5 rounds ahead of a new one
+	wp.pending.Store(10)
+	if got, want := wp.retryAfter(), 3*time.Second; got != want {
+		t.Errorf("backlog of 10: expected %v, got %v", want, got)
+	}
+
+	rec := httptest.NewRecorder()
+	wp.jsonErrors = true
+	wp.writeUnavailable(rec, "Service unavailable")
+
+	if got := rec.Header().Get("Retry-After"); got != "3" {
+		t.Errorf("expected Retry-After 3, got %q", got)
+	}
+	var body errorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("response is not valid JSON: %v (%q)", err, rec.Body.String())
+	}
+	if body.RetryAfter != 3 {
+		t.Errorf("expected retry_after_seconds 3 in body, got %d", body.RetryAfter)
+	}
+}
+
+func TestServer_RetryAfterReflectsBacklog(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelError,
+	}))
+
+	// One worker and a short queue timeout: while the first request is
+	// processed the rest queue up and are then dropped one after another,
+	// each seeing a smaller backlog than the last
+	config := Config{
+		Port:            "8099",
+		WorkerPoolSize:  1,
+		RequestTimeout:  10 * time.Second,
+		ShutdownTimeout: 5 * time.Second,
+		Logger:          logger,
+		QueueTimeout:    50 * time.Millisecond,
+		JSONErrors:      true,
+	}
+
+	server := NewServer(config)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start server: %v", err)
+	}
+	defer server.Stop(context.Background())
+	waitReady(t, server)
+
+	const numRequests = 30
+	var mu sync.Mutex
+	retryAfters := make(map[int]int)
+	var wg sync.WaitGroup
+	for i := 0; i < numRequests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := http.Get(fmt.Sprintf("http://localhost:%s/", config.Port))
+			if err != nil {
+				t.Errorf("request failed: %v", err)
+				return
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusServiceUnavailable {
+				return
+			}
+
+			var body errorResponse
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Errorf("503 body is not valid JSON: %v", err)
+				return
+			}
+			header, _ := strconv.Atoi(resp.Header.Get("Retry-After"))
+			if header != body.RetryAfter {
+				t.Errorf("Retry-After header %d does not match body %d", header, body.RetryAfter)
+			}
+			mu.Lock()
+			retryAfters[header]++
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if len(retryAfters) == 0 {
+		t.Fatal("expected saturation to produce 503 responses")
+	}
+	longest := 0
+	for secs := range retryAfters {
+		longest = max(longest, secs)
+	}
+	// ~28 requests queued behind one 100ms worker is close to 3s of backlog
+	if longest < 2 {
+		t.Errorf("expected Retry-After to reflect the backlog, saw %v", retryAfters)
+	}
+	if len(retryAfters) < 2 {
+		t.Errorf("expected Retry-After to shrink as the backlog drains, saw %v", retryAfters)
+	}
+}
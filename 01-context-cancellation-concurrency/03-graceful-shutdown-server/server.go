@@ -2,11 +2,16 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net"
 	"net/http"
+	"os"
+	"runtime/debug"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -17,20 +22,177 @@ type Config struct {
 	RequestTimeout  time.Duration
 	ShutdownTimeout time.Duration
 	Logger          *slog.Logger
+
+	// LameDuckPeriod delays the drain at the start of Stop: /readyz starts
+	// failing right away so load balancers stop routing here, but requests
+	// are still served normally until the period ends. It is added to the
+	// ShutdownTimeout, not taken from it. Zero starts draining immediately.
+	LameDuckPeriod time.Duration
+
+	// AdminPort serves operational endpoints on a separate listener.
+	// Leave empty to disable the admin listener.
+	AdminPort string
+	// EnablePprof mounts net/http/pprof handlers on the admin listener
+	EnablePprof bool
+	// PprofUsername and PprofPassword enable basic auth on the pprof
+	// handlers when PprofUsername is set
+	PprofUsername string
+	PprofPassword string
+
+	// Build is served as JSON on /version, on the admin listener if there
+	// is one and otherwise on the main port. The zero value serves nothing.
+	Build BuildInfo
+
+	// QueueSize caps how many requests may wait for a worker in the
+	// default pool. Zero means twice WorkerPoolSize.
+	QueueSize int
+
+	// QueueTimeout bounds how long a request may wait for a worker.
+	// Requests that wait longer are rejected with 503 instead of being
+	// processed late. Zero disables the check.
+	QueueTimeout time.Duration
+
+	// JSONErrors makes error responses {"error": ..., "code": ...} with an
+	// application/json content type instead of plain text
+	JSONErrors bool
+
+	// Pools declares additional named worker pools, so that slow routes
+	// can't starve fast ones of workers. Requests not matched by Routes
+	// use the default pool sized by WorkerPoolSize.
+	Pools []PoolConfig
+	// Routes sends requests matching a pattern to a named pool
+	Routes []Route
+
+	// MaxConnections caps open client connections on the main port,
+	// including idle keep-alive ones. Excess connections are closed as
+	// soon as they are accepted. Zero means no limit.
+	MaxConnections int
+
+	// ResponseWriteTimeout bounds how long a worker may spend writing a
+	// response. Clients that read too slowly to keep up are disconnected
+	// so they can't tie up the worker. Zero disables the check.
+	ResponseWriteTimeout time.Duration
+
+	// IdempotencyTTL enables Idempotency-Key handling: a repeated request
+	// with the same key, method and path within this window gets the first
+	// request's response replayed instead of being processed again, unless
+	// that response was transient (5xx, 408, 429 or Retry-After). Zero
+	// disables it.
+	IdempotencyTTL time.Duration
+
+	// AccessLog logs a line for each completed request. At high request
+	// rates AccessLogSampleRate logs only every Nth request (zero or one
+	// logs all), while requests taking at least AccessLogSlowThreshold are
+	// always logged. A zero threshold disables the slow-request rule.
+	AccessLog              bool
+	AccessLogSampleRate    int
+	AccessLogSlowThreshold time.Duration
+
+	// ShutdownOrder sets the order of the last two shutdown stages. The
+	// zero value stops the cache warmer before closing the database.
+	ShutdownOrder ShutdownOrder
+
+	// UpgradeHandler serves upgrade requests (e.g. WebSockets) on their
+	// hijacked connections, outside the worker pools. Stop closes those
+	// connections after the HTTP server has drained. Nil means upgrade
+	// requests are handled like any other request.
+	UpgradeHandler UpgradeHandler
+
+	// OnDrainProgress, when set, is called with the number of requests
+	// still in flight when Stop starts draining, and then every
+	// DrainProgressInterval (default one second) until the worker pools
+	// have drained. Stop waits for it, so it should return quickly.
+	OnDrainProgress       func(remaining int)
+	DrainProgressInterval time.Duration
+
+	// WarmFunc fills the cache, once at Start and then periodically. Each
+	// run gets a context that is done after WarmTimeout (default ten
+	// seconds) or at shutdown. Nil uses a built-in simulated warm.
+	WarmFunc    WarmFunc
+	WarmTimeout time.Duration
+}
+
+// WarmFunc performs one cache warm. It should return promptly once ctx is
+// done; one that doesn't is abandoned, still running, so the warmer can
+// carry on.
+type WarmFunc func(ctx context.Context) error
+
+// ShutdownOrder chooses whether Stop closes the database before or after
+// waiting for the cache warmer and other background goroutines
+type ShutdownOrder int
+
+const (
+	// StopCacheWarmerFirst waits for the cache warmer before closing the
+	// database, so a warmer that reads from the database can finish
+	StopCacheWarmerFirst ShutdownOrder = iota
+	// CloseDBFirst closes the database while the warmer is still winding
+	// down, for deployments where the warmer must not hold it open
+	CloseDBFirst
+)
+
+// defaultPoolName names the pool sized by Config.WorkerPoolSize
+const defaultPoolName = "default"
+
+// PoolConfig describes a named worker pool
+type PoolConfig struct {
+	Name string
+	Size int
+	// QueueSize caps how many requests may wait for a worker. Zero means
+	// twice Size.
+	QueueSize int
+}
+
+// Route directs requests matching Pattern (http.ServeMux syntax) to the
+// worker pool named Pool. An empty Pool means the default pool.
+type Route struct {
+	Pattern string
+	Pool    string
+	// Timeout overrides Config.RequestTimeout as the request deadline for
+	// this route. Zero means use the global timeout.
+	Timeout time.Duration
+	// Handler serves the route on its pool's workers. Nil means the
+	// default simulated work.
+	Handler HandlerFunc
+	// MaxConcurrent caps how many of this route's requests may be queued
+	// or processing at once; requests past the cap get 429 without taking
+	// a place in the pool. Zero means no limit.
+	MaxConcurrent int
 }
 
+// defaultDrainProgressInterval is how often OnDrainProgress is called when
+// DrainProgressInterval is zero
+const defaultDrainProgressInterval = time.Second
+
+// defaultWarmTimeout bounds each cache warm when WarmTimeout is zero
+const defaultWarmTimeout = 10 * time.Second
+
+// timeoutResponseGrace is how long past a deadline it may take to write
+// the timeout response on routes that outlive the server's WriteTimeout
+const timeoutResponseGrace = time.Second
+
 // Server represents the HTTP server with background workers and cache warmer
 type Server struct {
-	config        Config
-	httpServer    *http.Server
-	workerPool    *workerPool
-	cacheWarmer   *cacheWarmer
-	dbConn        *dbConnection
-	shutdownCh    chan struct{}
-	shutdownOnce  sync.Once
-	rootCtx       context.Context
-	rootCancel    context.CancelFunc
-	wg            sync.WaitGroup
+	config       Config
+	httpServer   *http.Server
+	adminServer  *http.Server
+	workerPool   *workerPool
+	pools        map[string]*workerPool
+	cacheWarmer  *cacheWarmer
+	dbConn       *dbConnection
+	shutdownCh   chan struct{}
+	shutdownOnce sync.Once
+	ready        chan struct{}
+	requestSeq   atomic.Uint64
+	accessSeq    atomic.Uint64
+	inflight     atomic.Int64
+	lameDuck     atomic.Bool
+	upgrades     *upgradeTracker
+	rootCtx      context.Context
+	rootCancel   context.CancelFunc
+	wg           sync.WaitGroup
+
+	// wrapListener, when set, wraps the main listener (used by tests)
+	wrapListener func(net.Listener) net.Listener
 }
 
 // NewServer creates a new Server instance
@@ -38,8 +200,9 @@ func NewServer(config Config) *Server {
 	rootCtx, rootCancel := context.WithCancel(context.Background())
 
 	return &Server{
-		config:     config,
+		config:     config.withDefaults(),
 		shutdownCh: make(chan struct{}),
+		ready:      make(chan struct{}),
 		rootCtx:    rootCtx,
 		rootCancel: rootCancel,
 	}
@@ -47,6 +210,10 @@ func NewServer(config Config) *Server {
 
 // Start starts the server and all background components
 func (s *Server) Start(ctx context.Context) error {
+	if err := s.config.Validate(); err != nil {
+		return err
+	}
+
 	s.config.Logger.Info("starting server",
 		"port", s.config.Port,
 		"worker_pool_size", s.config.WorkerPoolSize,
@@ -58,23 +225,104 @@ func (s *Server) Start(ctx context.Context) error {
 		return fmt.Errorf("failed to connect to database: %w", err)
 	}
 
+	// Bind listeners up front so port errors are reported by Start and
+	// connections are accepted as soon as Start returns
+	ln, err := net.Listen("tcp", ":"+s.config.Port)
+	if err != nil {
+		s.dbConn.close()
+		return fmt.Errorf("failed to listen on port %s: %w", s.config.Port, err)
+	}
+	if s.config.MaxConnections > 0 {
+		ln = newLimitListener(ln, s.config.MaxConnections, s.config.Logger)
+	}
+	if s.wrapListener != nil {
+		ln = s.wrapListener(ln)
+	}
+
+	var adminLn net.Listener
+	if s.config.AdminPort != "" {
+		adminLn, err = net.Listen("tcp", ":"+s.config.AdminPort)
+		if err != nil {
+			ln.Close()
+			s.dbConn.close()
+			return fmt.Errorf("failed to listen on admin port %s: %w", s.config.AdminPort, err)
+		}
+	}
+
 	// Start cache warmer
-	s.cacheWarmer = newCacheWarmer(s.rootCtx, s.config.Logger)
+	s.cacheWarmer = newCacheWarmer(s.rootCtx, s.config.Logger, s.config.WarmFunc, s.config.WarmTimeout)
 	s.wg.Add(1)
 	go s.cacheWarmer.start(&s.wg)
 
-	// Initialize worker pool
-	s.workerPool = newWorkerPool(s.config.WorkerPoolSize, s.config.Logger)
-	s.wg.Add(1)
-	go s.workerPool.start(s.rootCtx, &s.wg)
+	// Initialize worker pools: the default one plus any named pools
+	s.workerPool = s.newPool(defaultPoolName, s.config.WorkerPoolSize, s.config.QueueSize)
+	s.pools = map[string]*workerPool{defaultPoolName: s.workerPool}
+	for _, pc := range s.config.Pools {
+		s.pools[pc.Name] = s.newPool(pc.Name, pc.Size, pc.QueueSize)
+	}
+	for _, wp := range s.pools {
+		s.wg.Add(1)
+		wp.start(s.rootCtx, &s.wg)
+	}
 
 	// Setup HTTP server
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", s.handleRequest)
+	mux.HandleFunc("/healthz", s.handleHealth)
+	mux.HandleFunc("/readyz", s.handleReady)
+	if s.config.Build != (BuildInfo{}) && s.config.AdminPort == "" {
+		mux.HandleFunc("/version", s.handleVersion)
+	}
+	for _, route := range s.config.Routes {
+		wp := s.workerPool
+		if route.Pool != "" {
+			wp = s.pools[route.Pool]
+		}
+		timeout := s.config.RequestTimeout
+		if route.Timeout > 0 {
+			timeout = route.Timeout
+		}
+		handler := route.Handler
+		var sem chan struct{}
+		if route.MaxConcurrent > 0 {
+			sem = make(chan struct{}, route.MaxConcurrent)
+		}
+		mux.HandleFunc(route.Pattern, func(w http.ResponseWriter, r *http.Request) {
+			if sem != nil {
+				select {
+				case sem <- struct{}{}:
+					defer func() { <-sem }()
+				default:
+					writeError(w, s.config.JSONErrors, "Too many concurrent requests for this route", http.StatusTooManyRequests)
+					return
+				}
+			}
+			s.dispatch(w, r, wp, timeout, handler)
+		})
+	}
+
+	var handler http.Handler = mux
+	if s.config.IdempotencyTTL > 0 {
+		store := newIdempotencyStore(s.config.IdempotencyTTL)
+		handler = s.idempotent(handler, store)
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			store.runSweeper(s.rootCtx)
+		}()
+	}
+	if s.config.AccessLog {
+		handler = s.accessLog(handler)
+	}
+	if s.config.UpgradeHandler != nil {
+		s.upgrades = newUpgradeTracker()
+		handler = s.passUpgrades(handler)
+	}
 
 	s.httpServer = &http.Server{
 		Addr:         ":" + s.config.Port,
-		Handler:      mux,
+		Handler:      handler,
 		ReadTimeout:  s.config.RequestTimeout,
 		WriteTimeout: s.config.RequestTimeout,
 		IdleTimeout:  60 * time.Second,
@@ -84,143 +332,464 @@ func (s *Server) Start(ctx context.Context) error {
 	s.wg.Add(1)
 	go func() {
 		defer s.wg.Done()
+		defer s.recoverServe("HTTP server")
 		s.config.Logger.Info("HTTP server listening", "addr", s.httpServer.Addr)
-		if err := s.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		if err := s.httpServer.Serve(ln); err != nil && err != http.ErrServerClosed {
 			s.config.Logger.Error("HTTP server error", "error", err)
 		}
 	}()
 
+	// Start admin server on its own port, if configured
+	if adminLn != nil {
+		s.startAdminServer(adminLn)
+	}
+
+	// Cancelling the Start context shuts the server down, for callers that
+	// manage its lifetime through a context instead of calling Stop. Not
+	// tracked in s.wg, since Stop waits on that.
+	go func() {
+		select {
+		case <-ctx.Done():
+			s.config.Logger.Info("start context cancelled", "error", ctx.Err())
+			if err := s.Stop(context.Background()); err != nil {
+				s.config.Logger.Error("shutdown after context cancellation failed", "error", err)
+			}
+		case <-s.shutdownCh:
+		}
+	}()
+
+	close(s.ready)
 	return nil
 }
 
-// Stop gracefully shuts down the server
+// recoverServe keeps a panic in a Serve goroutine, e.g. from a custom
+// listener's Accept, from crashing the process: the panic is logged and the
+// server shut down in an orderly way instead. It must be deferred directly.
+func (s *Server) recoverServe(name string) {
+	if r := recover(); r != nil {
+		s.config.Logger.Error(name+" panicked, shutting down", "panic", r, "stack", string(debug.Stack()))
+		// Stop waits for this goroutine, so it can't be called from it
+		go func() {
+			if err := s.Stop(context.Background()); err != nil {
+				s.config.Logger.Error("shutdown after panic failed", "error", err)
+			}
+		}()
+	}
+}
+
+// validateRoutes checks that pool names are unique and every route refers
+// to a declared pool
+func (c Config) validateRoutes() error {
+	names := map[string]bool{defaultPoolName: true}
+	for _, pc := range c.Pools {
+		if names[pc.Name] {
+			return fmt.Errorf("duplicate worker pool name %q", pc.Name)
+		}
+		if pc.Size < 1 {
+			return fmt.Errorf("worker pool %q: size must be at least 1", pc.Name)
+		}
+		if pc.QueueSize < 0 {
+			return fmt.Errorf("worker pool %q: queue size must not be negative", pc.Name)
+		}
+		names[pc.Name] = true
+	}
+	for _, route := range c.Routes {
+		if route.Pool != "" && !names[route.Pool] {
+			return fmt.Errorf("route %q: unknown worker pool %q", route.Pattern, route.Pool)
+		}
+		if route.MaxConcurrent < 0 {
+			return fmt.Errorf("route %q: max concurrent must not be negative", route.Pattern)
+		}
+	}
+	return nil
+}
+
+// newPool creates a worker pool configured from the server config
+func (s *Server) newPool(name string, size, queueSize int) *workerPool {
+	wp := newQueuedWorkerPool(size, queueSize, s.config.Logger)
+	wp.name = name
+	wp.queueTimeout = s.config.QueueTimeout
+	wp.jsonErrors = s.config.JSONErrors
+	wp.writeTimeout = s.config.ResponseWriteTimeout
+	return wp
+}
+
+// WaitReady blocks until the server is accepting connections, the database
+// is connected and the initial cache warm has completed, or ctx is done
+func (s *Server) WaitReady(ctx context.Context) error {
+	select {
+	case <-s.ready:
+	case <-ctx.Done():
+		return fmt.Errorf("server not ready: %w", ctx.Err())
+	}
+
+	// The cache warmer is set by Start before ready is closed
+	select {
+	case <-s.cacheWarmer.warmed:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("server not ready: %w", ctx.Err())
+	}
+}
+
+// Config returns a copy of the configuration in effect, with defaults
+// filled in for the optional fields that were left unset
+func (s *Server) Config() Config {
+	config := s.config
+	config.Pools = slices.Clone(config.Pools)
+	config.Routes = slices.Clone(config.Routes)
+	return config
+}
+
+// Done returns a channel that is closed once the server has shut down,
+// whether through Stop or cancellation of the Start context
+func (s *Server) Done() <-chan struct{} {
+	return s.shutdownCh
+}
+
+// Stop gracefully shuts down the server. The returned error joins the
+// errors of every shutdown stage that failed, so each can be checked with
+// errors.Is.
 func (s *Server) Stop(ctx context.Context) error {
-	var shutdownErr error
+	var errs []error
 
 	s.shutdownOnce.Do(func() {
 		s.config.Logger.Info("shutting down server")
 
+		// Lame duck: report not ready but keep serving, giving load
+		// balancers time to notice before connections are drained
+		s.lameDuck.Store(true)
+		if s.config.LameDuckPeriod > 0 {
+			s.config.Logger.Info("entering lame-duck period", "duration", s.config.LameDuckPeriod)
+			select {
+			case <-time.After(s.config.LameDuckPeriod):
+			case <-ctx.Done():
+				s.config.Logger.Warn("lame-duck period cut short", "error", ctx.Err())
+			}
+		}
+
+		// Tell keep-alive clients to close their connections after the
+		// current response instead of sending more requests during the drain
+		s.httpServer.SetKeepAlivesEnabled(false)
+
 		// Cancel root context to signal all goroutines
 		s.rootCancel()
 
+		// Report drain progress until the worker pools have drained
+		stopProgress := func() {}
+		if s.config.OnDrainProgress != nil {
+			drained := make(chan struct{})
+			reporterDone := make(chan struct{})
+			go func() {
+				defer close(reporterDone)
+				s.reportDrainProgress(drained)
+			}()
+			stopProgress = func() {
+				close(drained)
+				<-reporterDone
+			}
+		}
+
 		// Step 1: Stop accepting new requests
 		shutdownCtx, cancel := context.WithTimeout(ctx, s.config.ShutdownTimeout)
 		defer cancel()
 
 		if err := s.httpServer.Shutdown(shutdownCtx); err != nil {
 			s.config.Logger.Error("HTTP server shutdown error", "error", err)
-			shutdownErr = fmt.Errorf("HTTP server shutdown: %w", err)
+			errs = append(errs, fmt.Errorf("HTTP server shutdown: %w", err))
 		} else {
 			s.config.Logger.Info("HTTP server stopped accepting new requests")
 		}
 
-		// Step 2: Drain worker pool (wait for in-flight requests)
-		if err := s.workerPool.stop(shutdownCtx); err != nil {
-			s.config.Logger.Error("worker pool shutdown error", "error", err)
-			if shutdownErr == nil {
-				shutdownErr = fmt.Errorf("worker pool shutdown: %w", err)
+		// Shutdown doesn't wait for hijacked connections, so close them
+		// here instead of leaving them open past the drain
+		if s.upgrades != nil {
+			if err := s.upgrades.closeAll(shutdownCtx); err != nil {
+				s.config.Logger.Error("upgraded connections shutdown error", "error", err)
+				errs = append(errs, err)
+			} else {
+				s.config.Logger.Info("upgraded connections closed")
+			}
+		}
+
+		if s.adminServer != nil {
+			if err := s.adminServer.Shutdown(shutdownCtx); err != nil {
+				s.config.Logger.Error("admin server shutdown error", "error", err)
+				errs = append(errs, fmt.Errorf("admin server shutdown: %w", err))
+			} else {
+				s.config.Logger.Info("admin server stopped")
+			}
+		}
+
+		// Step 2: Drain worker pools (wait for in-flight requests)
+		for name, wp := range s.pools {
+			if err := wp.stop(shutdownCtx); err != nil {
+				s.config.Logger.Error("worker pool shutdown error", "pool", name, "error", err)
+				errs = append(errs, fmt.Errorf("worker pool %s shutdown: %w", name, err))
+			} else {
+				s.config.Logger.Info("worker pool drained", "pool", name)
 			}
-		} else {
-			s.config.Logger.Info("worker pool drained")
 		}
+		stopProgress()
 
 		// Step 3: Wait for cache warmer to finish (stopped via context cancellation)
 		// The cache warmer goroutine is tracked in s.wg, so we need to wait for it
 		// along with the HTTP server goroutine
-		done := make(chan struct{})
-		go func() {
-			s.wg.Wait()
-			close(done)
-		}()
+		waitForGoroutines := func() {
+			done := make(chan struct{})
+			go func() {
+				s.wg.Wait()
+				close(done)
+			}()
 
-		select {
-		case <-done:
-			s.config.Logger.Info("cache warmer and all goroutines finished")
-		case <-shutdownCtx.Done():
-			s.config.Logger.Warn("shutdown timeout exceeded while waiting for goroutines")
-			if shutdownErr == nil {
-				shutdownErr = fmt.Errorf("shutdown timeout exceeded")
+			select {
+			case <-done:
+				s.config.Logger.Info("cache warmer and all goroutines finished")
+			case <-shutdownCtx.Done():
+				s.config.Logger.Warn("shutdown timeout exceeded while waiting for goroutines")
+				errs = append(errs, fmt.Errorf("shutdown timeout exceeded: %w", shutdownCtx.Err()))
 			}
 		}
 
-		// Step 4: Close database connection (after all goroutines finished)
-		if err := s.dbConn.close(); err != nil {
-			s.config.Logger.Error("database close error", "error", err)
-			if shutdownErr == nil {
-				shutdownErr = fmt.Errorf("database close: %w", err)
+		// Step 4: Close database connection
+		closeDB := func() {
+			if err := s.dbConn.close(); err != nil {
+				s.config.Logger.Error("database close error", "error", err)
+				errs = append(errs, fmt.Errorf("database close: %w", err))
+			} else {
+				s.config.Logger.Info("database connection closed")
 			}
+		}
+
+		// By default the database outlives every goroutine that might use it
+		if s.config.ShutdownOrder == CloseDBFirst {
+			closeDB()
+			waitForGoroutines()
 		} else {
-			s.config.Logger.Info("database connection closed")
+			waitForGoroutines()
+			closeDB()
 		}
 
 		close(s.shutdownCh)
 		s.config.Logger.Info("server shutdown complete")
 	})
 
-	return shutdownErr
+	return errors.Join(errs...)
+}
+
+// reportDrainProgress calls OnDrainProgress with the in-flight request
+// count right away and then on every interval, until drained is closed
+func (s *Server) reportDrainProgress(drained <-chan struct{}) {
+	ticker := time.NewTicker(s.config.DrainProgressInterval)
+	defer ticker.Stop()
+
+	for {
+		s.config.OnDrainProgress(int(s.inflight.Load()))
+		select {
+		case <-ticker.C:
+		case <-drained:
+			return
+		}
+	}
+}
+
+// handleReady reports whether the server should be sent traffic: not until
+// the initial cache warm has completed, and not once shutdown has begun.
+// Unlike /healthz a failure here doesn't mean the server is broken.
+func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
+	select {
+	case <-s.cacheWarmer.warmed:
+	default:
+		writeError(w, s.config.JSONErrors, "Cache warming", http.StatusServiceUnavailable)
+		return
+	}
+	if s.lameDuck.Load() {
+		writeError(w, s.config.JSONErrors, "Server is shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintln(w, "ok")
+}
+
+// healthCheckTimeout bounds the dependency checks made by /healthz
+const healthCheckTimeout = time.Second
+
+// handleHealth reports whether the server can serve requests. It runs on
+// the HTTP goroutine rather than a worker, so a saturated pool doesn't make
+// the server look unhealthy.
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+	defer cancel()
+
+	if err := s.dbConn.ping(ctx); err != nil {
+		s.config.Logger.Warn("health check failed", "check", "database", "error", err)
+		writeError(w, s.config.JSONErrors, "Database unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintln(w, "ok")
 }
 
-// handleRequest handles incoming HTTP requests
+// handleRequest handles incoming HTTP requests on the default worker pool
 func (s *Server) handleRequest(w http.ResponseWriter, r *http.Request) {
+	s.dispatch(w, r, s.workerPool, s.config.RequestTimeout, nil)
+}
+
+// dispatch hands a request to wp and waits for its response. A positive
+// timeout becomes the request context deadline, covering queueing and work.
+// A nil handler means the default simulated work.
+func (s *Server) dispatch(w http.ResponseWriter, r *http.Request, wp *workerPool, timeout time.Duration, handler HandlerFunc) {
 	// Check if server is shutting down
 	select {
 	case <-s.shutdownCh:
-		http.Error(w, "Server is shutting down", http.StatusServiceUnavailable)
+		wp.writeUnavailable(w, "Server is shutting down")
 		return
 	default:
 	}
 
+	// Counted until the response is written, for drain progress reports
+	s.inflight.Add(1)
+	defer s.inflight.Add(-1)
+
+	// Reuse the caller's request ID when given so logs correlate across hops
+	requestID := r.Header.Get("X-Request-ID")
+	if requestID == "" {
+		requestID = fmt.Sprintf("req-%d", s.requestSeq.Add(1))
+	}
+	w.Header().Set("X-Request-ID", requestID)
+
+	// Everything that logs on behalf of this request shares these fields
+	logger := s.config.Logger.With(
+		"request_id", requestID,
+		"method", r.Method,
+		"path", r.URL.Path,
+	)
+	r = r.WithContext(contextWithLogger(r.Context(), logger))
+
+	if timeout > 0 {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		r = r.WithContext(ctx)
+
+		// The server-wide WriteTimeout would cut off a route allowed to run
+		// longer, so push the connection's write deadline out to match
+		if timeout > s.config.RequestTimeout {
+			deadline := time.Now().Add(timeout + timeoutResponseGrace)
+			if err := http.NewResponseController(w).SetWriteDeadline(deadline); err != nil {
+				logger.Debug("could not extend write deadline", "error", err)
+			}
+		}
+	}
+
 	// Submit request to worker pool
 	req := &request{
-		w: w,
-		r: r,
+		id:      requestID,
+		w:       w,
+		r:       r,
+		handler: handler,
+		done:    make(chan struct{}),
 	}
 
-	if err := s.workerPool.submit(s.rootCtx, req); err != nil {
-		http.Error(w, "Service unavailable", http.StatusServiceUnavailable)
+	if err := wp.submit(s.rootCtx, req); err != nil {
+		wp.writeUnavailable(w, "Service unavailable")
 		return
 	}
+
+	// The ResponseWriter must not be used after the handler returns, so wait
+	// until the worker (or the pool's shutdown path) has written the response
+	<-req.done
 }
 
 // request represents an HTTP request to be processed
 type request struct {
-	w http.ResponseWriter
-	r *http.Request
+	id         string
+	w          http.ResponseWriter
+	r          *http.Request
+	handler    HandlerFunc
+	enqueuedAt time.Time
+	done       chan struct{}
+}
+
+// finish signals the submitting handler that the response has been written
+func (req *request) finish() {
+	if req.done != nil {
+		close(req.done)
+	}
 }
 
 // workerPool manages a pool of worker goroutines
 type workerPool struct {
-	size      int
-	workers   int
-	requestCh chan *request
-	stopCh    chan struct{}
-	logger    *slog.Logger
-	wg        sync.WaitGroup
-	mu        sync.Mutex
+	name         string
+	size         int
+	workers      int
+	queueTimeout time.Duration
+	writeTimeout time.Duration
+	jsonErrors   bool
+	requestCh    chan *request
+	stopCh       chan struct{}
+	closing      chan struct{}
+	sendMu       sync.RWMutex
+	closed       bool
+	stopOnce     sync.Once
+	logger       *slog.Logger
+	wg           sync.WaitGroup
+	mu           sync.Mutex
+
+	// workCtx is what workers process requests under. Stopping the pool
+	// only stops it accepting, so queued and in-flight work still finish;
+	// abort cancels workCtx once a stop gives up waiting for them.
+	workCtx context.Context
+	abort   context.CancelFunc
+
+	// pending counts submitted requests not yet taken by a worker,
+	// including submitters still waiting for queue space
+	pending atomic.Int64
+	// avgProcessing is a moving average of processing time in nanoseconds
+	avgProcessing atomic.Int64
 }
 
 func newWorkerPool(size int, logger *slog.Logger) *workerPool {
+	return newQueuedWorkerPool(size, size*queueSizePerWorker, logger)
+}
+
+// newQueuedWorkerPool creates a pool whose queue holds queueSize requests
+func newQueuedWorkerPool(size, queueSize int, logger *slog.Logger) *workerPool {
+	workCtx, abort := context.WithCancel(context.Background())
 	return &workerPool{
 		size:      size,
-		requestCh: make(chan *request, size*2), // Buffer for better throughput
+		requestCh: make(chan *request, queueSize),
 		stopCh:    make(chan struct{}),
+		closing:   make(chan struct{}),
 		logger:    logger,
+		workCtx:   workCtx,
+		abort:     abort,
 	}
 }
 
+// start launches the workers and returns once they are running, so a stop
+// issued right after can't race their wg.Add or find the pool already
+// drained. The pool then runs in the background until ctx is done or stop
+// is called, and calls wg.Done once its workers have finished the queue.
 func (wp *workerPool) start(ctx context.Context, wg *sync.WaitGroup) {
-	defer wg.Done()
-
 	// Start worker goroutines
 	wp.mu.Lock()
 	for i := 0; i < wp.size; i++ {
 		wp.workers++
 		wp.wg.Add(1)
-		go wp.worker(ctx, i)
+		go wp.worker(wp.workCtx, i)
 	}
 	wp.mu.Unlock()
 
-	wp.logger.Info("worker pool started", "workers", wp.size)
+	wp.logger.Info("worker pool started", "pool", wp.name, "workers", wp.size)
+
+	go wp.run(ctx, wg)
+}
+
+// run waits for ctx to be done or stop to be called, then stops accepting
+// requests and waits for the workers to finish the queue
+func (wp *workerPool) run(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+	defer wp.abort()
 
 	// Wait for context cancellation or stop signal
 	select {
@@ -230,11 +799,23 @@ func (wp *workerPool) start(ctx context.Context, wg *sync.WaitGroup) {
 		wp.logger.Info("worker pool stop signal received")
 	}
 
-	// Close request channel to signal workers to stop
+	// Close request channel to signal workers to stop once they have
+	// emptied it. Submitters send under sendMu's read lock, so wake any
+	// that are waiting for queue space and take the write lock before
+	// closing.
+	close(wp.closing)
+	wp.sendMu.Lock()
+	wp.closed = true
 	close(wp.requestCh)
+	wp.sendMu.Unlock()
 
 	// Wait for all workers to finish
 	wp.wg.Wait()
+
+	// Reject anything still queued, left behind if the drain was aborted,
+	// so those handlers aren't left waiting for a response that will never
+	// be written
+	wp.rejectQueued()
 	wp.logger.Info("all workers finished")
 }
 
@@ -253,6 +834,7 @@ func (wp *workerPool) worker(ctx context.Context, id int) {
 				wp.logger.Debug("request channel closed", "id", id)
 				return
 			}
+			wp.pending.Add(-1)
 			wp.processRequest(ctx, req, id)
 		}
 	}
@@ -260,25 +842,38 @@ func (wp *workerPool) worker(ctx context.Context, id int) {
 
 func (wp *workerPool) processRequest(ctx context.Context, req *request, workerID int) {
 	// Handle nil request (for testing)
-	if req == nil || req.r == nil {
+	if req == nil {
 		wp.logger.Debug("skipping nil request", "worker_id", workerID)
 		return
 	}
+	defer req.finish()
+	if req.r == nil {
+		wp.logger.Debug("skipping nil request", "worker_id", workerID)
+		return
+	}
+
+	// Requests submitted by dispatch carry a logger with their request
+	// fields; others (e.g. in tests) get the request ID added here
+	logger := loggerFromContext(req.r.Context(), wp.logger.With("request_id", req.id)).
+		With("worker_id", workerID)
 
-	// Simulate request processing
-	path := "/"
-	if req.r.URL != nil {
-		path = req.r.URL.Path
+	// Drop requests that waited too long for a worker; the client has
+	// likely given up and answering late only wastes capacity
+	if wp.queueTimeout > 0 {
+		if waited := time.Since(req.enqueuedAt); waited > wp.queueTimeout {
+			logger.Warn("dropping stale request", "queued_for", waited)
+			if req.w != nil {
+				wp.writeUnavailable(req.w, "Request timed out in queue")
+			}
+			return
+		}
 	}
-	wp.logger.Info("processing request",
-		"worker_id", workerID,
-		"path", path,
-		"method", req.r.Method,
-	)
+
+	logger.Info("processing request")
 
 	// Handle nil response writer (for testing)
 	if req.w == nil {
-		wp.logger.Debug("skipping response (nil writer)", "worker_id", workerID)
+		logger.Debug("skipping response (nil writer)")
 		// Simulate work even without response writer
 		select {
 		case <-time.After(100 * time.Millisecond):
@@ -287,30 +882,156 @@ func (wp *workerPool) processRequest(ctx context.Context, req *request, workerID
 		return
 	}
 
+	if req.handler != nil {
+		wp.runHandler(req, logger)
+		return
+	}
+
 	// Simulate some work
+	workStart := time.Now()
 	select {
 	case <-time.After(100 * time.Millisecond):
+		wp.observeProcessing(time.Since(workStart))
+		wp.setWriteDeadline(req.w, logger)
 		req.w.WriteHeader(http.StatusOK)
-		req.w.Write([]byte(fmt.Sprintf("OK - processed by worker %d\n", workerID)))
+		body := fmt.Sprintf("OK - processed by worker %d\n", workerID)
+		if err := writeAndFlush(req.w, []byte(body)); errors.Is(err, os.ErrDeadlineExceeded) {
+			// The client isn't reading fast enough; the failed write marks
+			// the connection broken so net/http closes it
+			logger.Warn("slow consumer disconnected", "write_timeout", wp.writeTimeout, "error", err)
+		} else if err != nil {
+			// The connection is gone (client hung up or WriteTimeout fired).
+			// Nobody is left to answer, so give the worker back right away.
+			logger.Warn("response write failed", "error", err)
+		}
+	case <-req.r.Context().Done():
+		if errors.Is(req.r.Context().Err(), context.DeadlineExceeded) {
+			logger.Warn("request deadline exceeded")
+			writeError(req.w, wp.jsonErrors, "Request timed out", http.StatusGatewayTimeout)
+			return
+		}
+		// net/http cancels the request context when the client disconnects
+		// or the connection times out; writing now would only fail
+		logger.Warn("client gone before response was written", "error", req.r.Context().Err())
 	case <-ctx.Done():
-		http.Error(req.w, "Request cancelled", http.StatusRequestTimeout)
+		writeError(req.w, wp.jsonErrors, "Request cancelled", http.StatusRequestTimeout)
+		return
+	}
+}
+
+// setWriteDeadline limits how long writing the response may block on a slow
+// client, when a write timeout is configured
+func (wp *workerPool) setWriteDeadline(w http.ResponseWriter, logger *slog.Logger) {
+	if wp.writeTimeout <= 0 {
 		return
 	}
+	err := http.NewResponseController(w).SetWriteDeadline(time.Now().Add(wp.writeTimeout))
+	if err != nil && !errors.Is(err, http.ErrNotSupported) {
+		logger.Debug("could not set write deadline", "error", err)
+	}
+}
+
+// writeAndFlush writes body and flushes it to the connection so that a dead
+// connection is reported here rather than silently buffered
+func writeAndFlush(w http.ResponseWriter, body []byte) error {
+	if _, err := w.Write(body); err != nil {
+		return err
+	}
+	if err := http.NewResponseController(w).Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	return nil
 }
 
 func (wp *workerPool) submit(ctx context.Context, req *request) error {
+	// Queue time includes any wait for buffer space
+	req.enqueuedAt = time.Now()
+	wp.pending.Add(1)
+
+	// Holding the read lock keeps start from closing requestCh mid-send
+	wp.sendMu.RLock()
+	defer wp.sendMu.RUnlock()
+	if wp.closed {
+		wp.pending.Add(-1)
+		return errPoolShuttingDown
+	}
+
 	select {
 	case <-ctx.Done():
+		wp.pending.Add(-1)
 		return ctx.Err()
 	case <-wp.stopCh:
-		return fmt.Errorf("worker pool is shutting down")
+		wp.pending.Add(-1)
+		return errPoolShuttingDown
+	case <-wp.closing:
+		wp.pending.Add(-1)
+		return errPoolShuttingDown
 	case wp.requestCh <- req:
 		return nil
 	}
 }
 
+// errPoolShuttingDown is returned by submit once the pool has begun stopping
+var errPoolShuttingDown = errors.New("worker pool is shutting down")
+
+// defaultProcessingEstimate stands in for the average processing time until
+// the pool has completed a request
+const defaultProcessingEstimate = 100 * time.Millisecond
+
+// observeProcessing folds d into the moving average of processing time
+func (wp *workerPool) observeProcessing(d time.Duration) {
+	for {
+		prev := wp.avgProcessing.Load()
+		next := int64(d)
+		if prev != 0 {
+			// Exponentially weighted, favouring history 4:1
+			next = prev + (int64(d)-prev)/5
+		}
+		if wp.avgProcessing.CompareAndSwap(prev, next) {
+			return
+		}
+	}
+}
+
+// retryAfter estimates how long until a new request would be served: the
+// backlog spread across the workers, plus the request itself, at the
+// average processing time
+func (wp *workerPool) retryAfter() time.Duration {
+	avg := time.Duration(wp.avgProcessing.Load())
+	if avg == 0 {
+		avg = defaultProcessingEstimate
+	}
+	backlog := float64(max(wp.pending.Load(), 0)) / float64(max(wp.size, 1))
+	return time.Duration(float64(avg) * (backlog + 1))
+}
+
+// writeUnavailable replies 503 with the pool's current Retry-After estimate
+func (wp *workerPool) writeUnavailable(w http.ResponseWriter, msg string) {
+	writeUnavailable(w, wp.jsonErrors, msg, wp.retryAfter())
+}
+
+// reject answers a request that will not be processed with a 503
+func (wp *workerPool) reject(req *request, msg string) {
+	if req == nil {
+		return
+	}
+	if req.w != nil {
+		wp.writeUnavailable(req.w, msg)
+	}
+	req.finish()
+}
+
+// stop signals the pool to shut down and waits for workers to finish.
+// Only the first call does any work; later calls return nil immediately.
 func (wp *workerPool) stop(ctx context.Context) error {
-	close(wp.stopCh)
+	stopping := false
+	wp.stopOnce.Do(func() {
+		close(wp.stopCh)
+		stopping = true
+	})
+	if !stopping {
+		return nil
+	}
 
 	// Wait for workers to finish with timeout
 	done := make(chan struct{})
@@ -323,22 +1044,60 @@ func (wp *workerPool) stop(ctx context.Context) error {
 	case <-done:
 		return nil
 	case <-ctx.Done():
+		// Workers are stuck, so start won't get to drain the queue; cancel
+		// their work and answer the waiting requests now instead of
+		// abandoning their connections
+		wp.abort()
+		if n := wp.rejectQueued(); n > 0 {
+			wp.logger.Warn("rejected queued requests after stop timeout", "pool", wp.name, "count", n)
+		}
 		return fmt.Errorf("worker pool stop timeout: %w", ctx.Err())
 	}
 }
 
+// rejectQueued answers every request currently waiting in the queue with a
+// 503 and returns how many there were. It never blocks, so it is safe to
+// call whether or not requestCh has been closed.
+func (wp *workerPool) rejectQueued() int {
+	n := 0
+	for {
+		select {
+		case req, ok := <-wp.requestCh:
+			if !ok {
+				return n
+			}
+			wp.pending.Add(-1)
+			wp.reject(req, "Service unavailable")
+			n++
+		default:
+			return n
+		}
+	}
+}
+
 // cacheWarmer runs background cache warming tasks
 type cacheWarmer struct {
-	ctx    context.Context
-	ticker *time.Ticker
-	logger *slog.Logger
+	ctx     context.Context
+	ticker  *time.Ticker
+	logger  *slog.Logger
+	warm    WarmFunc
+	timeout time.Duration
+	warmed  chan struct{} // closed after the initial warm completes
 }
 
-func newCacheWarmer(ctx context.Context, logger *slog.Logger) *cacheWarmer {
+// newCacheWarmer creates a warmer that runs warm, or simulateWarm if it is
+// nil, with each run bounded by timeout
+func newCacheWarmer(ctx context.Context, logger *slog.Logger, warm WarmFunc, timeout time.Duration) *cacheWarmer {
+	if warm == nil {
+		warm = simulateWarm
+	}
 	return &cacheWarmer{
-		ctx:    ctx,
-		ticker: time.NewTicker(30 * time.Second),
-		logger: logger,
+		ctx:     ctx,
+		ticker:  time.NewTicker(30 * time.Second),
+		logger:  logger,
+		warm:    warm,
+		timeout: timeout,
+		warmed:  make(chan struct{}),
 	}
 }
 
@@ -348,6 +1107,12 @@ func (cw *cacheWarmer) start(wg *sync.WaitGroup) {
 
 	cw.logger.Info("cache warmer started")
 
+	// Warm once up front so the server isn't reported ready with a cold
+	// cache. A failed or timed out warm still makes it ready, cold, rather
+	// than never.
+	cw.warmCache()
+	close(cw.warmed)
+
 	for {
 		select {
 		case <-cw.ctx.Done():
@@ -359,11 +1124,42 @@ func (cw *cacheWarmer) start(wg *sync.WaitGroup) {
 	}
 }
 
+// warmCache runs one warm, waiting no longer than the timeout or until
+// shutdown. The warm runs on its own goroutine so one that ignores its
+// context can't block the warmer.
 func (cw *cacheWarmer) warmCache() {
 	cw.logger.Info("warming cache")
-	// Simulate cache warming work
-	time.Sleep(100 * time.Millisecond)
-	cw.logger.Info("cache warmed")
+
+	ctx, cancel := context.WithTimeout(cw.ctx, cw.timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- cw.warm(ctx) }()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			cw.logger.Error("cache warm failed", "error", err)
+			return
+		}
+		cw.logger.Info("cache warmed")
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			cw.logger.Error("cache warm timed out", "timeout", cw.timeout)
+		} else {
+			cw.logger.Info("cache warm cancelled")
+		}
+	}
+}
+
+// simulateWarm stands in for real cache warming work
+func simulateWarm(ctx context.Context) error {
+	select {
+	case <-time.After(100 * time.Millisecond):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
 // dbConnection represents a database connection pool
@@ -408,3 +1204,21 @@ func (db *dbConnection) close() error {
 	return nil
 }
 
+// errDBNotConnected is returned by ping when there is no open connection
+var errDBNotConnected = errors.New("database not connected")
+
+// ping checks that the connection is usable. The mock has nothing to round
+// trip to, so it only checks that the connection is still open.
+func (db *dbConnection) ping(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.conn == nil {
+		return errDBNotConnected
+	}
+	return nil
+}
@@ -1,12 +1,19 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"net"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"runtime"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -34,8 +41,7 @@ func TestServer_GracefulShutdown(t *testing.T) {
 		t.Fatalf("failed to start server: %v", err)
 	}
 
-	// Give server time to start
-	time.Sleep(100 * time.Millisecond)
+	waitReady(t, server)
 
 	// Send multiple requests
 	var wg sync.WaitGroup
@@ -97,7 +103,7 @@ func TestServer_ShutdownTimeout(t *testing.T) {
 		t.Fatalf("failed to start server: %v", err)
 	}
 
-	time.Sleep(100 * time.Millisecond)
+	waitReady(t, server)
 
 	// Create a context with shorter timeout than shutdown timeout
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
@@ -147,7 +153,10 @@ func TestServer_NoGoroutineLeak(t *testing.T) {
 	// Send some requests
 	for i := 0; i < 5; i++ {
 		go func() {
-			http.Get(fmt.Sprintf("http://localhost:%s/", config.Port))
+			resp, err := http.Get(fmt.Sprintf("http://localhost:%s/", config.Port))
+			if err == nil {
+				resp.Body.Close()
+			}
 		}()
 		time.Sleep(100 * time.Millisecond)
 	}
@@ -187,7 +196,7 @@ func TestWorkerPool_ChannelCoordination(t *testing.T) {
 
 	var wg sync.WaitGroup
 	wg.Add(1)
-	go wp.start(ctx, &wg)
+	wp.start(ctx, &wg)
 
 	// Submit some requests
 	for i := 0; i < 5; i++ {
@@ -221,7 +230,7 @@ func TestCacheWarmer_TickerCleanup(t *testing.T) {
 	}))
 
 	ctx, cancel := context.WithCancel(context.Background())
-	cw := newCacheWarmer(ctx, logger)
+	cw := newCacheWarmer(ctx, logger, nil, defaultWarmTimeout)
 
 	var wg sync.WaitGroup
 	wg.Add(1)
@@ -248,3 +257,1122 @@ func TestCacheWarmer_TickerCleanup(t *testing.T) {
 	}
 }
 
+func TestCacheWarmer_WarmTimeout(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelError,
+	}))
+
+	// Hangs regardless of its context, like a warm stuck on a dead backend
+	release := make(chan struct{})
+	defer close(release)
+	warmCtx := make(chan context.Context, 1)
+	warm := func(ctx context.Context) error {
+		warmCtx <- ctx
+		<-release
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cw := newCacheWarmer(ctx, logger, warm, 50*time.Millisecond)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go cw.start(&wg)
+
+	select {
+	case <-cw.warmed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the initial warm to give up after the warm timeout")
+	}
+	if err := (<-warmCtx).Err(); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the warm's context to have timed out, got %v", err)
+	}
+
+	cancel()
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Error("expected the cache warmer to stop despite the hung warm")
+	}
+}
+
+
+func TestServer_QueueTimeoutRejectsStaleRequests(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelError,
+	}))
+
+	config := Config{
+		Port:            "8088",
+		WorkerPoolSize:  1, // Single worker so requests queue behind each other
+		RequestTimeout:  5 * time.Second,
+		ShutdownTimeout: 5 * time.Second,
+		Logger:          logger,
+		QueueTimeout:    50 * time.Millisecond, // Shorter than the 100ms processing time
+	}
+
+	server := NewServer(config)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start server: %v", err)
+	}
+	defer server.Stop(context.Background())
+
+	waitReady(t, server)
+
+	// Saturate the pool: one request is processed, the rest wait in the queue
+	const requestCount = 4
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	statuses := make(map[int]int)
+
+	for i := 0; i < requestCount; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := http.Get(fmt.Sprintf("http://localhost:%s/", config.Port))
+			if err != nil {
+				t.Errorf("request failed: %v", err)
+				return
+			}
+			resp.Body.Close()
+			mu.Lock()
+			statuses[resp.StatusCode]++
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if statuses[http.StatusOK] < 1 {
+		t.Errorf("expected at least one request to be processed, got statuses %v", statuses)
+	}
+	if statuses[http.StatusServiceUnavailable] < 2 {
+		t.Errorf("expected stale queued requests to get 503, got statuses %v", statuses)
+	}
+	if statuses[http.StatusOK]+statuses[http.StatusServiceUnavailable] != requestCount {
+		t.Errorf("unexpected statuses: %v", statuses)
+	}
+}
+
+func TestWorkerPool_StopIdempotent(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelError,
+	}))
+
+	wp := newWorkerPool(2, logger)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	wp.start(ctx, &wg)
+
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer shutdownCancel()
+
+	defer func() {
+		if r := recover(); r != nil {
+			t.Fatalf("stop panicked on second call: %v", r)
+		}
+	}()
+
+	if err := wp.stop(shutdownCtx); err != nil {
+		t.Errorf("first stop returned error: %v", err)
+	}
+	if err := wp.stop(shutdownCtx); err != nil {
+		t.Errorf("second stop returned error: %v", err)
+	}
+
+	wg.Wait()
+}
+
+// waitReady blocks until server reports ready, failing the test otherwise
+func waitReady(t *testing.T, server *Server) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.WaitReady(ctx); err != nil {
+		t.Fatalf("server did not become ready: %v", err)
+	}
+}
+
+func TestServer_WaitReady(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelError,
+	}))
+
+	config := Config{
+		Port:            "8089",
+		WorkerPoolSize:  2,
+		RequestTimeout:  5 * time.Second,
+		ShutdownTimeout: 5 * time.Second,
+		Logger:          logger,
+	}
+
+	server := NewServer(config)
+
+	// Not ready before Start
+	notReadyCtx, notReadyCancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer notReadyCancel()
+	if err := server.WaitReady(notReadyCtx); err == nil {
+		t.Fatal("expected WaitReady to fail before Start")
+	}
+
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start server: %v", err)
+	}
+	defer server.Stop(context.Background())
+
+	waitReady(t, server)
+
+	// No sleep: the first request must succeed once WaitReady returns
+	resp, err := http.Get(fmt.Sprintf("http://localhost:%s/", config.Port))
+	if err != nil {
+		t.Fatalf("request after WaitReady failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected 200 after WaitReady, got %d", resp.StatusCode)
+	}
+}
+
+// syncBuffer is a goroutine-safe buffer for capturing log output
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestWorkerPool_ClientDisconnectFreesWorker(t *testing.T) {
+	var logs syncBuffer
+	logger := slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{
+		Level: slog.LevelWarn,
+	}))
+
+	wp := newWorkerPool(1, logger)
+
+	// Simulate a client that hangs up shortly after sending its request
+	reqCtx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	r := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(reqCtx)
+	req := &request{id: "req-early-close", w: httptest.NewRecorder(), r: r}
+
+	start := time.Now()
+	wp.processRequest(context.Background(), req, 0)
+	elapsed := time.Since(start)
+
+	// Processing takes 100ms; the worker must give up as soon as the client leaves
+	if elapsed > 80*time.Millisecond {
+		t.Errorf("worker took %v to notice the disconnected client", elapsed)
+	}
+	if !strings.Contains(logs.String(), "request_id=req-early-close") {
+		t.Errorf("expected disconnect to be logged with the request ID, got %q", logs.String())
+	}
+}
+
+// failingWriter is a ResponseWriter whose connection has already died
+type failingWriter struct {
+	header http.Header
+}
+
+func (w *failingWriter) Header() http.Header {
+	if w.header == nil {
+		w.header = make(http.Header)
+	}
+	return w.header
+}
+
+func (w *failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("i/o timeout")
+}
+
+func (w *failingWriter) WriteHeader(int) {}
+
+// blockingWriter is a ResponseWriter whose writes hang until release is closed
+type blockingWriter struct {
+	failingWriter
+	release chan struct{}
+}
+
+func (w *blockingWriter) Write([]byte) (int, error) {
+	<-w.release
+	return 0, errors.New("connection closed")
+}
+
+func TestWorkerPool_StopTimeoutRejectsQueued(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelError,
+	}))
+
+	wp := newWorkerPool(1, logger)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	wp.start(context.Background(), &wg)
+
+	// Wedge the only worker on a write that never completes
+	stuck := &blockingWriter{release: make(chan struct{})}
+	defer close(stuck.release)
+	wedged := &request{id: "req-stuck", w: stuck, r: httptest.NewRequest(http.MethodGet, "/", nil), done: make(chan struct{})}
+	if err := wp.submit(context.Background(), wedged); err != nil {
+		t.Fatalf("submit failed: %v", err)
+	}
+	time.Sleep(150 * time.Millisecond)
+
+	// These can only wait in the queue
+	queued := make([]*request, 2)
+	recorders := make([]*httptest.ResponseRecorder, len(queued))
+	for i := range queued {
+		recorders[i] = httptest.NewRecorder()
+		queued[i] = &request{
+			id:   fmt.Sprintf("req-queued-%d", i),
+			w:    recorders[i],
+			r:    httptest.NewRequest(http.MethodGet, "/", nil),
+			done: make(chan struct{}),
+		}
+		if err := wp.submit(context.Background(), queued[i]); err != nil {
+			t.Fatalf("submit %d failed: %v", i, err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := wp.stop(ctx); err == nil {
+		t.Fatal("expected stop to time out with a wedged worker")
+	}
+
+	for i, req := range queued {
+		select {
+		case <-req.done:
+		default:
+			t.Errorf("queued request %d was abandoned without a response", i)
+			continue
+		}
+		if recorders[i].Code != http.StatusServiceUnavailable {
+			t.Errorf("queued request %d: expected 503, got %d", i, recorders[i].Code)
+		}
+	}
+}
+
+// slowClientWriter is a ResponseWriter for a client that never reads: writes
+// hang until the write deadline passes, like a full socket send buffer
+type slowClientWriter struct {
+	failingWriter
+	mu       sync.Mutex
+	deadline time.Time
+}
+
+func (w *slowClientWriter) SetWriteDeadline(deadline time.Time) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.deadline = deadline
+	return nil
+}
+
+func (w *slowClientWriter) Write([]byte) (int, error) {
+	w.mu.Lock()
+	deadline := w.deadline
+	w.mu.Unlock()
+	if deadline.IsZero() {
+		select {} // no deadline: blocks forever, as a stuck client would
+	}
+	time.Sleep(time.Until(deadline))
+	return 0, os.ErrDeadlineExceeded
+}
+
+func TestWorkerPool_SubmitRacingStop(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelError,
+	}))
+
+	for round := 0; round < 20; round++ {
+		wp := newWorkerPool(2, logger)
+		ctx, cancel := context.WithCancel(context.Background())
+		var wg sync.WaitGroup
+		wg.Add(1)
+		wp.start(ctx, &wg)
+
+		var submitters sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			submitters.Add(1)
+			go func(i int) {
+				defer submitters.Done()
+				for j := 0; j < 20; j++ {
+					req := &request{
+						id:   fmt.Sprintf("req-%d-%d", i, j),
+						w:    httptest.NewRecorder(),
+						r:    httptest.NewRequest(http.MethodGet, "/", nil),
+						done: make(chan struct{}),
+					}
+					// Errors are expected once the pool is stopping; a
+					// send on the closed channel would panic instead
+					_ = wp.submit(context.Background(), req)
+				}
+			}(i)
+		}
+
+		// Alternate between the two ways the pool closes requestCh
+		if round%2 == 0 {
+			cancel()
+		} else {
+			stopCtx, stopCancel := context.WithTimeout(context.Background(), time.Second)
+			_ = wp.stop(stopCtx)
+			stopCancel()
+		}
+		submitters.Wait()
+		wg.Wait()
+		cancel()
+	}
+}
+
+func TestWorkerPool_SlowConsumerDisconnected(t *testing.T) {
+	var logs syncBuffer
+	logger := slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{
+		Level: slog.LevelWarn,
+	}))
+
+	wp := newWorkerPool(1, logger)
+	wp.writeTimeout = 50 * time.Millisecond
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	req := &request{id: "req-slow-reader", w: &slowClientWriter{}, r: r, done: make(chan struct{})}
+
+	freed := make(chan struct{})
+	start := time.Now()
+	go func() {
+		defer close(freed)
+		wp.processRequest(context.Background(), req, 0)
+	}()
+
+	// 100ms of work plus at most the write timeout, with some slack
+	select {
+	case <-freed:
+	case <-time.After(time.Second):
+		t.Fatal("worker still blocked on a slow consumer after the write deadline")
+	}
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("expected the worker to wait out the write timeout, returned after %v", elapsed)
+	}
+
+	out := logs.String()
+	if !strings.Contains(out, "slow consumer disconnected") || !strings.Contains(out, "req-slow-reader") {
+		t.Errorf("expected slow consumer to be logged with its request ID, got: %s", out)
+	}
+}
+
+func TestWorkerPool_WriteFailureLogged(t *testing.T) {
+	var logs syncBuffer
+	logger := slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{
+		Level: slog.LevelWarn,
+	}))
+
+	wp := newWorkerPool(1, logger)
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	req := &request{id: "req-write-fail", w: &failingWriter{}, r: r, done: make(chan struct{})}
+
+	wp.processRequest(context.Background(), req, 0)
+
+	select {
+	case <-req.done:
+	default:
+		t.Error("expected request to be finished after a failed write")
+	}
+
+	out := logs.String()
+	if !strings.Contains(out, "response write failed") || !strings.Contains(out, "request_id=req-write-fail") {
+		t.Errorf("expected write failure logged with request ID, got %q", out)
+	}
+}
+
+func TestServer_RoutePoolsIsolated(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelError,
+	}))
+
+	config := Config{
+		Port:            "8091",
+		WorkerPoolSize:  2,
+		RequestTimeout:  10 * time.Second,
+		ShutdownTimeout: 5 * time.Second,
+		Logger:          logger,
+		Pools: []PoolConfig{
+			{Name: "io", Size: 1},
+			{Name: "cpu", Size: 2},
+		},
+		Routes: []Route{
+			{Pattern: "/io/", Pool: "io"},
+			{Pattern: "/cpu/", Pool: "cpu"},
+		},
+	}
+
+	server := NewServer(config)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start server: %v", err)
+	}
+	defer server.Stop(context.Background())
+	waitReady(t, server)
+
+	// Saturate the single-worker io pool: ~1s of queued work
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := http.Get(fmt.Sprintf("http://localhost:%s/io/slow", config.Port))
+			if err == nil {
+				resp.Body.Close()
+			}
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	// The cpu pool must still answer promptly
+	start := time.Now()
+	resp, err := http.Get(fmt.Sprintf("http://localhost:%s/cpu/fast", config.Port))
+	if err != nil {
+		t.Fatalf("cpu request failed: %v", err)
+	}
+	resp.Body.Close()
+	elapsed := time.Since(start)
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected 200 from cpu pool, got %d", resp.StatusCode)
+	}
+	if elapsed > 400*time.Millisecond {
+		t.Errorf("cpu request took %v; saturated io pool appears to block it", elapsed)
+	}
+
+	wg.Wait()
+}
+
+func TestServer_RouteUnknownPool(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelError,
+	}))
+
+	server := NewServer(Config{
+		Port:            "8092",
+		WorkerPoolSize:  1,
+		RequestTimeout:  time.Second,
+		ShutdownTimeout: time.Second,
+		Logger:          logger,
+		Routes:          []Route{{Pattern: "/io/", Pool: "missing"}},
+	})
+
+	if err := server.Start(context.Background()); err == nil {
+		t.Fatal("expected Start to reject a route referring to an undeclared pool")
+	}
+}
+
+func TestServer_KeepAliveClosedOnShutdown(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelError,
+	}))
+
+	config := Config{
+		Port:            "8094",
+		WorkerPoolSize:  2,
+		RequestTimeout:  5 * time.Second,
+		ShutdownTimeout: 5 * time.Second,
+		Logger:          logger,
+	}
+
+	server := NewServer(config)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start server: %v", err)
+	}
+	waitReady(t, server)
+
+	conn, err := net.Dial("tcp", "localhost:"+config.Port)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer conn.Close()
+	rd := bufio.NewReader(conn)
+
+	// A first request proves the connection is kept alive normally
+	resp, err := keepAliveGet(conn, rd)
+	if err != nil {
+		t.Fatalf("first request failed: %v", err)
+	}
+	if resp.Close {
+		t.Fatal("expected keep-alive before shutdown")
+	}
+
+	// Start a request, then begin shutdown while it is in flight
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := fmt.Fprint(conn, "GET / HTTP/1.1\r\nHost: localhost\r\n\r\n"); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	time.Sleep(30 * time.Millisecond)
+
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- server.Stop(context.Background())
+	}()
+
+	// The in-flight request may be answered or cancelled by the drain;
+	// either way it must be the last one on this connection
+	resp, err = http.ReadResponse(rd, nil)
+	if err != nil {
+		t.Fatalf("in-flight request failed: %v", err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	if !resp.Close {
+		t.Error("expected Connection: close on the response sent during shutdown")
+	}
+	if _, err := rd.ReadByte(); err == nil {
+		t.Error("expected server to close the keep-alive connection")
+	}
+
+	if err := <-stopped; err != nil {
+		t.Errorf("shutdown failed: %v", err)
+	}
+}
+
+func TestServer_PerRouteTimeouts(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelError,
+	}))
+
+	// The global timeout is shorter than the simulated work, so only the
+	// slow route, which overrides it, can succeed
+	config := Config{
+		Port:            "8096",
+		WorkerPoolSize:  2,
+		RequestTimeout:  50 * time.Millisecond,
+		ShutdownTimeout: 5 * time.Second,
+		Logger:          logger,
+		Routes: []Route{
+			{Pattern: "/fast/", Timeout: 30 * time.Millisecond},
+			{Pattern: "/slow/", Timeout: 2 * time.Second},
+		},
+	}
+
+	server := NewServer(config)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start server: %v", err)
+	}
+	defer server.Stop(context.Background())
+	waitReady(t, server)
+
+	start := time.Now()
+	resp, err := http.Get(fmt.Sprintf("http://localhost:%s/fast/report", config.Port))
+	if err != nil {
+		t.Fatalf("fast route request failed: %v", err)
+	}
+	resp.Body.Close()
+	elapsed := time.Since(start)
+
+	if resp.StatusCode != http.StatusGatewayTimeout {
+		t.Errorf("expected 504 from the fast route, got %d", resp.StatusCode)
+	}
+	if elapsed > 90*time.Millisecond {
+		t.Errorf("expected the fast route to give up near its 30ms deadline, took %v", elapsed)
+	}
+
+	resp, err = http.Get(fmt.Sprintf("http://localhost:%s/slow/report", config.Port))
+	if err != nil {
+		t.Fatalf("slow route request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected the slow route to outlive the global timeout with 200, got %d", resp.StatusCode)
+	}
+}
+
+func TestServer_StartContextCancelShutsDown(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelError,
+	}))
+
+	config := Config{
+		Port:            "8101",
+		WorkerPoolSize:  2,
+		RequestTimeout:  5 * time.Second,
+		ShutdownTimeout: 5 * time.Second,
+		Logger:          logger,
+	}
+
+	server := NewServer(config)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := server.Start(ctx); err != nil {
+		t.Fatalf("failed to start server: %v", err)
+	}
+	waitReady(t, server)
+
+	resp, err := http.Get(fmt.Sprintf("http://localhost:%s/", config.Port))
+	if err != nil {
+		t.Fatalf("request before cancel failed: %v", err)
+	}
+	resp.Body.Close()
+
+	cancel()
+	select {
+	case <-server.Done():
+	case <-time.After(config.ShutdownTimeout):
+		t.Fatal("server did not shut down after the start context was cancelled")
+	}
+
+	if _, err := net.DialTimeout("tcp", "localhost:"+config.Port, time.Second); err == nil {
+		t.Error("expected the listener to be closed after shutdown")
+	}
+	if err := server.Stop(context.Background()); err != nil {
+		t.Errorf("expected Stop after shutdown to be a no-op, got %v", err)
+	}
+}
+
+func TestServer_HealthzProbesDatabase(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelError,
+	}))
+
+	config := Config{
+		Port:            "8102",
+		WorkerPoolSize:  1,
+		RequestTimeout:  5 * time.Second,
+		ShutdownTimeout: 5 * time.Second,
+		Logger:          logger,
+	}
+
+	server := NewServer(config)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start server: %v", err)
+	}
+	defer server.Stop(context.Background())
+	waitReady(t, server)
+
+	healthz := func() int {
+		resp, err := http.Get(fmt.Sprintf("http://localhost:%s/healthz", config.Port))
+		if err != nil {
+			t.Fatalf("health check request failed: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if code := healthz(); code != http.StatusOK {
+		t.Errorf("expected 200 with the database connected, got %d", code)
+	}
+
+	if err := server.dbConn.close(); err != nil {
+		t.Fatalf("closing database failed: %v", err)
+	}
+	if code := healthz(); code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 with the database closed, got %d", code)
+	}
+}
+
+func TestServer_ShutdownOrder(t *testing.T) {
+	tests := []struct {
+		name  string
+		port  string
+		order ShutdownOrder
+		first string
+		then  string
+	}{
+		{"warmer first", "8104", StopCacheWarmerFirst, "cache warmer and all goroutines finished", "database connection closed"},
+		{"db first", "8105", CloseDBFirst, "database connection closed", "cache warmer and all goroutines finished"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs syncBuffer
+			config := Config{
+				Port:            tt.port,
+				WorkerPoolSize:  1,
+				RequestTimeout:  5 * time.Second,
+				ShutdownTimeout: 5 * time.Second,
+				Logger:          slog.New(slog.NewTextHandler(&logs, nil)),
+				ShutdownOrder:   tt.order,
+			}
+
+			server := NewServer(config)
+			if err := server.Start(context.Background()); err != nil {
+				t.Fatalf("failed to start server: %v", err)
+			}
+			waitReady(t, server)
+			if err := server.Stop(context.Background()); err != nil {
+				t.Fatalf("stop failed: %v", err)
+			}
+
+			out := logs.String()
+			first := strings.Index(out, fmt.Sprintf("msg=%q", tt.first))
+			then := strings.Index(out, fmt.Sprintf("msg=%q", tt.then))
+			if first < 0 || then < 0 {
+				t.Fatalf("missing shutdown stage logs in:\n%s", out)
+			}
+			if first > then {
+				t.Errorf("expected %q before %q", tt.first, tt.then)
+			}
+		})
+	}
+}
+
+func TestServer_DrainProgressReportsRemaining(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelError,
+	}))
+
+	var mu sync.Mutex
+	var reports []int
+	config := Config{
+		Port:            "8107",
+		WorkerPoolSize:  4,
+		RequestTimeout:  5 * time.Second,
+		ShutdownTimeout: 5 * time.Second,
+		Logger:          logger,
+		// Slow work that ignores shutdown, finishing one request at a time
+		Routes: []Route{{Pattern: "/slow", Handler: func(w http.ResponseWriter, r *http.Request) error {
+			d, err := time.ParseDuration(r.URL.Query().Get("d"))
+			if err != nil {
+				return err
+			}
+			time.Sleep(d)
+			fmt.Fprintln(w, "done")
+			return nil
+		}}},
+		OnDrainProgress: func(remaining int) {
+			mu.Lock()
+			reports = append(reports, remaining)
+			mu.Unlock()
+		},
+		DrainProgressInterval: 50 * time.Millisecond,
+	}
+
+	server := NewServer(config)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start server: %v", err)
+	}
+	waitReady(t, server)
+
+	const numRequests = 4
+	var wg sync.WaitGroup
+	for i := 1; i <= numRequests; i++ {
+		wg.Add(1)
+		go func(d time.Duration) {
+			defer wg.Done()
+			resp, err := http.Get(fmt.Sprintf("http://localhost:%s/slow?d=%s", config.Port, d))
+			if err != nil {
+				t.Errorf("request failed: %v", err)
+				return
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Errorf("expected in-flight request to complete, got %d", resp.StatusCode)
+			}
+		}(time.Duration(i) * 200 * time.Millisecond)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for server.inflight.Load() < numRequests {
+		if time.Now().After(deadline) {
+			t.Fatalf("only %d requests in flight", server.inflight.Load())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if err := server.Stop(context.Background()); err != nil {
+		t.Fatalf("stop failed: %v", err)
+	}
+	wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(reports) == 0 || reports[0] != numRequests {
+		t.Fatalf("expected the first report to count %d requests, got %v", numRequests, reports)
+	}
+	distinct := map[int]bool{}
+	for i, n := range reports {
+		if i > 0 && n > reports[i-1] {
+			t.Errorf("remaining count went up: %v", reports)
+			break
+		}
+		distinct[n] = true
+	}
+	if len(distinct) < 3 {
+		t.Errorf("expected progress as requests completed, got %v", reports)
+	}
+}
+
+func TestServer_LameDuckServesUntilDrain(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelError,
+	}))
+
+	config := Config{
+		Port:            "8108",
+		WorkerPoolSize:  4,
+		RequestTimeout:  5 * time.Second,
+		ShutdownTimeout: 5 * time.Second,
+		Logger:          logger,
+		LameDuckPeriod:  time.Second,
+	}
+
+	server := NewServer(config)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start server: %v", err)
+	}
+	waitReady(t, server)
+
+	base := fmt.Sprintf("http://localhost:%s", config.Port)
+	status := func(path string) int {
+		resp, err := http.Get(base + path)
+		if err != nil {
+			t.Errorf("GET %s failed: %v", path, err)
+			return 0
+		}
+		defer resp.Body.Close()
+		io.Copy(io.Discard, resp.Body)
+		return resp.StatusCode
+	}
+
+	if got := status("/readyz"); got != http.StatusOK {
+		t.Fatalf("expected /readyz 200 before shutdown, got %d", got)
+	}
+
+	stopped := make(chan error, 1)
+	start := time.Now()
+	go func() { stopped <- server.Stop(context.Background()) }()
+
+	deadline := time.Now().Add(500 * time.Millisecond)
+	for status("/readyz") != http.StatusServiceUnavailable {
+		if time.Now().After(deadline) {
+			t.Fatal("expected /readyz to fail once lame duck began")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Well inside the lame-duck period, so these must be served normally
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if got := status("/"); got != http.StatusOK {
+				t.Errorf("expected request during lame duck to succeed, got %d", got)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := <-stopped; err != nil {
+		t.Fatalf("stop failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < config.LameDuckPeriod {
+		t.Errorf("expected Stop to wait out the %v lame-duck period, took %v", config.LameDuckPeriod, elapsed)
+	}
+
+	// Past the lame duck, requests that still reach the handler are refused
+	rec := httptest.NewRecorder()
+	server.handleRequest(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 after the lame-duck period, got %d", rec.Code)
+	}
+}
+
+func TestServer_RouteMaxConcurrent(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelError,
+	}))
+
+	release := make(chan struct{})
+	started := make(chan struct{}, 2)
+	config := Config{
+		Port:            "8109",
+		WorkerPoolSize:  4,
+		RequestTimeout:  5 * time.Second,
+		ShutdownTimeout: 5 * time.Second,
+		Logger:          logger,
+		Routes: []Route{{Pattern: "/report", MaxConcurrent: 2, Handler: func(w http.ResponseWriter, r *http.Request) error {
+			started <- struct{}{}
+			<-release
+			fmt.Fprintln(w, "report")
+			return nil
+		}}},
+	}
+
+	server := NewServer(config)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start server: %v", err)
+	}
+	defer server.Stop(context.Background())
+	waitReady(t, server)
+
+	get := func(path string) int {
+		resp, err := http.Get(fmt.Sprintf("http://localhost:%s%s", config.Port, path))
+		if err != nil {
+			t.Errorf("GET %s failed: %v", path, err)
+			return 0
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// Fill the route's limit
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if got := get("/report"); got != http.StatusOK {
+				t.Errorf("expected requests within the limit to succeed, got %d", got)
+			}
+		}()
+	}
+	<-started
+	<-started
+
+	if got := get("/report"); got != http.StatusTooManyRequests {
+		t.Errorf("expected 429 past the route's limit, got %d", got)
+	}
+	// The pool still has free workers for other routes
+	if got := get("/"); got != http.StatusOK {
+		t.Errorf("expected other routes to be served, got %d", got)
+	}
+
+	close(release)
+	wg.Wait()
+}
+
+// closeFailConn is a connection whose Close always fails
+type closeFailConn struct {
+	net.Conn
+}
+
+var errCloseFailed = errors.New("close failed")
+
+func (c closeFailConn) Close() error {
+	c.Conn.Close()
+	return errCloseFailed
+}
+
+func TestServer_StopJoinsStageErrors(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	config := Config{
+		Port:            "8110",
+		WorkerPoolSize:  1,
+		RequestTimeout:  5 * time.Second,
+		ShutdownTimeout: 200 * time.Millisecond,
+		Logger:          logger,
+		// Outlives the shutdown timeout, so the HTTP and worker pool
+		// stages both time out
+		Routes: []Route{{Pattern: "/stuck", Handler: func(w http.ResponseWriter, r *http.Request) error {
+			close(started)
+			<-release
+			return nil
+		}}},
+	}
+
+	server := NewServer(config)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start server: %v", err)
+	}
+	waitReady(t, server)
+	defer close(release)
+
+	go func() {
+		resp, err := http.Get(fmt.Sprintf("http://localhost:%s/stuck", config.Port))
+		if err == nil {
+			resp.Body.Close()
+		}
+	}()
+	<-started
+
+	// And the database fails to close
+	server.dbConn.mu.Lock()
+	server.dbConn.conn = closeFailConn{Conn: server.dbConn.conn}
+	server.dbConn.mu.Unlock()
+
+	err := server.Stop(context.Background())
+	if err == nil {
+		t.Fatal("expected shutdown errors")
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the timed-out stages to be reported, got %v", err)
+	}
+	if !errors.Is(err, errCloseFailed) {
+		t.Errorf("expected the database close failure to be reported, got %v", err)
+	}
+	for _, stage := range []string{"HTTP server shutdown", "worker pool default shutdown", "database close"} {
+		if !strings.Contains(err.Error(), stage) {
+			t.Errorf("expected error to include %q, got %v", stage, err)
+		}
+	}
+}
+
+func TestServer_DrainFinishesQueuedRequests(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelError,
+	}))
+
+	// One worker, so all but one request are still queued when Stop begins
+	config := Config{
+		Port:            "8114",
+		WorkerPoolSize:  1,
+		QueueSize:       4,
+		RequestTimeout:  5 * time.Second,
+		ShutdownTimeout: 5 * time.Second,
+		Logger:          logger,
+	}
+
+	server := NewServer(config)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start server: %v", err)
+	}
+	waitReady(t, server)
+
+	const numRequests = 5
+	var wg sync.WaitGroup
+	for i := 0; i < numRequests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			resp, err := http.Get("http://localhost:" + config.Port + "/")
+			if err != nil {
+				t.Errorf("request %d failed: %v", i, err)
+				return
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Errorf("request %d: expected queued work to complete with 200, got %d", i, resp.StatusCode)
+			}
+		}(i)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for server.inflight.Load() < numRequests {
+		if time.Now().After(deadline) {
+			t.Fatalf("only %d requests in flight", server.inflight.Load())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if err := server.Stop(context.Background()); err != nil {
+		t.Fatalf("stop failed: %v", err)
+	}
+	wg.Wait()
+}
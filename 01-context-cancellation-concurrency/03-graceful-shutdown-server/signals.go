@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// notifySignals is signal.Notify, replaced in tests to deliver signals
+// without sending them to the process
+var notifySignals = signal.Notify
+
+// HandleSignals shuts the server down on SIGINT or SIGTERM, so callers don't
+// need their own signal handling. It blocks until the first signal, then
+// calls Stop, bounded by the configured LameDuckPeriod plus ShutdownTimeout,
+// and returns Stop's error once shutdown completes. It returns nil if the
+// server shuts down some other way first, and ctx.Err() if ctx is done
+// first, leaving the server running. The handlers are removed on return.
+func (s *Server) HandleSignals(ctx context.Context) error {
+	sigCh := make(chan os.Signal, 1)
+	notifySignals(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+
+	select {
+	case sig := <-sigCh:
+		s.config.Logger.Info("received shutdown signal", "signal", sig.String())
+	case <-s.shutdownCh:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	stopCtx, cancel := context.WithTimeout(context.Background(), s.config.LameDuckPeriod+s.config.ShutdownTimeout)
+	defer cancel()
+	return s.Stop(stopCtx)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// fakeSignals replaces notifySignals for the test and returns the channels
+// HandleSignals registers, so signals can be delivered to it directly
+func fakeSignals(t *testing.T) <-chan chan<- os.Signal {
+	t.Helper()
+	registered := make(chan chan<- os.Signal, 1)
+	notifySignals = func(c chan<- os.Signal, sig ...os.Signal) {
+		registered <- c
+	}
+	t.Cleanup(func() { notifySignals = signal.Notify })
+	return registered
+}
+
+func TestServer_HandleSignalsStopsOnSIGTERM(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelError,
+	}))
+	registered := fakeSignals(t)
+
+	config := Config{
+		Port:            "8115",
+		WorkerPoolSize:  1,
+		RequestTimeout:  5 * time.Second,
+		ShutdownTimeout: 5 * time.Second,
+		Logger:          logger,
+	}
+
+	server := NewServer(config)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start server: %v", err)
+	}
+	waitReady(t, server)
+
+	handled := make(chan error, 1)
+	go func() { handled <- server.HandleSignals(context.Background()) }()
+
+	select {
+	case <-server.Done():
+		t.Fatal("expected the server to keep running until a signal arrives")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	sigCh := <-registered
+	sigCh <- syscall.SIGTERM
+
+	select {
+	case err := <-handled:
+		if err != nil {
+			t.Errorf("expected a clean shutdown, got %v", err)
+		}
+	case <-time.After(config.ShutdownTimeout):
+		t.Fatal("HandleSignals did not return after the signal")
+	}
+
+	// Stop has fully completed by the time HandleSignals returns
+	select {
+	case <-server.Done():
+	default:
+		t.Error("expected the server to be shut down")
+	}
+}
+
+func TestServer_HandleSignalsContextDone(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelError,
+	}))
+	fakeSignals(t)
+
+	config := Config{
+		Port:            "8116",
+		WorkerPoolSize:  1,
+		RequestTimeout:  5 * time.Second,
+		ShutdownTimeout: 5 * time.Second,
+		Logger:          logger,
+	}
+
+	server := NewServer(config)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start server: %v", err)
+	}
+	defer server.Stop(context.Background())
+	waitReady(t, server)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := server.HandleSignals(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the context's error, got %v", err)
+	}
+
+	select {
+	case <-server.Done():
+		t.Error("expected the server to keep running when only the context ended")
+	default:
+	}
+}
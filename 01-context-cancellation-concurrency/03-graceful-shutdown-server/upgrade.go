@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// UpgradeHandler takes over a connection after an upgrade request (e.g. a
+// WebSocket handshake) has been hijacked. It must write the protocol's
+// response itself, such as 101 Switching Protocols, and should return once
+// reads from conn fail: the server closes conn when shutting down, and
+// again once the handler returns.
+type UpgradeHandler func(conn net.Conn, rw *bufio.ReadWriter, r *http.Request)
+
+// isUpgradeRequest reports whether r asks to switch protocols, i.e. has an
+// Upgrade header and lists "upgrade" among its Connection tokens
+func isUpgradeRequest(r *http.Request) bool {
+	if r.Header.Get("Upgrade") == "" {
+		return false
+	}
+	for _, value := range r.Header.Values("Connection") {
+		for _, token := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// upgradeTracker keeps the hijacked connections, which http.Server.Shutdown
+// no longer knows about, so Stop can close them itself
+type upgradeTracker struct {
+	mu     sync.Mutex
+	conns  map[net.Conn]struct{}
+	closed bool
+	wg     sync.WaitGroup
+}
+
+func newUpgradeTracker() *upgradeTracker {
+	return &upgradeTracker{conns: make(map[net.Conn]struct{})}
+}
+
+// add starts tracking conn, or returns false once closeAll has been called
+func (t *upgradeTracker) add(conn net.Conn) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed {
+		return false
+	}
+	t.conns[conn] = struct{}{}
+	t.wg.Add(1)
+	return true
+}
+
+// done closes conn and stops tracking it
+func (t *upgradeTracker) done(conn net.Conn) {
+	conn.Close()
+
+	t.mu.Lock()
+	delete(t.conns, conn)
+	t.mu.Unlock()
+	t.wg.Done()
+}
+
+// closeAll closes every tracked connection and waits for their handlers to
+// return, or for ctx to be done
+func (t *upgradeTracker) closeAll(ctx context.Context) error {
+	t.mu.Lock()
+	t.closed = true
+	for conn := range t.conns {
+		conn.Close()
+	}
+	t.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		t.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("waiting for upgraded connections: %w", ctx.Err())
+	}
+}
+
+// passUpgrades hands upgrade requests to Config.UpgradeHandler on the HTTP
+// goroutine, bypassing the worker pools: a long-lived connection would
+// otherwise hold a worker for its whole lifetime and stall the drain.
+// Other requests go to next.
+func (s *Server) passUpgrades(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isUpgradeRequest(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		conn, rw, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			s.config.Logger.Error("failed to hijack upgrade request", "error", err)
+			writeError(w, s.config.JSONErrors, "Upgrade not supported", http.StatusInternalServerError)
+			return
+		}
+		if !s.upgrades.add(conn) {
+			// Shutdown has already closed the other upgraded connections
+			conn.Close()
+			return
+		}
+		defer s.upgrades.done(conn)
+
+		// The server's read and write timeouts are for HTTP exchanges and
+		// would cut the upgraded connection off mid-stream
+		conn.SetDeadline(time.Time{})
+
+		s.config.Logger.Info("connection upgraded",
+			"protocol", r.Header.Get("Upgrade"),
+			"remote_addr", r.RemoteAddr,
+		)
+		s.config.UpgradeHandler(conn, rw, r)
+	})
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestIsUpgradeRequest(t *testing.T) {
+	tests := []struct {
+		name       string
+		connection []string
+		upgrade    string
+		want       bool
+	}{
+		{"websocket", []string{"Upgrade"}, "websocket", true},
+		{"token list", []string{"keep-alive, upgrade"}, "websocket", true},
+		{"repeated header", []string{"keep-alive", "Upgrade"}, "h2c", true},
+		{"no upgrade header", []string{"Upgrade"}, "", false},
+		{"no connection token", []string{"keep-alive"}, "websocket", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			for _, v := range tt.connection {
+				r.Header.Add("Connection", v)
+			}
+			if tt.upgrade != "" {
+				r.Header.Set("Upgrade", tt.upgrade)
+			}
+			if got := isUpgradeRequest(r); got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestServer_ShutdownClosesUpgradedConnections(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelError,
+	}))
+
+	handlerDone := make(chan struct{})
+	config := Config{
+		Port:            "8106",
+		WorkerPoolSize:  1,
+		RequestTimeout:  5 * time.Second,
+		ShutdownTimeout: 5 * time.Second,
+		Logger:          logger,
+		// Echoes until the connection is closed, like a WebSocket session
+		// that never ends on its own
+		UpgradeHandler: func(conn net.Conn, rw *bufio.ReadWriter, r *http.Request) {
+			defer close(handlerDone)
+			rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: echo\r\nConnection: Upgrade\r\n\r\n")
+			rw.Flush()
+			io.Copy(conn, rw)
+		},
+	}
+
+	server := NewServer(config)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start server: %v", err)
+	}
+	waitReady(t, server)
+
+	conn, err := net.Dial("tcp", "localhost:"+config.Port)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer conn.Close()
+
+	conn.Write([]byte("GET /ws HTTP/1.1\r\nHost: localhost\r\nConnection: Upgrade\r\nUpgrade: echo\r\n\r\n"))
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatalf("failed to read upgrade response: %v", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("expected 101, got %d", resp.StatusCode)
+	}
+
+	conn.Write([]byte("ping"))
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(br, buf); err != nil || string(buf) != "ping" {
+		t.Fatalf("expected echo of ping, got %q (%v)", buf, err)
+	}
+
+	stopped := make(chan error, 1)
+	start := time.Now()
+	go func() { stopped <- server.Stop(context.Background()) }()
+
+	select {
+	case err := <-stopped:
+		if err != nil {
+			t.Errorf("expected clean shutdown, got %v", err)
+		}
+	case <-time.After(config.ShutdownTimeout):
+		t.Fatal("Stop hung on the upgraded connection")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("expected the upgraded connection to be closed promptly, Stop took %v", elapsed)
+	}
+
+	select {
+	case <-handlerDone:
+	default:
+		t.Error("expected the upgrade handler to have returned by the end of Stop")
+	}
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := br.ReadByte(); err != io.EOF {
+		t.Errorf("expected the client to see the connection closed, got %v", err)
+	}
+}
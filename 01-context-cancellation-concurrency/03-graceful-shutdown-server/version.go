@@ -0,0 +1,21 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// BuildInfo identifies the running build, typically set at link time with
+// -ldflags "-X ...". BuildTime is kept as given, e.g. RFC 3339.
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildTime string `json:"build_time"`
+}
+
+// handleVersion reports Config.Build as JSON, so a deployment can be
+// checked for the build it is actually running
+func (s *Server) handleVersion(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.config.Build)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestServer_VersionEndpoint(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelError,
+	}))
+	build := BuildInfo{Version: "v1.4.2", Commit: "9f3c2e1", BuildTime: "2026-10-01T12:00:00Z"}
+
+	tests := []struct {
+		name       string
+		port       string
+		adminPort  string
+		servedPort string
+		hiddenPort string // must not serve /version
+	}{
+		{"main port", "8111", "", "8111", ""},
+		{"admin port", "8112", "8113", "8113", "8112"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := Config{
+				Port:            tt.port,
+				AdminPort:       tt.adminPort,
+				WorkerPoolSize:  1,
+				RequestTimeout:  5 * time.Second,
+				ShutdownTimeout: 5 * time.Second,
+				Logger:          logger,
+				Build:           build,
+			}
+
+			server := NewServer(config)
+			if err := server.Start(context.Background()); err != nil {
+				t.Fatalf("failed to start server: %v", err)
+			}
+			defer server.Stop(context.Background())
+			waitReady(t, server)
+
+			resp, err := http.Get("http://localhost:" + tt.servedPort + "/version")
+			if err != nil {
+				t.Fatalf("version request failed: %v", err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("expected 200, got %d", resp.StatusCode)
+			}
+			if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+				t.Errorf("expected application/json, got %q", ct)
+			}
+			var got BuildInfo
+			if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+				t.Fatalf("failed to decode body: %v", err)
+			}
+			if got != build {
+				t.Errorf("expected %+v, got %+v", build, got)
+			}
+
+			if tt.hiddenPort == "" {
+				return
+			}
+			resp, err = http.Get("http://localhost:" + tt.hiddenPort + "/version")
+			if err != nil {
+				t.Fatalf("main port request failed: %v", err)
+			}
+			resp.Body.Close()
+			if resp.Header.Get("Content-Type") == "application/json" {
+				t.Error("expected the main port not to serve /version when there is an admin listener")
+			}
+		})
+	}
+}
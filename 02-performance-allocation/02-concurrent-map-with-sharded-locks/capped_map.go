@@ -0,0 +1,83 @@
+package main
+
+import "sync/atomic"
+
+// CappedShardedMap is a ShardedMap with a hard limit on the number of entries.
+// Once full, inserts of new keys are rejected rather than evicting anything;
+// updates to existing keys are still accepted.
+//
+// The underlying map is not embedded so that every mutation goes through
+// methods that keep the entry count accurate.
+type CappedShardedMap[K comparable, V any] struct {
+	sm         *ShardedMap[K, V]
+	maxEntries int64
+	count      atomic.Int64
+}
+
+// NewCappedShardedMap creates a CappedShardedMap holding at most maxEntries keys.
+func NewCappedShardedMap[K comparable, V any](shardCount, maxEntries int, opts ...Option[K, V]) *CappedShardedMap[K, V] {
+	return &CappedShardedMap[K, V]{
+		sm:         NewShardedMap[K, V](shardCount, opts...),
+		maxEntries: int64(maxEntries),
+	}
+}
+
+// Get retrieves a value from the map. Returns the value and a boolean indicating existence.
+func (cm *CappedShardedMap[K, V]) Get(key K) (V, bool) {
+	return cm.sm.Get(key)
+}
+
+// Keys returns all keys from all shards.
+func (cm *CappedShardedMap[K, V]) Keys() []K {
+	return cm.sm.Keys()
+}
+
+// Set inserts or updates a value. It returns false, leaving the map unchanged,
+// when key is new and the map is already at capacity.
+func (cm *CappedShardedMap[K, V]) Set(key K, value V) bool {
+	shardIndex := cm.sm.getShardIndex(key)
+	cm.sm.shardMutex[shardIndex].Lock()
+	defer cm.sm.shardMutex[shardIndex].Unlock()
+
+	if _, exists := cm.sm.shards[shardIndex][key]; !exists {
+		// Reserve a slot; shards are locked independently, so the
+		// reservation has to be atomic across the whole map
+		if cm.count.Add(1) > cm.maxEntries {
+			cm.count.Add(-1)
+			return false
+		}
+	}
+
+	cm.sm.shards[shardIndex][key] = value
+	cm.sm.ops[shardIndex].sets.Add(1)
+	cm.sm.touch(shardIndex, key)
+	return true
+}
+
+// Delete removes a key from the map, freeing its slot.
+func (cm *CappedShardedMap[K, V]) Delete(key K) {
+	shardIndex := cm.sm.getShardIndex(key)
+	cm.sm.shardMutex[shardIndex].Lock()
+	defer cm.sm.shardMutex[shardIndex].Unlock()
+
+	cm.sm.ops[shardIndex].deletes.Add(1)
+	if _, exists := cm.sm.shards[shardIndex][key]; exists {
+		delete(cm.sm.shards[shardIndex], key)
+		cm.sm.forget(shardIndex, key)
+		cm.count.Add(-1)
+	}
+}
+
+// Drain removes every entry and returns them, freeing all slots.
+func (cm *CappedShardedMap[K, V]) Drain() map[K]V {
+	// Freed while the shards are still locked, so a Set can't find the map
+	// empty with the count still full
+	return cm.sm.drain(func(n int) {
+		cm.count.Add(-int64(n))
+	})
+}
+
+// Len returns the number of entries currently stored.
+func (cm *CappedShardedMap[K, V]) Len() int {
+	return int(cm.count.Load())
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// TestCappedRejectsWhenFull tests that new keys are rejected at capacity
+func TestCappedRejectsWhenFull(t *testing.T) {
+	cm := NewCappedShardedMap[string, int](8, 10)
+
+	for i := 0; i < 10; i++ {
+		if !cm.Set(fmt.Sprintf("key-%d", i), i) {
+			t.Fatalf("Set of key-%d rejected below capacity", i)
+		}
+	}
+
+	if cm.Set("overflow", 99) {
+		t.Error("Expected Set of a new key to be rejected at capacity")
+	}
+	if _, exists := cm.Get("overflow"); exists {
+		t.Error("Rejected key must not be stored")
+	}
+
+	// Existing keys remain readable and updatable
+	for i := 0; i < 10; i++ {
+		val, exists := cm.Get(fmt.Sprintf("key-%d", i))
+		if !exists || val != i {
+			t.Errorf("key-%d: expected %d, got %d, exists=%v", i, i, val, exists)
+		}
+	}
+	if !cm.Set("key-0", 100) {
+		t.Error("Expected update of an existing key to succeed at capacity")
+	}
+
+	// Deleting frees a slot
+	cm.Delete("key-1")
+	if !cm.Set("overflow", 99) {
+		t.Error("Expected Set to succeed after Delete freed a slot")
+	}
+	if cm.Len() != 10 {
+		t.Errorf("Expected Len 10, got %d", cm.Len())
+	}
+}
+
+// TestCappedConcurrentSets tests that concurrent inserts never exceed the cap
+func TestCappedConcurrentSets(t *testing.T) {
+	const maxEntries = 500
+	cm := NewCappedShardedMap[int, int](16, maxEntries)
+
+	var accepted atomic.Int64
+	var wg sync.WaitGroup
+	for g := 0; g < 20; g++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if cm.Set(id*100+j, j) {
+					accepted.Add(1)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if accepted.Load() != maxEntries {
+		t.Errorf("Expected exactly %d accepted inserts, got %d", maxEntries, accepted.Load())
+	}
+	if keys := cm.Keys(); len(keys) != maxEntries {
+		t.Errorf("Expected %d stored keys, got %d", maxEntries, len(keys))
+	}
+}
+
+// TestCappedDrainFreesSlots tests that Drain frees every slot and keeps the
+// count in step with the entries under concurrent inserts
+func TestCappedDrainFreesSlots(t *testing.T) {
+	cm := NewCappedShardedMap[int, int](8, 100)
+	for i := 0; i < 100; i++ {
+		cm.Set(i, i)
+	}
+
+	var wg sync.WaitGroup
+	var drainedTotal int
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				cm.Set(100+g*1000+i, i)
+			}
+		}(g)
+	}
+	for i := 0; i < 50; i++ {
+		drainedTotal += len(cm.Drain())
+	}
+	wg.Wait()
+
+	if got, want := cm.Len(), cm.sm.Len(); got != want {
+		t.Errorf("Expected count %d to match the entries, got %d", want, got)
+	}
+	if drainedTotal < 100 {
+		t.Errorf("Expected the initial entries to be drained, got %d", drainedTotal)
+	}
+	cm.Drain()
+	if cm.Len() != 0 || !cm.Set(-1, 0) {
+		t.Errorf("Expected an empty map with free slots after Drain, got %d entries", cm.Len())
+	}
+}
@@ -0,0 +1,26 @@
+package main
+
+// WithValueCloner makes every read return clone(value) instead of the stored
+// value itself. Use it when V is a slice, map or pointer, so a caller that
+// modifies what it read can't change the stored value behind the shard lock.
+// It applies to Get, TryGet, GetOrSet, KeyHandle.Get, ShardSnapshot (and so
+// RangeChecked), Range, ChangedSince and TopN; Keys needs no cloning, as it
+// already returns a fresh slice. clone runs under the shard's lock and must
+// not call back into the map.
+//
+// Only reads are copied: a value passed to Set is stored as is, so the caller
+// must not modify it afterwards.
+func WithValueCloner[K comparable, V any](clone func(V) V) Option[K, V] {
+	return func(sm *ShardedMap[K, V]) {
+		sm.clone = clone
+	}
+}
+
+// cloned returns a copy of value made by the map's cloner, or value itself
+// when none is set.
+func (sm *ShardedMap[K, V]) cloned(value V) V {
+	if sm.clone == nil {
+		return value
+	}
+	return sm.clone(value)
+}
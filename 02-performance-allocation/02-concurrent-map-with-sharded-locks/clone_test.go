@@ -0,0 +1,44 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+// TestValueClonerPreventsAliasing tests that mutating a value read from a
+// map with a cloner leaves the stored value intact.
+func TestValueClonerPreventsAliasing(t *testing.T) {
+	sm := NewShardedMap[string, []int](4, WithValueCloner[string](slices.Clone[[]int]))
+	sm.Set("a", []int{1, 2, 3})
+
+	got, _ := sm.Get("a")
+	got[0] = 99
+
+	if stored, _ := sm.Get("a"); stored[0] != 1 {
+		t.Errorf("Get result aliases the stored value: stored is now %v", stored)
+	}
+
+	h := sm.Handle("a")
+	hv, _ := h.Get()
+	hv[1] = 99
+	snapshot := sm.ShardSnapshot(int(h.shardIndex))
+	snapshot["a"][2] = 99
+
+	if stored, _ := sm.Get("a"); !slices.Equal(stored, []int{1, 2, 3}) {
+		t.Errorf("Expected stored value [1 2 3], got %v", stored)
+	}
+}
+
+// TestValueClonerOff tests that without a cloner reads share the stored
+// value, which is what WithValueCloner exists to prevent.
+func TestValueClonerOff(t *testing.T) {
+	sm := NewShardedMap[string, []int](4)
+	sm.Set("a", []int{1, 2, 3})
+
+	got, _ := sm.Get("a")
+	got[0] = 99
+
+	if stored, _ := sm.Get("a"); stored[0] != 99 {
+		t.Errorf("Expected reads to alias without a cloner, stored is %v", stored)
+	}
+}
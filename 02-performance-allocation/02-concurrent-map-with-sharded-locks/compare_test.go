@@ -0,0 +1,159 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"text/tabwriter"
+)
+
+// Run the comparison table with:
+//
+//	go test -run TestCompareWithSyncMap -compare -v
+//
+// or the raw benchmarks with:
+//
+//	go test -run x -bench 'Compare' -benchmem
+var compareTable = flag.Bool("compare", false, "print the ShardedMap vs sync.Map comparison table")
+
+// benchMap is the subset of operations both implementations are compared on.
+type benchMap interface {
+	Get(key string) (int, bool)
+	Set(key string, value int)
+}
+
+// syncMapAdapter wraps sync.Map in the benchMap interface.
+type syncMapAdapter struct {
+	m sync.Map
+}
+
+func (a *syncMapAdapter) Get(key string) (int, bool) {
+	v, ok := a.m.Load(key)
+	if !ok {
+		return 0, false
+	}
+	return v.(int), true
+}
+
+func (a *syncMapAdapter) Set(key string, value int) {
+	a.m.Store(key, value)
+}
+
+// compareWorkload is a mix of reads and writes over a fixed key set.
+type compareWorkload struct {
+	name    string
+	readPct int
+}
+
+var compareWorkloads = []compareWorkload{
+	{name: "ReadHeavy", readPct: 90},
+	{name: "Mixed", readPct: 50},
+	{name: "WriteHeavy", readPct: 10},
+}
+
+var compareShardCounts = []int{1, 16, 64, 256}
+
+const compareNumKeys = 10000
+
+// compareImpl names a map implementation and builds a pre-populated instance.
+type compareImpl struct {
+	name string
+	new  func() benchMap
+}
+
+func compareImpls() []compareImpl {
+	impls := []compareImpl{{name: "sync.Map", new: func() benchMap { return &syncMapAdapter{} }}}
+	for _, shards := range compareShardCounts {
+		impls = append(impls, compareImpl{
+			name: fmt.Sprintf("ShardedMap/%d", shards),
+			new:  func() benchMap { return NewShardedMap[string, int](shards) },
+		})
+	}
+	return impls
+}
+
+func compareKeys() []string {
+	keys := make([]string, compareNumKeys)
+	for i := range keys {
+		keys[i] = "key-" + strconv.Itoa(i)
+	}
+	return keys
+}
+
+// runCompareWorkload runs w against m from parallel goroutines.
+func runCompareWorkload(b *testing.B, m benchMap, keys []string, w compareWorkload) {
+	for i, key := range keys {
+		m.Set(key, i)
+	}
+
+	// Each goroutine starts at a different offset so they don't move in
+	// lockstep over the same keys
+	var offset atomic.Uint64
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := offset.Add(7919)
+		for pb.Next() {
+			key := keys[i%uint64(len(keys))]
+			if int(i%100) < w.readPct {
+				m.Get(key)
+			} else {
+				m.Set(key, int(i))
+			}
+			i++
+		}
+	})
+}
+
+// BenchmarkCompare runs every workload against sync.Map and ShardedMap at
+// several shard counts.
+func BenchmarkCompare(b *testing.B) {
+	keys := compareKeys()
+	for _, w := range compareWorkloads {
+		for _, impl := range compareImpls() {
+			b.Run(w.name+"/"+impl.name, func(b *testing.B) {
+				runCompareWorkload(b, impl.new(), keys, w)
+			})
+		}
+	}
+}
+
+// TestCompareWithSyncMap prints ns/op for each workload and implementation,
+// plus the speedup relative to sync.Map. It only runs with -compare.
+func TestCompareWithSyncMap(t *testing.T) {
+	if !*compareTable {
+		t.Skip("pass -compare to print the comparison table")
+	}
+
+	keys := compareKeys()
+	impls := compareImpls()
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprint(tw, "workload\t")
+	for _, impl := range impls {
+		fmt.Fprintf(tw, "%s\t", impl.name)
+	}
+	fmt.Fprintln(tw)
+
+	for _, w := range compareWorkloads {
+		var baseline float64
+		fmt.Fprintf(tw, "%s\t", w.name)
+		for i, impl := range impls {
+			res := testing.Benchmark(func(b *testing.B) {
+				runCompareWorkload(b, impl.new(), keys, w)
+			})
+			nsPerOp := float64(res.T.Nanoseconds()) / float64(res.N)
+			if i == 0 {
+				baseline = nsPerOp
+				fmt.Fprintf(tw, "%.1f ns\t", nsPerOp)
+				continue
+			}
+			fmt.Fprintf(tw, "%.1f ns (%.2fx)\t", nsPerOp, baseline/nsPerOp)
+		}
+		fmt.Fprintln(tw)
+	}
+	tw.Flush()
+}
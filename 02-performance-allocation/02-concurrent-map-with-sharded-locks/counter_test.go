@@ -0,0 +1,180 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// TestCounterIncrement tests concurrent increments are not lost
+func TestCounterIncrement(t *testing.T) {
+	c := NewShardedCounter[string](16)
+	const numGoroutines = 50
+	const incrementsPerGoroutine = 200
+
+	var wg sync.WaitGroup
+	for i := 0; i < numGoroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < incrementsPerGoroutine; j++ {
+				c.Increment("hits", 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := c.Get("hits"); got != numGoroutines*incrementsPerGoroutine {
+		t.Errorf("Expected %d, got %d", numGoroutines*incrementsPerGoroutine, got)
+	}
+	if got := c.Get("missing"); got != 0 {
+		t.Errorf("Expected 0 for missing key, got %d", got)
+	}
+}
+
+// TestCounterIncrementCapped tests the counter never exceeds max under contention
+func TestCounterIncrementCapped(t *testing.T) {
+	c := NewShardedCounter[string](16)
+	const max = 1000
+	const numGoroutines = 50
+	const incrementsPerGoroutine = 100
+
+	var allowed, limited atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < numGoroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < incrementsPerGoroutine; j++ {
+				val, hit := c.IncrementCapped("user-1", 1, max)
+				if val > max {
+					t.Errorf("Counter exceeded max: %d", val)
+				}
+				if hit {
+					limited.Add(1)
+					if val != max {
+						t.Errorf("Limited increment should leave counter at max, got %d", val)
+					}
+				} else {
+					allowed.Add(1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := c.Get("user-1"); got != max {
+		t.Errorf("Expected final value %d, got %d", max, got)
+	}
+	if allowed.Load() != max {
+		t.Errorf("Expected exactly %d allowed increments, got %d", max, allowed.Load())
+	}
+	if limited.Load() != numGoroutines*incrementsPerGoroutine-max {
+		t.Errorf("Expected %d limited increments, got %d",
+			numGoroutines*incrementsPerGoroutine-max, limited.Load())
+	}
+}
+
+// TestCounterIncrementCappedBoundary tests that reaching max exactly is not limited
+func TestCounterIncrementCappedBoundary(t *testing.T) {
+	c := NewShardedCounter[int](4)
+
+	if val, hit := c.IncrementCapped(1, 5, 5); hit || val != 5 {
+		t.Errorf("Expected (5, false) when reaching max exactly, got (%d, %v)", val, hit)
+	}
+	if val, hit := c.IncrementCapped(1, 1, 5); !hit || val != 5 {
+		t.Errorf("Expected (5, true) past max, got (%d, %v)", val, hit)
+	}
+}
+
+// TestCounterCompareAndIncrement tests a stale expected value leaves the
+// counter unchanged, and that retrying loops lose no increments
+func TestCounterCompareAndIncrement(t *testing.T) {
+	c := NewShardedCounter[string](16)
+	c.Increment("seq", 5)
+
+	expected := c.Get("seq")
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.Increment("seq", 1) // lands between the read and the compare
+	}()
+	<-done
+
+	if got, ok := c.CompareAndIncrement("seq", expected, 10); ok || got != 6 {
+		t.Errorf("Expected the stale compare to fail with current value 6, got %d, %v", got, ok)
+	}
+	if got := c.Get("seq"); got != 6 {
+		t.Errorf("Expected the failed call to leave 6, got %d", got)
+	}
+	if got, ok := c.CompareAndIncrement("seq", 6, 10); !ok || got != 16 {
+		t.Errorf("Expected 16, true, got %d, %v", got, ok)
+	}
+
+	const numGoroutines = 20
+	const incrementsPerGoroutine = 50
+	var wg sync.WaitGroup
+	for i := 0; i < numGoroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < incrementsPerGoroutine; j++ {
+				current := c.Get("cas")
+				for {
+					var ok bool
+					if current, ok = c.CompareAndIncrement("cas", current, 1); ok {
+						break
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := c.Get("cas"); got != numGoroutines*incrementsPerGoroutine {
+		t.Errorf("Expected %d, got %d", numGoroutines*incrementsPerGoroutine, got)
+	}
+}
+
+// TestCounterAddMany tests concurrent batch increments across many keys are not lost
+func TestCounterAddMany(t *testing.T) {
+	c := NewShardedCounter[string](8)
+	deltas := map[string]int64{
+		"/users": 1, "/orders": 2, "/cart": 3, "/login": 4,
+		"/logout": 5, "/search": 6, "/health": 7, "/metrics": 8,
+	}
+
+	first := c.AddMany(deltas)
+	for key, delta := range deltas {
+		if first[key] != delta {
+			t.Errorf("Key %s: expected first total %d, got %d", key, delta, first[key])
+		}
+	}
+
+	const numGoroutines = 20
+	const batchesPerGoroutine = 50
+	var wg sync.WaitGroup
+	for i := 0; i < numGoroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < batchesPerGoroutine; j++ {
+				c.AddMany(deltas)
+				// Mixed with single increments on the same keys
+				c.Increment("/users", 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	const batches = numGoroutines*batchesPerGoroutine + 1
+	for key, delta := range deltas {
+		want := delta * batches
+		if key == "/users" {
+			want += numGoroutines * batchesPerGoroutine
+		}
+		if got := c.Get(key); got != want {
+			t.Errorf("Key %s: expected %d, got %d", key, want, got)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -10,7 +11,7 @@ import (
 func main() {
 	// Create a sharded map with 64 shards for high concurrency
 	// This simulates a rate limiter tracking request counts per user
-	rateLimiter := NewShardedMap[string, int](64)
+	rateLimiter := NewShardedMap[string, *atomic.Int64](64)
 	
 	var wg sync.WaitGroup
 	const numUsers = 1000
@@ -22,15 +23,13 @@ func main() {
 		wg.Add(1)
 		go func(userID int) {
 			defer wg.Done()
+			// LoadOrStore hands every goroutine the same counter, so
+			// increments can't be lost the way a Get followed by a Set
+			// would lose them
+			count, _ := rateLimiter.LoadOrStore(fmt.Sprintf("user-%d", userID), new(atomic.Int64))
 			for j := 0; j < requestsPerUser; j++ {
-				// Check current count
-				count, exists := rateLimiter.Get(fmt.Sprintf("user-%d", userID))
-				if !exists {
-					count = 0
-				}
-				
 				// Increment (simulating rate limit check)
-				rateLimiter.Set(fmt.Sprintf("user-%d", userID), count+1)
+				count.Add(1)
 			}
 		}(i)
 	}
@@ -42,7 +41,7 @@ func main() {
 		numUsers, requestsPerUser, numUsers*requestsPerUser)
 	fmt.Printf("Time taken: %v\n", duration)
 	fmt.Printf("Throughput: %.0f ops/sec\n", 
-		float64(numUsers*requestsPerUser*2)/duration.Seconds())
+		float64(numUsers*requestsPerUser)/duration.Seconds())
 	
 	// Verify final counts
 	allKeys := rateLimiter.Keys()
@@ -52,7 +51,7 @@ func main() {
 	for i := 0; i < 5 && i < len(allKeys); i++ {
 		userID := allKeys[i]
 		count, _ := rateLimiter.Get(userID)
-		fmt.Printf("  %s: %d requests\n", userID, count)
+		fmt.Printf("  %s: %d requests\n", userID, count.Load())
 	}
 }
 
@@ -0,0 +1,89 @@
+package main
+
+// KeyHandle is a ShardedMap key with its shard index already computed.
+// Hot loops that touch the same key repeatedly can use a handle to skip
+// rehashing the key on every call. A handle is a small value and is safe
+// for concurrent use; it stays valid for the lifetime of the map.
+type KeyHandle[K comparable, V any] struct {
+	sm         *ShardedMap[K, V]
+	key        K
+	shardIndex uint64
+}
+
+// Handle returns a KeyHandle for key.
+func (sm *ShardedMap[K, V]) Handle(key K) KeyHandle[K, V] {
+	return KeyHandle[K, V]{sm: sm, key: key, shardIndex: sm.getShardIndex(key)}
+}
+
+// Key returns the key the handle refers to.
+func (h KeyHandle[K, V]) Key() K {
+	return h.key
+}
+
+// Get retrieves the key's value. Returns the value and a boolean indicating existence.
+func (h KeyHandle[K, V]) Get() (V, bool) {
+	h.sm.shardMutex[h.shardIndex].RLock()
+	defer h.sm.shardMutex[h.shardIndex].RUnlock()
+
+	value, exists := h.sm.shards[h.shardIndex][h.key]
+	h.sm.ops[h.shardIndex].gets.Add(1)
+	h.sm.recordLookup(exists)
+	h.sm.accessed(h.shardIndex, h.key, exists)
+	if exists {
+		value = h.sm.cloned(value)
+	}
+	return value, exists
+}
+
+// Set inserts or updates the key's value.
+func (h KeyHandle[K, V]) Set(value V) {
+	h.sm.shardMutex[h.shardIndex].Lock()
+	defer h.sm.shardMutex[h.shardIndex].Unlock()
+
+	h.sm.shards[h.shardIndex][h.key] = value
+	h.sm.ops[h.shardIndex].sets.Add(1)
+	h.sm.touch(h.shardIndex, h.key)
+}
+
+// Delete removes the key from the map.
+func (h KeyHandle[K, V]) Delete() {
+	h.sm.shardMutex[h.shardIndex].Lock()
+	defer h.sm.shardMutex[h.shardIndex].Unlock()
+
+	delete(h.sm.shards[h.shardIndex], h.key)
+	h.sm.ops[h.shardIndex].deletes.Add(1)
+	h.sm.forget(h.shardIndex, h.key)
+}
+
+// CounterHandle is a ShardedCounter key with its shard index already computed.
+type CounterHandle[K comparable] struct {
+	h KeyHandle[K, int64]
+}
+
+// Handle returns a CounterHandle for key.
+func (c *ShardedCounter[K]) Handle(key K) CounterHandle[K] {
+	return CounterHandle[K]{h: c.sm.Handle(key)}
+}
+
+// Get returns the current value, or 0 if it has never been incremented.
+func (ch CounterHandle[K]) Get() int64 {
+	value, _ := ch.h.Get()
+	return value
+}
+
+// Increment adds delta to the counter and returns the new value.
+func (ch CounterHandle[K]) Increment(delta int64) int64 {
+	h := ch.h
+	h.sm.shardMutex[h.shardIndex].Lock()
+	defer h.sm.shardMutex[h.shardIndex].Unlock()
+
+	value := h.sm.shards[h.shardIndex][h.key] + delta
+	h.sm.shards[h.shardIndex][h.key] = value
+	h.sm.touch(h.shardIndex, h.key)
+	return value
+}
+
+// Delete removes the counter.
+func (ch CounterHandle[K]) Delete() {
+	ch.h.Delete()
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// countingHasher is the default string hashing, counting how often a key is
+// hashed.
+type countingHasher struct {
+	calls atomic.Int64
+}
+
+func (h *countingHasher) Hash(key string) uint64 {
+	h.calls.Add(1)
+	return hashKey(key, 0)
+}
+
+// TestKeyHandle tests that handle operations match keyed operations
+func TestKeyHandle(t *testing.T) {
+	sm := NewShardedMap[string, int](16)
+	h := sm.Handle("user-1")
+
+	if _, exists := h.Get(); exists {
+		t.Error("Expected missing key before Set")
+	}
+
+	h.Set(42)
+	if val, exists := sm.Get("user-1"); !exists || val != 42 {
+		t.Errorf("Expected keyed Get to see handle Set, got %d, exists=%v", val, exists)
+	}
+
+	sm.Set("user-1", 7)
+	if val, _ := h.Get(); val != 7 {
+		t.Errorf("Expected handle Get to see keyed Set, got %d", val)
+	}
+
+	h.Delete()
+	if _, exists := sm.Get("user-1"); exists {
+		t.Error("Expected key to be deleted through the handle")
+	}
+}
+
+// TestCounterHandleConcurrentIncrement tests that handle increments are not lost
+func TestCounterHandleConcurrentIncrement(t *testing.T) {
+	c := NewShardedCounter[string](16)
+
+	const goroutines = 50
+	const increments = 1000
+
+	var wg sync.WaitGroup
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			h := c.Handle("user-1")
+			for j := 0; j < increments; j++ {
+				h.Increment(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := c.Get("user-1"); got != goroutines*increments {
+		t.Errorf("Expected %d, got %d", goroutines*increments, got)
+	}
+}
+
+// TestHandleHashesOnce tests that a handle hashes its key only when created,
+// where keyed calls hash it on every call
+func TestHandleHashesOnce(t *testing.T) {
+	hasher := &countingHasher{}
+	sm := NewShardedMap[string, int](16, WithHasher[string, int](hasher))
+
+	for i := 0; i < 10; i++ {
+		count, _ := sm.Get("user-1")
+		sm.Set("user-1", count+1)
+	}
+	if got := hasher.calls.Load(); got != 20 {
+		t.Errorf("Expected keyed calls to hash 20 times, got %d", got)
+	}
+
+	hasher.calls.Store(0)
+	h := sm.Handle("user-1")
+	for i := 0; i < 10; i++ {
+		count, _ := h.Get()
+		h.Set(count + 1)
+	}
+	if got := hasher.calls.Load(); got != 1 {
+		t.Errorf("Expected the handle to hash once, got %d", got)
+	}
+}
+
+// BenchmarkKeyedGetSet is the rate limiter loop hashing the key on every call.
+func BenchmarkKeyedGetSet(b *testing.B) {
+	hasher := &countingHasher{}
+	sm := NewShardedMap[string, int](64, WithHasher[string, int](hasher))
+	key := "user-1234567890"
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		count, _ := sm.Get(key)
+		sm.Set(key, count+1)
+	}
+	b.ReportMetric(float64(hasher.calls.Load())/float64(b.N), "hashes/op")
+}
+
+// BenchmarkHandleGetSet is the same loop hashing the key once up front.
+func BenchmarkHandleGetSet(b *testing.B) {
+	hasher := &countingHasher{}
+	sm := NewShardedMap[string, int](64, WithHasher[string, int](hasher))
+	h := sm.Handle("user-1234567890")
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		count, _ := h.Get()
+		h.Set(count + 1)
+	}
+	b.ReportMetric(float64(hasher.calls.Load())/float64(b.N), "hashes/op")
+}
@@ -0,0 +1,29 @@
+package main
+
+// Hasher hashes keys for shard placement. Keys that are equal must hash
+// identically. The default hashing special-cases strings and integers and
+// otherwise hashes the key's raw memory, which breaks that rule for structs
+// holding strings or pointers: equal values can point at different memory.
+// Such keys need a Hasher.
+type Hasher[K comparable] interface {
+	Hash(key K) uint64
+}
+
+// HasherFunc adapts an ordinary function to the Hasher interface.
+type HasherFunc[K comparable] func(key K) uint64
+
+// Hash calls f(key).
+func (f HasherFunc[K]) Hash(key K) uint64 {
+	return f(key)
+}
+
+// WithHasher places keys using h instead of the default hashing. The map's
+// seed is still mixed into each hash, so placement stays unpredictable even
+// if h is not, and distinct hash values spread across the shards even if
+// they differ only in a few bits. Seeding can't separate keys h collides,
+// though: keys with the same hash always share a shard.
+func WithHasher[K comparable, V any](h Hasher[K]) Option[K, V] {
+	return func(sm *ShardedMap[K, V]) {
+		sm.hasher = h
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"hash/fnv"
+	"strings"
+	"testing"
+)
+
+// userKey is a struct key whose string field defeats hashing by memory:
+// equal values may hold different string pointers.
+type userKey struct {
+	tenant string
+	id     int
+}
+
+// TestWithHasher tests that equal struct keys built separately are found
+// through a Hasher, and that placement follows it
+func TestWithHasher(t *testing.T) {
+	hasher := HasherFunc[userKey](func(k userKey) uint64 {
+		h := fnv.New64a()
+		h.Write([]byte(k.tenant))
+		return h.Sum64() ^ uint64(k.id)
+	})
+	sm := NewShardedMap[userKey, int](16, WithHasher[userKey, int](hasher))
+
+	for i := 0; i < 100; i++ {
+		sm.Set(userKey{tenant: strings.Repeat("acme", 2), id: i}, i)
+	}
+	for i := 0; i < 100; i++ {
+		// A freshly built string, equal to but not sharing memory with the
+		// one stored
+		key := userKey{tenant: "acme" + strings.ToLower("ACME"), id: i}
+		if val, exists := sm.Get(key); !exists || val != i {
+			t.Errorf("Key %d: expected %d, got %d, exists=%v", i, i, val, exists)
+		}
+	}
+	if got := sm.Len(); got != 100 {
+		t.Errorf("Expected 100 entries, got %d", got)
+	}
+
+	// Every key with the same hash must share a shard
+	same := NewShardedMap[userKey, int](16, WithHasher[userKey, int](HasherFunc[userKey](func(userKey) uint64 { return 7 })))
+	want := same.getShardIndex(userKey{tenant: "a"})
+	for i := 0; i < 10; i++ {
+		if got := same.getShardIndex(userKey{tenant: "b", id: i}); got != want {
+			t.Fatalf("Expected every key in shard %d, got %d", want, got)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+)
+
+// errNoLoader is returned by GetOrLoad on a map created without WithLoader.
+var errNoLoader = errors.New("no loader configured")
+
+// errLoaderPanicked is returned to callers waiting on a load whose loader
+// panicked.
+var errLoaderPanicked = errors.New("loader panicked")
+
+// loadCall is a load in progress. Callers that miss on the same key while it
+// runs wait on done and share its result instead of loading again.
+type loadCall[V any] struct {
+	done  chan struct{}
+	value V
+	err   error
+}
+
+// WithLoader makes GetOrLoad read through to load on a miss, so the map can
+// serve as a cache in front of a slower source.
+func WithLoader[K comparable, V any](load func(context.Context, K) (V, error)) Option[K, V] {
+	return func(sm *ShardedMap[K, V]) {
+		sm.loader = load
+		sm.loads = make([]map[K]*loadCall[V], sm.shardCount)
+		for i := range sm.loads {
+			sm.loads[i] = make(map[K]*loadCall[V])
+		}
+	}
+}
+
+// GetOrLoad returns key's value, loading and storing it first if it is
+// absent. Concurrent misses on the same key share a single load: the first
+// caller runs the loader with its own ctx, and the others wait for its
+// result, or for their own ctx to be done. A failed load stores nothing, so
+// the next call tries again. The map must have been created with WithLoader.
+// Hits take only the shard's read lock, as Get does.
+func (sm *ShardedMap[K, V]) GetOrLoad(ctx context.Context, key K) (V, error) {
+	var zero V
+	if sm.loader == nil {
+		return zero, errNoLoader
+	}
+
+	shardIndex := sm.getShardIndex(key)
+	sm.shardMutex[shardIndex].RLock()
+	if value, exists := sm.shards[shardIndex][key]; exists {
+		sm.recordLookup(true)
+		value = sm.cloned(value)
+		sm.shardMutex[shardIndex].RUnlock()
+		return value, nil
+	}
+	sm.shardMutex[shardIndex].RUnlock()
+
+	// Check again under the write lock: a load may have stored the key since
+	sm.shardMutex[shardIndex].Lock()
+	if value, exists := sm.shards[shardIndex][key]; exists {
+		sm.recordLookup(true)
+		value = sm.cloned(value)
+		sm.shardMutex[shardIndex].Unlock()
+		return value, nil
+	}
+	sm.recordLookup(false)
+
+	call, loading := sm.loads[shardIndex][key]
+	if !loading {
+		call = &loadCall[V]{done: make(chan struct{})}
+		sm.loads[shardIndex][key] = call
+	}
+	sm.shardMutex[shardIndex].Unlock()
+
+	if !loading {
+		sm.load(ctx, shardIndex, key, call)
+	} else {
+		select {
+		case <-call.done:
+		case <-ctx.Done():
+			return zero, ctx.Err()
+		}
+	}
+
+	if call.err != nil {
+		return zero, call.err
+	}
+	return sm.cloned(call.value), nil
+}
+
+// load runs the loader for key and publishes the result to call's waiters,
+// storing the value on success. The loader runs without any lock held.
+func (sm *ShardedMap[K, V]) load(ctx context.Context, shardIndex uint64, key K, call *loadCall[V]) {
+	// Deferred so waiters are released even if the loader panics, in which
+	// case they get errLoaderPanicked while the panic reaches the caller
+	call.err = errLoaderPanicked
+	defer func() {
+		sm.shardMutex[shardIndex].Lock()
+		if call.err == nil {
+			sm.shards[shardIndex][key] = call.value
+			sm.touch(shardIndex, key)
+		}
+		delete(sm.loads[shardIndex], key)
+		sm.shardMutex[shardIndex].Unlock()
+
+		close(call.done)
+	}()
+
+	call.value, call.err = sm.loader(ctx, key)
+}
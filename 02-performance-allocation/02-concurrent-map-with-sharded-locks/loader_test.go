@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestGetOrLoadSingleFlight tests that concurrent misses on a key load it once
+func TestGetOrLoadSingleFlight(t *testing.T) {
+	var mu sync.Mutex
+	loads := make(map[int]int)
+	sm := NewShardedMap[int, string](8, WithLoader(func(_ context.Context, key int) (string, error) {
+		mu.Lock()
+		loads[key]++
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond) // keep the load in flight while others miss
+		return fmt.Sprintf("value-%d", key), nil
+	}))
+
+	const numKeys = 10
+	const callersPerKey = 20
+	var wg sync.WaitGroup
+	for key := 0; key < numKeys; key++ {
+		for c := 0; c < callersPerKey; c++ {
+			wg.Add(1)
+			go func(key int) {
+				defer wg.Done()
+				value, err := sm.GetOrLoad(context.Background(), key)
+				if err != nil {
+					t.Errorf("Key %d: unexpected error: %v", key, err)
+					return
+				}
+				if want := fmt.Sprintf("value-%d", key); value != want {
+					t.Errorf("Key %d: expected %q, got %q", key, want, value)
+				}
+			}(key)
+		}
+	}
+	wg.Wait()
+
+	for key := 0; key < numKeys; key++ {
+		if loads[key] != 1 {
+			t.Errorf("Key %d: expected 1 load, got %d", key, loads[key])
+		}
+	}
+	if value, ok := sm.Get(3); !ok || value != "value-3" {
+		t.Errorf("Expected the loaded value to be stored, got %q, exists=%v", value, ok)
+	}
+}
+
+// TestGetOrLoadErrorNotCached tests that a failed load is retried by the next call
+func TestGetOrLoadErrorNotCached(t *testing.T) {
+	var calls atomic.Int32
+	errUnavailable := errors.New("source unavailable")
+	sm := NewShardedMap[string, int](4, WithLoader(func(context.Context, string) (int, error) {
+		if calls.Add(1) == 1 {
+			return 0, errUnavailable
+		}
+		return 42, nil
+	}))
+
+	if _, err := sm.GetOrLoad(context.Background(), "a"); !errors.Is(err, errUnavailable) {
+		t.Fatalf("Expected the loader's error, got %v", err)
+	}
+	if _, ok := sm.Get("a"); ok {
+		t.Error("Expected nothing stored after a failed load")
+	}
+	if value, err := sm.GetOrLoad(context.Background(), "a"); err != nil || value != 42 {
+		t.Errorf("Expected a retried load to return 42, got %d, %v", value, err)
+	}
+
+	if _, err := NewShardedMap[string, int](4).GetOrLoad(context.Background(), "a"); err == nil {
+		t.Error("Expected an error without WithLoader")
+	}
+}
+
+// TestGetOrLoadHitTakesReadLock tests that a cached key is served while
+// another reader holds its shard
+func TestGetOrLoadHitTakesReadLock(t *testing.T) {
+	sm := NewShardedMap[string, int](4, WithLoader(func(context.Context, string) (int, error) {
+		return 0, errors.New("unexpected load")
+	}))
+	sm.Set("a", 1)
+
+	shardIndex := sm.getShardIndex("a")
+	sm.shardMutex[shardIndex].RLock()
+	defer sm.shardMutex[shardIndex].RUnlock()
+
+	done := make(chan int, 1)
+	go func() {
+		value, _ := sm.GetOrLoad(context.Background(), "a")
+		done <- value
+	}()
+
+	select {
+	case value := <-done:
+		if value != 1 {
+			t.Errorf("Expected 1, got %d", value)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the hit not to wait for the shard's readers")
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrLockTimeout is returned by GetWithTimeout and SetWithTimeout when the
+// key's shard lock couldn't be acquired within the map's lock timeout.
+var ErrLockTimeout = errors.New("timed out acquiring shard lock")
+
+// errLockTimeoutDisabled is returned by GetWithTimeout and SetWithTimeout on
+// a map created without WithLockTimeout.
+var errLockTimeoutDisabled = errors.New("lock timeout not enabled")
+
+// maxLockBackoff caps the sleep between attempts to take a contended lock.
+const maxLockBackoff = time.Millisecond
+
+// WithLockTimeout bounds how long GetWithTimeout and SetWithTimeout wait for
+// a shard lock, so a lock held abnormally long, e.g. by a slow callback,
+// surfaces as ErrLockTimeout instead of a silent hang. Get and Set still
+// wait indefinitely.
+func WithLockTimeout[K comparable, V any](d time.Duration) Option[K, V] {
+	return func(sm *ShardedMap[K, V]) {
+		sm.lockTimeout = d
+	}
+}
+
+// GetWithTimeout is a Get that gives up with ErrLockTimeout if the shard's
+// read lock can't be taken within the lock timeout. The map must have been
+// created with WithLockTimeout.
+func (sm *ShardedMap[K, V]) GetWithTimeout(key K) (V, bool, error) {
+	var zero V
+	if sm.lockTimeout <= 0 {
+		return zero, false, errLockTimeoutDisabled
+	}
+
+	shardIndex := sm.getShardIndex(key)
+	if !sm.acquireWithin(sm.shardMutex[shardIndex].TryRLock) {
+		return zero, false, ErrLockTimeout
+	}
+	defer sm.shardMutex[shardIndex].RUnlock()
+
+	value, exists := sm.shards[shardIndex][key]
+	sm.ops[shardIndex].gets.Add(1)
+	sm.recordLookup(exists)
+	sm.accessed(shardIndex, key, exists)
+	if exists {
+		value = sm.cloned(value)
+	}
+	return value, exists, nil
+}
+
+// SetWithTimeout is a Set that gives up with ErrLockTimeout, storing
+// nothing, if the shard's write lock can't be taken within the lock timeout.
+// The map must have been created with WithLockTimeout.
+func (sm *ShardedMap[K, V]) SetWithTimeout(key K, value V) error {
+	if sm.lockTimeout <= 0 {
+		return errLockTimeoutDisabled
+	}
+
+	shardIndex := sm.getShardIndex(key)
+	if !sm.acquireWithin(sm.shardMutex[shardIndex].TryLock) {
+		return ErrLockTimeout
+	}
+	defer sm.shardMutex[shardIndex].Unlock()
+
+	sm.shards[shardIndex][key] = value
+	sm.ops[shardIndex].sets.Add(1)
+	sm.touch(shardIndex, key)
+	return nil
+}
+
+// acquireWithin retries tryLock, backing off exponentially between attempts,
+// until it succeeds or the lock timeout has passed.
+func (sm *ShardedMap[K, V]) acquireWithin(tryLock func() bool) bool {
+	deadline := time.Now().Add(sm.lockTimeout)
+	backoff := time.Microsecond
+	for !tryLock() {
+		if !time.Now().Before(deadline) {
+			return false
+		}
+		time.Sleep(backoff)
+		backoff = min(backoff*2, maxLockBackoff)
+	}
+	return true
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestLockTimeout tests that a held shard lock makes timed operations fail
+// promptly instead of blocking, and that they succeed once it is released
+func TestLockTimeout(t *testing.T) {
+	sm := NewShardedMap[string, int](4, WithLockTimeout[string, int](20*time.Millisecond))
+	sm.Set("key", 1)
+
+	// Simulate a caller stuck while holding the key's shard lock
+	shardIndex := sm.getShardIndex("key")
+	sm.shardMutex[shardIndex].Lock()
+
+	start := time.Now()
+	if err := sm.SetWithTimeout("key", 2); !errors.Is(err, ErrLockTimeout) {
+		t.Errorf("Expected ErrLockTimeout from SetWithTimeout, got %v", err)
+	}
+	if _, _, err := sm.GetWithTimeout("key"); !errors.Is(err, ErrLockTimeout) {
+		t.Errorf("Expected ErrLockTimeout from GetWithTimeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected both calls to give up after about 20ms each, took %v", elapsed)
+	}
+
+	sm.shardMutex[shardIndex].Unlock()
+
+	if val, _ := sm.Get("key"); val != 1 {
+		t.Errorf("Expected the timed out Set to store nothing, got %d", val)
+	}
+	if err := sm.SetWithTimeout("key", 3); err != nil {
+		t.Errorf("Expected SetWithTimeout to succeed once unlocked, got %v", err)
+	}
+	if val, exists, err := sm.GetWithTimeout("key"); err != nil || !exists || val != 3 {
+		t.Errorf("Expected 3, true, nil, got %d, %v, %v", val, exists, err)
+	}
+}
+
+// TestLockTimeoutDisabled tests that the timed operations require WithLockTimeout
+func TestLockTimeoutDisabled(t *testing.T) {
+	sm := NewShardedMap[string, int](4)
+	if err := sm.SetWithTimeout("key", 1); !errors.Is(err, errLockTimeoutDisabled) {
+		t.Errorf("Expected errLockTimeoutDisabled, got %v", err)
+	}
+	if _, _, err := sm.GetWithTimeout("key"); !errors.Is(err, errLockTimeoutDisabled) {
+		t.Errorf("Expected errLockTimeoutDisabled, got %v", err)
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"math/rand/v2"
+	"sync"
+)
+
+// lruNode is an entry in a shard's recency list. The list is intrusive: the
+// node is both the map value and the list link, so moving or evicting an
+// entry needs no allocation or search.
+type lruNode[K comparable, V any] struct {
+	key        K
+	value      V
+	prev, next *lruNode[K, V]
+}
+
+// lruShard is one lock domain of a ShardedLRU. root is a sentinel: root.next
+// is the most recently used entry and root.prev the least.
+type lruShard[K comparable, V any] struct {
+	mu       sync.Mutex
+	entries  map[K]*lruNode[K, V]
+	root     lruNode[K, V]
+	capacity int
+}
+
+// ShardedLRU is a fixed-capacity cache that evicts the least recently used
+// entry when full. Recency is tracked per shard, so eviction is LRU within a
+// shard rather than across the whole cache; in exchange, operations on
+// different shards never contend.
+type ShardedLRU[K comparable, V any] struct {
+	shards     []lruShard[K, V]
+	shardCount uint64
+	seed       uint64
+}
+
+// NewShardedLRU creates a ShardedLRU holding about capacity entries, split
+// evenly across shardCount shards. Every shard holds at least one entry.
+func NewShardedLRU[K comparable, V any](shardCount, capacity int) *ShardedLRU[K, V] {
+	if shardCount < 1 {
+		shardCount = 1
+	}
+	perShard := max((capacity+shardCount-1)/shardCount, 1)
+	lru := &ShardedLRU[K, V]{
+		shards:     make([]lruShard[K, V], shardCount),
+		shardCount: uint64(shardCount),
+		seed:       rand.Uint64(),
+	}
+	for i := range lru.shards {
+		shard := &lru.shards[i]
+		shard.entries = make(map[K]*lruNode[K, V], perShard)
+		shard.root.next = &shard.root
+		shard.root.prev = &shard.root
+		shard.capacity = perShard
+	}
+	return lru
+}
+
+func (lru *ShardedLRU[K, V]) shardFor(key K) *lruShard[K, V] {
+	return &lru.shards[hashKey(key, lru.seed)%lru.shardCount]
+}
+
+// Get retrieves a value and marks it as most recently used.
+// Because it reorders the list, Get takes the shard's write lock.
+func (lru *ShardedLRU[K, V]) Get(key K) (V, bool) {
+	shard := lru.shardFor(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	node, exists := shard.entries[key]
+	if !exists {
+		var zero V
+		return zero, false
+	}
+	shard.moveToFront(node)
+	return node.value, true
+}
+
+// Add inserts or updates a value and marks it as most recently used. If the
+// shard was full, its least recently used entry is evicted and evicted is true.
+func (lru *ShardedLRU[K, V]) Add(key K, value V) (evicted bool) {
+	shard := lru.shardFor(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	if node, exists := shard.entries[key]; exists {
+		node.value = value
+		shard.moveToFront(node)
+		return false
+	}
+
+	if len(shard.entries) >= shard.capacity {
+		// Reuse the evicted node for the new entry
+		node := shard.root.prev
+		shard.unlink(node)
+		delete(shard.entries, node.key)
+		node.key, node.value = key, value
+		shard.pushFront(node)
+		shard.entries[key] = node
+		return true
+	}
+
+	node := &lruNode[K, V]{key: key, value: value}
+	shard.pushFront(node)
+	shard.entries[key] = node
+	return false
+}
+
+// Len returns the number of entries across all shards.
+func (lru *ShardedLRU[K, V]) Len() int {
+	n := 0
+	for i := range lru.shards {
+		lru.shards[i].mu.Lock()
+		n += len(lru.shards[i].entries)
+		lru.shards[i].mu.Unlock()
+	}
+	return n
+}
+
+// The list helpers must be called with the shard lock held.
+
+func (s *lruShard[K, V]) pushFront(node *lruNode[K, V]) {
+	node.prev = &s.root
+	node.next = s.root.next
+	s.root.next.prev = node
+	s.root.next = node
+}
+
+func (s *lruShard[K, V]) unlink(node *lruNode[K, V]) {
+	node.prev.next = node.next
+	node.next.prev = node.prev
+	node.prev, node.next = nil, nil
+}
+
+func (s *lruShard[K, V]) moveToFront(node *lruNode[K, V]) {
+	if s.root.next == node {
+		return
+	}
+	s.unlink(node)
+	s.pushFront(node)
+}
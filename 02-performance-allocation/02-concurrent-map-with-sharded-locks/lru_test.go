@@ -0,0 +1,110 @@
+package main
+
+import (
+	"sync"
+	"testing"
+)
+
+// TestLRUEvictionOrder tests that the least recently used entry is evicted first
+func TestLRUEvictionOrder(t *testing.T) {
+	lru := NewShardedLRU[string, int](1, 3)
+	lru.Add("a", 1)
+	lru.Add("b", 2)
+	lru.Add("c", 3)
+
+	// Touch "a" so "b" becomes the oldest
+	if val, ok := lru.Get("a"); !ok || val != 1 {
+		t.Fatalf("Expected a=1, got %d, exists=%v", val, ok)
+	}
+	if !lru.Add("d", 4) {
+		t.Error("Expected adding to a full shard to evict")
+	}
+	if _, ok := lru.Get("b"); ok {
+		t.Error("Expected b to be evicted as least recently used")
+	}
+
+	// Updating an entry also counts as a use
+	lru.Add("c", 30)
+	lru.Add("e", 5)
+	if _, ok := lru.Get("a"); ok {
+		t.Error("Expected a to be evicted after c was updated")
+	}
+	for key, want := range map[string]int{"c": 30, "d": 4, "e": 5} {
+		if val, ok := lru.Get(key); !ok || val != want {
+			t.Errorf("Expected %s=%d, got %d, exists=%v", key, want, val, ok)
+		}
+	}
+	if n := lru.Len(); n != 3 {
+		t.Errorf("Expected 3 entries, got %d", n)
+	}
+}
+
+// TestLRUEvictionPerShard tests that eviction only considers the full shard's own entries
+func TestLRUEvictionPerShard(t *testing.T) {
+	lru := NewShardedLRU[int, int](4, 8)
+
+	// Group keys by shard; two per shard fits exactly
+	byShard := make(map[*lruShard[int, int]][]int)
+	for k := 0; len(byShard) < 4 || !allFull(byShard, 3); k++ {
+		shard := lru.shardFor(k)
+		if len(byShard[shard]) < 3 {
+			byShard[shard] = append(byShard[shard], k)
+		}
+	}
+
+	for _, keys := range byShard {
+		lru.Add(keys[0], keys[0])
+		lru.Add(keys[1], keys[1])
+	}
+	for _, keys := range byShard {
+		lru.Get(keys[0])
+		if !lru.Add(keys[2], keys[2]) {
+			t.Errorf("Expected the third key in a shard to evict")
+		}
+		if _, ok := lru.Get(keys[1]); ok {
+			t.Errorf("Expected key %d to be evicted from its shard", keys[1])
+		}
+		if _, ok := lru.Get(keys[0]); !ok {
+			t.Errorf("Expected recently used key %d to survive", keys[0])
+		}
+	}
+	if n := lru.Len(); n != 8 {
+		t.Errorf("Expected 8 entries, got %d", n)
+	}
+}
+
+func allFull(byShard map[*lruShard[int, int]][]int, n int) bool {
+	for _, keys := range byShard {
+		if len(keys) < n {
+			return false
+		}
+	}
+	return true
+}
+
+// TestLRUConcurrentAccess tests concurrent Get and Add stay within capacity
+func TestLRUConcurrentAccess(t *testing.T) {
+	const capacity = 64
+	lru := NewShardedLRU[int, int](8, capacity)
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 2000; i++ {
+				key := (g*31 + i) % 200
+				if val, ok := lru.Get(key); ok && val != key*2 {
+					t.Errorf("Key %d: expected %d, got %d", key, key*2, val)
+					return
+				}
+				lru.Add(key, key*2)
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if n := lru.Len(); n > capacity {
+		t.Errorf("Expected at most %d entries, got %d", capacity, n)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Meta describes an entry's history, as tracked by WithMetadata.
+// LastAccessed is the zero time if the entry has not been read.
+type Meta struct {
+	CreatedAt    time.Time
+	LastAccessed time.Time
+	AccessCount  uint64
+}
+
+// entryMeta is the tracked state behind Meta. The per-shard maps holding it
+// change only under the shard's write lock, but reads update the access
+// fields under the read lock, so those are atomic.
+type entryMeta struct {
+	createdAt  time.Time
+	lastAccess atomic.Int64 // unix nanoseconds, zero if never read
+	accesses   atomic.Uint64
+}
+
+// WithMetadata makes the map track when each entry was created and how often
+// and when it was last read, for reporting by GetWithMeta. An entry is
+// created when its key is stored while absent, and overwriting it keeps its
+// creation time. Reads through Get, TryGet, GetWithTimeout and KeyHandle.Get
+// count as accesses. Tracking costs a clock read per lookup and an extra map
+// insert per new key, so it is off by default.
+func WithMetadata[K comparable, V any]() Option[K, V] {
+	return func(sm *ShardedMap[K, V]) {
+		sm.metas = make([]map[K]*entryMeta, sm.shardCount)
+		for i := range sm.metas {
+			sm.metas[i] = make(map[K]*entryMeta)
+		}
+	}
+}
+
+// GetWithMeta returns key's value along with its metadata. Reading the
+// metadata doesn't count as an access. Without WithMetadata the Meta is
+// always zero.
+func (sm *ShardedMap[K, V]) GetWithMeta(key K) (V, Meta, bool) {
+	shardIndex := sm.getShardIndex(key)
+	sm.shardMutex[shardIndex].RLock()
+	defer sm.shardMutex[shardIndex].RUnlock()
+
+	value, exists := sm.shards[shardIndex][key]
+	if !exists {
+		return value, Meta{}, false
+	}
+
+	var meta Meta
+	if sm.metas != nil {
+		if m := sm.metas[shardIndex][key]; m != nil {
+			meta.CreatedAt = m.createdAt
+			meta.AccessCount = m.accesses.Load()
+			if last := m.lastAccess.Load(); last != 0 {
+				meta.LastAccessed = time.Unix(0, last)
+			}
+		}
+	}
+	return sm.cloned(value), meta, true
+}
+
+// created starts tracking key's metadata if it is new. The caller must hold
+// the shard's write lock.
+func (sm *ShardedMap[K, V]) created(shardIndex uint64, key K) {
+	if sm.metas == nil {
+		return
+	}
+	if _, tracked := sm.metas[shardIndex][key]; !tracked {
+		sm.metas[shardIndex][key] = &entryMeta{createdAt: time.Now()}
+	}
+}
+
+// accessed records a read of key when it exists. The caller must hold at
+// least the shard's read lock.
+func (sm *ShardedMap[K, V]) accessed(shardIndex uint64, key K, exists bool) {
+	if sm.metas == nil || !exists {
+		return
+	}
+	if m := sm.metas[shardIndex][key]; m != nil {
+		m.accesses.Add(1)
+		m.lastAccess.Store(time.Now().UnixNano())
+	}
+}
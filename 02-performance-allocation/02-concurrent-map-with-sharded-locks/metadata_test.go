@@ -0,0 +1,99 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestGetWithMeta tests that each Get counts an access while the creation
+// time stays fixed, even across overwrites
+func TestGetWithMeta(t *testing.T) {
+	sm := NewShardedMap[string, int](4, WithMetadata[string, int]())
+
+	before := time.Now()
+	sm.Set("key", 1)
+
+	_, meta, exists := sm.GetWithMeta("key")
+	if !exists {
+		t.Fatal("Expected key to exist")
+	}
+	created := meta.CreatedAt
+	if created.Before(before) || created.After(time.Now()) {
+		t.Errorf("Expected CreatedAt around the Set, got %v", created)
+	}
+	if meta.AccessCount != 0 || !meta.LastAccessed.IsZero() {
+		t.Errorf("Expected no accesses yet, got %+v", meta)
+	}
+
+	var lastAccessed time.Time
+	for i := 1; i <= 3; i++ {
+		sm.Get("key")
+		sm.Set("key", i) // overwriting must not reset CreatedAt
+
+		val, meta, _ := sm.GetWithMeta("key")
+		if val != i {
+			t.Errorf("Expected value %d, got %d", i, val)
+		}
+		if meta.AccessCount != uint64(i) {
+			t.Errorf("Expected access count %d, got %d", i, meta.AccessCount)
+		}
+		if !meta.CreatedAt.Equal(created) {
+			t.Errorf("Expected CreatedAt to stay %v, got %v", created, meta.CreatedAt)
+		}
+		if meta.LastAccessed.Before(lastAccessed) || meta.LastAccessed.Before(created) {
+			t.Errorf("Expected LastAccessed to advance, got %v after %v", meta.LastAccessed, lastAccessed)
+		}
+		lastAccessed = meta.LastAccessed
+	}
+
+	// A deleted and re-added key starts over
+	sm.Delete("key")
+	if _, _, exists := sm.GetWithMeta("key"); exists {
+		t.Error("Expected key to be gone after Delete")
+	}
+	sm.Set("key", 10)
+	if _, meta, _ := sm.GetWithMeta("key"); meta.AccessCount != 0 || meta.CreatedAt.Before(created) {
+		t.Errorf("Expected fresh metadata for the re-added key, got %+v", meta)
+	}
+}
+
+// TestGetWithMetaConcurrentReads tests that reads sharing the read lock
+// don't lose accesses
+func TestGetWithMetaConcurrentReads(t *testing.T) {
+	sm := NewShardedMap[string, int](4, WithMetadata[string, int]())
+	sm.Set("key", 1)
+
+	const numGoroutines = 10
+	const readsPerGoroutine = 100
+	var wg sync.WaitGroup
+	for i := 0; i < numGoroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < readsPerGoroutine; j++ {
+				sm.Get("key")
+			}
+		}()
+	}
+	wg.Wait()
+
+	if _, meta, _ := sm.GetWithMeta("key"); meta.AccessCount != numGoroutines*readsPerGoroutine {
+		t.Errorf("Expected %d accesses, got %d", numGoroutines*readsPerGoroutine, meta.AccessCount)
+	}
+}
+
+// TestGetWithMetaDisabled tests that Meta is zero without WithMetadata
+func TestGetWithMetaDisabled(t *testing.T) {
+	sm := NewShardedMap[string, int](4)
+	sm.Set("key", 1)
+	sm.Get("key")
+
+	val, meta, exists := sm.GetWithMeta("key")
+	if !exists || val != 1 {
+		t.Errorf("Expected 1, true, got %d, %v", val, exists)
+	}
+	if meta != (Meta{}) {
+		t.Errorf("Expected zero Meta, got %+v", meta)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// WithHitTracking makes lookups (Get, TryGet, GetWithTimeout, GetOrLoad and
+// KeyHandle.Get) count hits and misses, for reporting by WriteMetrics. A
+// GetOrLoad miss counts once, however its load ends. The counters are
+// map-wide atomics, so every lookup contends on them; tracking is off by
+// default.
+func WithHitTracking[K comparable, V any]() Option[K, V] {
+	return func(sm *ShardedMap[K, V]) {
+		sm.trackHits = true
+	}
+}
+
+// recordLookup counts a lookup's result when hit tracking is enabled.
+func (sm *ShardedMap[K, V]) recordLookup(hit bool) {
+	if !sm.trackHits {
+		return
+	}
+	if hit {
+		sm.hits.Add(1)
+	} else {
+		sm.misses.Add(1)
+	}
+}
+
+// WriteMetrics writes the map's entry counts, per shard and in total, in
+// the Prometheus text exposition format. Hit and miss counters are included
+// when the map was created with WithHitTracking. Shards are counted one at
+// a time, so under concurrent writes the total is a point-in-time estimate,
+// but it always equals the sum of the per-shard counts written with it.
+func (sm *ShardedMap[K, V]) WriteMetrics(w io.Writer) error {
+	counts := make([]int, len(sm.shards))
+	total := 0
+	for i := range sm.shards {
+		sm.shardMutex[i].RLock()
+		counts[i] = len(sm.shards[i])
+		sm.shardMutex[i].RUnlock()
+		total += counts[i]
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "# HELP sharded_map_shard_entries Number of entries in each shard.")
+	fmt.Fprintln(bw, "# TYPE sharded_map_shard_entries gauge")
+	for i, n := range counts {
+		fmt.Fprintf(bw, "sharded_map_shard_entries{shard=\"%d\"} %d\n", i, n)
+	}
+	fmt.Fprintln(bw, "# HELP sharded_map_entries Number of entries in the map.")
+	fmt.Fprintln(bw, "# TYPE sharded_map_entries gauge")
+	fmt.Fprintf(bw, "sharded_map_entries %d\n", total)
+
+	if sm.trackHits {
+		fmt.Fprintln(bw, "# HELP sharded_map_hits_total Lookups that found their key.")
+		fmt.Fprintln(bw, "# TYPE sharded_map_hits_total counter")
+		fmt.Fprintf(bw, "sharded_map_hits_total %d\n", sm.hits.Load())
+		fmt.Fprintln(bw, "# HELP sharded_map_misses_total Lookups that did not find their key.")
+		fmt.Fprintln(bw, "# TYPE sharded_map_misses_total counter")
+		fmt.Fprintf(bw, "sharded_map_misses_total %d\n", sm.misses.Load())
+	}
+	return bw.Flush()
+}
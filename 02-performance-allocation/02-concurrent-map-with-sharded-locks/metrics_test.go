@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// scrape parses Prometheus text output into sample values by series name
+func scrape(t *testing.T, out string) map[string]int {
+	t.Helper()
+	samples := make(map[string]int)
+	sc := bufio.NewScanner(strings.NewReader(out))
+	for sc.Scan() {
+		line := sc.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, value, ok := strings.Cut(line, " ")
+		if !ok {
+			t.Fatalf("Malformed sample line %q", line)
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			t.Fatalf("Non-integer value in %q", line)
+		}
+		samples[name] = n
+	}
+	return samples
+}
+
+// TestWriteMetrics tests that the exported entry counts match the map contents
+func TestWriteMetrics(t *testing.T) {
+	sm := NewShardedMap[int, int](4, WithHitTracking[int, int]())
+	for i := 0; i < 250; i++ {
+		sm.Set(i, i)
+	}
+	sm.Get(1)
+	sm.Get(2)
+	sm.Get(-1)
+
+	var out strings.Builder
+	if err := sm.WriteMetrics(&out); err != nil {
+		t.Fatalf("WriteMetrics failed: %v", err)
+	}
+	samples := scrape(t, out.String())
+
+	if got, want := samples["sharded_map_entries"], sm.Len(); got != want {
+		t.Errorf("Expected sharded_map_entries %d, got %d", want, got)
+	}
+	sum := 0
+	for i := 0; i < sm.NumShards(); i++ {
+		n, ok := samples[fmt.Sprintf("sharded_map_shard_entries{shard=\"%d\"}", i)]
+		if !ok {
+			t.Errorf("Missing entry count for shard %d", i)
+		}
+		sum += n
+	}
+	if sum != samples["sharded_map_entries"] {
+		t.Errorf("Expected shard counts to sum to the total %d, got %d", samples["sharded_map_entries"], sum)
+	}
+	if samples["sharded_map_hits_total"] != 2 || samples["sharded_map_misses_total"] != 1 {
+		t.Errorf("Expected 2 hits and 1 miss, got %d and %d",
+			samples["sharded_map_hits_total"], samples["sharded_map_misses_total"])
+	}
+
+	// Without tracking there is nothing to report for lookups
+	out.Reset()
+	NewShardedMap[int, int](4).WriteMetrics(&out)
+	if strings.Contains(out.String(), "hits_total") {
+		t.Error("Expected no hit counters without WithHitTracking")
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import "errors"
+
+// ErrConcurrentModification is returned by RangeChecked when the map was
+// written to while it was being iterated.
+var ErrConcurrentModification = errors.New("map modified during iteration")
+
+// errModTrackingDisabled is returned by RangeChecked on a map created
+// without WithModificationTracking.
+var errModTrackingDisabled = errors.New("modification tracking not enabled")
+
+// WithModificationTracking makes the map count every write, so that
+// RangeChecked can detect writes made during an iteration. The counter is a
+// single map-wide atomic, which every writer contends on, so it is off by
+// default.
+func WithModificationTracking[K comparable, V any]() Option[K, V] {
+	return func(sm *ShardedMap[K, V]) {
+		sm.trackMods = true
+	}
+}
+
+// modified records a write when modification tracking is enabled.
+func (sm *ShardedMap[K, V]) modified() {
+	if sm.trackMods {
+		sm.mods.Add(1)
+	}
+}
+
+// RangeChecked calls fn for every entry, stopping early if fn returns false.
+// It fails fast with ErrConcurrentModification as soon as it notices that
+// the map was written to since the iteration began, whether by fn or by
+// another goroutine; entries already passed to fn may then be stale.
+//
+// Each shard is copied under its read lock and fn is called without any
+// lock held, so fn may itself read or write the map. The map must have been
+// created with WithModificationTracking.
+func (sm *ShardedMap[K, V]) RangeChecked(fn func(K, V) bool) error {
+	if !sm.trackMods {
+		return errModTrackingDisabled
+	}
+
+	start := sm.mods.Load()
+	for i := range sm.shards {
+		snapshot := sm.ShardSnapshot(i)
+		for key, value := range snapshot {
+			if !fn(key, value) {
+				return nil
+			}
+		}
+		if sm.mods.Load() != start {
+			return ErrConcurrentModification
+		}
+	}
+	return nil
+}
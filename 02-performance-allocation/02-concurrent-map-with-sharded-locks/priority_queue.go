@@ -0,0 +1,110 @@
+package main
+
+import (
+	"cmp"
+	"container/heap"
+	"math/rand/v2"
+	"sync"
+)
+
+// pqItem is a single queued key with its priority.
+type pqItem[K comparable, P cmp.Ordered] struct {
+	key      K
+	priority P
+}
+
+// pqHeap is a min-heap of items ordered by priority, for use with container/heap.
+type pqHeap[K comparable, P cmp.Ordered] []pqItem[K, P]
+
+func (h pqHeap[K, P]) Len() int           { return len(h) }
+func (h pqHeap[K, P]) Less(i, j int) bool { return h[i].priority < h[j].priority }
+func (h pqHeap[K, P]) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *pqHeap[K, P]) Push(x any)        { *h = append(*h, x.(pqItem[K, P])) }
+func (h *pqHeap[K, P]) Pop() any {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[:n-1]
+	return item
+}
+
+// ShardedPriorityQueue is a concurrent min-priority queue that spreads items
+// across independently locked shards using the same key hashing as ShardedMap.
+//
+// Push only locks the key's shard. PopMin scans the shard heads and pops the
+// smallest one it saw; under concurrent pushes and pops the result is the
+// minimum at the time of the scan, so ordering is approximate rather than
+// strict while the queue is being mutated.
+type ShardedPriorityQueue[K comparable, P cmp.Ordered] struct {
+	heaps      []pqHeap[K, P]
+	shardMutex []sync.Mutex
+	shardCount uint64
+	seed       uint64
+}
+
+// NewShardedPriorityQueue creates a new ShardedPriorityQueue with the specified number of shards.
+func NewShardedPriorityQueue[K comparable, P cmp.Ordered](shardCount int) *ShardedPriorityQueue[K, P] {
+	if shardCount < 1 {
+		shardCount = 1
+	}
+	return &ShardedPriorityQueue[K, P]{
+		heaps:      make([]pqHeap[K, P], shardCount),
+		shardMutex: make([]sync.Mutex, shardCount),
+		shardCount: uint64(shardCount),
+		seed:       rand.Uint64(),
+	}
+}
+
+// Push adds key with the given priority. Lower priorities are popped first.
+func (pq *ShardedPriorityQueue[K, P]) Push(key K, priority P) {
+	shardIndex := hashKey(key, pq.seed) % pq.shardCount
+	pq.shardMutex[shardIndex].Lock()
+	defer pq.shardMutex[shardIndex].Unlock()
+
+	heap.Push(&pq.heaps[shardIndex], pqItem[K, P]{key: key, priority: priority})
+}
+
+// PopMin removes and returns the item with the lowest priority across all
+// shards. The boolean is false if the queue is empty.
+func (pq *ShardedPriorityQueue[K, P]) PopMin() (K, P, bool) {
+	for {
+		// Find the shard whose head is smallest, locking one shard at a time
+		best := -1
+		var bestPriority P
+		for i := range pq.heaps {
+			pq.shardMutex[i].Lock()
+			if len(pq.heaps[i]) > 0 {
+				if p := pq.heaps[i][0].priority; best == -1 || p < bestPriority {
+					best, bestPriority = i, p
+				}
+			}
+			pq.shardMutex[i].Unlock()
+		}
+
+		if best == -1 {
+			var zeroK K
+			var zeroP P
+			return zeroK, zeroP, false
+		}
+
+		// Another goroutine may have emptied the shard since the scan
+		pq.shardMutex[best].Lock()
+		if len(pq.heaps[best]) > 0 {
+			item := heap.Pop(&pq.heaps[best]).(pqItem[K, P])
+			pq.shardMutex[best].Unlock()
+			return item.key, item.priority, true
+		}
+		pq.shardMutex[best].Unlock()
+	}
+}
+
+// Len returns the total number of queued items.
+func (pq *ShardedPriorityQueue[K, P]) Len() int {
+	total := 0
+	for i := range pq.heaps {
+		pq.shardMutex[i].Lock()
+		total += len(pq.heaps[i])
+		pq.shardMutex[i].Unlock()
+	}
+	return total
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"testing"
+)
+
+// TestPriorityQueueOrder tests that items pushed concurrently pop in priority order
+func TestPriorityQueueOrder(t *testing.T) {
+	pq := NewShardedPriorityQueue[string, int](16)
+	const numGoroutines = 8
+	const itemsPerGoroutine = 500
+
+	var wg sync.WaitGroup
+	for g := 0; g < numGoroutines; g++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			for j := 0; j < itemsPerGoroutine; j++ {
+				pq.Push(fmt.Sprintf("job-%d-%d", id, j), rand.IntN(10000))
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if pq.Len() != numGoroutines*itemsPerGoroutine {
+		t.Fatalf("Expected %d items, got %d", numGoroutines*itemsPerGoroutine, pq.Len())
+	}
+
+	// With no concurrent pushes, PopMin is exact
+	last := -1
+	popped := 0
+	for {
+		_, priority, ok := pq.PopMin()
+		if !ok {
+			break
+		}
+		if priority < last {
+			t.Fatalf("PopMin out of order: %d after %d", priority, last)
+		}
+		last = priority
+		popped++
+	}
+
+	if popped != numGoroutines*itemsPerGoroutine {
+		t.Errorf("Expected to pop %d items, got %d", numGoroutines*itemsPerGoroutine, popped)
+	}
+}
+
+// TestPriorityQueueConcurrentPop tests that concurrent pops return every item exactly once
+func TestPriorityQueueConcurrentPop(t *testing.T) {
+	pq := NewShardedPriorityQueue[int, int](8)
+	const numItems = 2000
+	for i := 0; i < numItems; i++ {
+		pq.Push(i, rand.IntN(100))
+	}
+
+	var mu sync.Mutex
+	seen := make(map[int]int)
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				key, _, ok := pq.PopMin()
+				if !ok {
+					return
+				}
+				mu.Lock()
+				seen[key]++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(seen) != numItems {
+		t.Errorf("Expected %d distinct items, got %d", numItems, len(seen))
+	}
+	for key, count := range seen {
+		if count != 1 {
+			t.Errorf("Item %d popped %d times", key, count)
+		}
+	}
+}
+
+// TestPriorityQueueEmpty tests PopMin on an empty queue
+func TestPriorityQueueEmpty(t *testing.T) {
+	pq := NewShardedPriorityQueue[string, float64](4)
+	if _, _, ok := pq.PopMin(); ok {
+		t.Error("Expected PopMin on empty queue to report false")
+	}
+}
@@ -1,7 +1,12 @@
 package main
 
 import (
+	"context"
+	"math/bits"
+	"math/rand/v2"
 	"sync"
+	"sync/atomic"
+	"time"
 	"unsafe"
 )
 
@@ -11,18 +16,71 @@ type ShardedMap[K comparable, V any] struct {
 	shards     []map[K]V
 	shardMutex []sync.RWMutex
 	shardCount uint64
+	seed       uint64
+	hasher     Hasher[K]
+
+	// ops counts each shard's operations for Stats
+	ops []shardOps
+
+	// versions and clock are only used with WithVersioning
+	versions []map[K]uint64
+	clock    atomic.Uint64
+
+	// metas holds entry metadata when set by WithMetadata
+	metas []map[K]*entryMeta
+
+	// mods counts writes when trackMods is set by WithModificationTracking
+	trackMods bool
+	mods      atomic.Uint64
+
+	// hits and misses count lookups when trackHits is set by WithHitTracking
+	trackHits bool
+	hits      atomic.Uint64
+	misses    atomic.Uint64
+
+	// clone copies values on read when set by WithValueCloner
+	clone func(V) V
+
+	// loader and loads back GetOrLoad when set by WithLoader
+	loader func(context.Context, K) (V, error)
+	loads  []map[K]*loadCall[V]
+
+	// lockTimeout bounds GetWithTimeout and SetWithTimeout when set by
+	// WithLockTimeout
+	lockTimeout time.Duration
+}
+
+// Option configures a ShardedMap at construction time.
+type Option[K comparable, V any] func(*ShardedMap[K, V])
+
+// WithSeed fixes the hash seed instead of picking a random one.
+// Use it for reproducible shard placement in tests; production maps should
+// keep the random seed so key placement can't be predicted by clients.
+func WithSeed[K comparable, V any](seed uint64) Option[K, V] {
+	return func(sm *ShardedMap[K, V]) {
+		sm.seed = seed
+	}
 }
 
-// NewShardedMap creates a new ShardedMap with the specified number of shards.
-// shardCount should be a power of 2 for optimal distribution.
-func NewShardedMap[K comparable, V any](shardCount int) *ShardedMap[K, V] {
+// NewShardedMap creates a new ShardedMap with the specified number of shards,
+// rounded up to a power of 2 so a key's shard can be found by masking its
+// hash; ShardCount reports the count actually used. Each map gets a random
+// hash seed, so an attacker who knows the hashing scheme still can't craft
+// keys that all land in one shard.
+func NewShardedMap[K comparable, V any](shardCount int, opts ...Option[K, V]) *ShardedMap[K, V] {
 	if shardCount < 1 {
 		shardCount = 1
 	}
+	shardCount = 1 << bits.Len(uint(shardCount-1))
 	sm := &ShardedMap[K, V]{
 		shards:     make([]map[K]V, shardCount),
 		shardMutex: make([]sync.RWMutex, shardCount),
 		shardCount: uint64(shardCount),
+		seed:       rand.Uint64(),
+		ops:        make([]shardOps, shardCount),
+	}
+	for _, opt := range opts {
+		opt(sm)
 	}
 	for i := range sm.shards {
 		sm.shards[i] = make(map[K]V)
@@ -31,13 +89,14 @@ func NewShardedMap[K comparable, V any](shardCount int) *ShardedMap[K, V] {
 }
 
 // fnv64aHash computes FNV-1a 64-bit hash without allocations.
-// This is an inline implementation of FNV-1a algorithm.
-func fnv64aHash(data []byte) uint64 {
+// This is an inline implementation of FNV-1a algorithm, with the seed
+// mixed into the offset basis.
+func fnv64aHash(data []byte, seed uint64) uint64 {
 	const (
 		offset64 uint64 = 14695981039346656037
 		prime64  uint64 = 1099511628211
 	)
-	hash := offset64
+	hash := offset64 ^ seed
 	for _, b := range data {
 		hash ^= uint64(b)
 		hash *= prime64
@@ -45,60 +104,57 @@ func fnv64aHash(data []byte) uint64 {
 	return hash
 }
 
-// getShardIndex computes the shard index for a given key using FNV64 hashing.
-// This function is designed to avoid allocations in the hot path.
-func (sm *ShardedMap[K, V]) getShardIndex(key K) uint64 {
-	var hash uint64
+// mix64 scrambles an integer key using xxhash-style mixing for better
+// distribution. The seed is folded in before mixing.
+func mix64(hash, seed uint64) uint64 {
+	hash ^= seed
+	hash ^= hash >> 33
+	hash *= 0xff51afd7ed558ccd
+	hash ^= hash >> 33
+	hash *= 0xc4ceb9fe1a85ec53
+	hash ^= hash >> 33
+	return hash
+}
+
+// hashKey computes a seeded 64-bit hash for a key.
+// This function is designed to avoid allocations in the hot path, and is
+// shared by every sharded structure in this package.
+func hashKey[K comparable](key K, seed uint64) uint64 {
 	switch k := any(key).(type) {
 	case string:
 		// Direct byte access for strings (no allocation)
 		// Use unsafe to access string bytes directly
-		hash = fnv64aHash(unsafe.Slice(unsafe.StringData(k), len(k)))
+		return fnv64aHash(unsafe.Slice(unsafe.StringData(k), len(k)), seed)
 	case int:
 		// Direct hashing for int (no allocation)
-		// Use xxhash-style mixing for better distribution
-		hash = uint64(k)
-		hash ^= hash >> 33
-		hash *= 0xff51afd7ed558ccd
-		hash ^= hash >> 33
-		hash *= 0xc4ceb9fe1a85ec53
-		hash ^= hash >> 33
+		return mix64(uint64(k), seed)
 	case int64:
-		hash = uint64(k)
-		hash ^= hash >> 33
-		hash *= 0xff51afd7ed558ccd
-		hash ^= hash >> 33
-		hash *= 0xc4ceb9fe1a85ec53
-		hash ^= hash >> 33
+		return mix64(uint64(k), seed)
 	case uint64:
-		hash = k
-		hash ^= hash >> 33
-		hash *= 0xff51afd7ed558ccd
-		hash ^= hash >> 33
-		hash *= 0xc4ceb9fe1a85ec53
-		hash ^= hash >> 33
+		return mix64(k, seed)
 	case uint32:
-		hash = uint64(k)
-		hash ^= hash >> 33
-		hash *= 0xff51afd7ed558ccd
-		hash ^= hash >> 33
-		hash *= 0xc4ceb9fe1a85ec53
-		hash ^= hash >> 33
+		return mix64(uint64(k), seed)
 	case int32:
-		hash = uint64(k)
-		hash ^= hash >> 33
-		hash *= 0xff51afd7ed558ccd
-		hash ^= hash >> 33
-		hash *= 0xc4ceb9fe1a85ec53
-		hash ^= hash >> 33
+		return mix64(uint64(k), seed)
 	default:
 		// Fallback: use FNV64 on the key's memory representation
-		// This is safe for comparable types and avoids string conversion
+		// This is safe for comparable types and avoids string conversion,
+		// but only consistent for keys without strings or pointers inside;
+		// maps with such keys should use WithHasher
 		keyPtr := unsafe.Pointer(&key)
 		keySize := unsafe.Sizeof(key)
-		hash = fnv64aHash(unsafe.Slice((*byte)(keyPtr), keySize))
+		return fnv64aHash(unsafe.Slice((*byte)(keyPtr), keySize), seed)
 	}
-	return hash % sm.shardCount
+}
+
+// getShardIndex computes the shard index for a given key using FNV64 hashing,
+// or the map's Hasher when set by WithHasher. The shard count is a power of
+// 2, so masking the hash's low bits picks the shard.
+func (sm *ShardedMap[K, V]) getShardIndex(key K) uint64 {
+	if sm.hasher != nil {
+		return mix64(sm.hasher.Hash(key), sm.seed) & (sm.shardCount - 1)
+	}
+	return hashKey(key, sm.seed) & (sm.shardCount - 1)
 }
 
 // Get retrieves a value from the map. Returns the value and a boolean indicating existence.
@@ -109,9 +165,35 @@ func (sm *ShardedMap[K, V]) Get(key K) (V, bool) {
 	defer sm.shardMutex[shardIndex].RUnlock()
 
 	value, exists := sm.shards[shardIndex][key]
+	sm.ops[shardIndex].gets.Add(1)
+	sm.recordLookup(exists)
+	sm.accessed(shardIndex, key, exists)
+	if exists {
+		value = sm.cloned(value)
+	}
 	return value, exists
 }
 
+// TryGet is a Get that never waits on a contended shard. acquired is false,
+// and the lookup is skipped, if the shard's read lock couldn't be taken
+// immediately (e.g. because a writer holds it).
+func (sm *ShardedMap[K, V]) TryGet(key K) (value V, exists, acquired bool) {
+	shardIndex := sm.getShardIndex(key)
+	if !sm.shardMutex[shardIndex].TryRLock() {
+		return value, false, false
+	}
+	defer sm.shardMutex[shardIndex].RUnlock()
+
+	value, exists = sm.shards[shardIndex][key]
+	sm.ops[shardIndex].gets.Add(1)
+	sm.recordLookup(exists)
+	sm.accessed(shardIndex, key, exists)
+	if exists {
+		value = sm.cloned(value)
+	}
+	return value, exists, true
+}
+
 // Set inserts or updates a value in the map.
 // Uses Lock for write operations.
 func (sm *ShardedMap[K, V]) Set(key K, value V) {
@@ -120,6 +202,83 @@ func (sm *ShardedMap[K, V]) Set(key K, value V) {
 	defer sm.shardMutex[shardIndex].Unlock()
 
 	sm.shards[shardIndex][key] = value
+	sm.ops[shardIndex].sets.Add(1)
+	sm.touch(shardIndex, key)
+}
+
+// GetOrSet returns the existing value for key if present. Otherwise it stores
+// value and returns it. loaded reports whether the value was already present.
+//
+// grew is a growth heuristic for capacity planning: Go doesn't expose bucket
+// growth, so it reports whether this insert brought the shard's entry count
+// to a power of two, which is roughly when the shard's map doubles.
+func (sm *ShardedMap[K, V]) GetOrSet(key K, value V) (actual V, loaded, grew bool) {
+	shardIndex := sm.getShardIndex(key)
+	sm.shardMutex[shardIndex].Lock()
+	defer sm.shardMutex[shardIndex].Unlock()
+
+	shard := sm.shards[shardIndex]
+	if existing, ok := shard[key]; ok {
+		return sm.cloned(existing), true, false
+	}
+
+	shard[key] = value
+	sm.touch(shardIndex, key)
+	n := len(shard)
+	return value, false, n&(n-1) == 0
+}
+
+// LoadOrStore returns the existing value for key if present. Otherwise it
+// stores and returns value. loaded reports whether the value was already
+// present. The check and the store happen under one shard write lock, so
+// concurrent callers for a key all get the same value, as with
+// sync.Map.LoadOrStore.
+func (sm *ShardedMap[K, V]) LoadOrStore(key K, value V) (actual V, loaded bool) {
+	actual, loaded, _ = sm.GetOrSet(key, value)
+	return actual, loaded
+}
+
+// UpdateIf atomically replaces key's value with fn(current) when
+// pred(current) is true, and reports whether it did. Both functions are
+// called under the shard's write lock with the current value and whether the
+// key exists, so a false pred can reject the update, e.g. to allow only
+// legal state transitions. Neither function may call back into the map.
+func (sm *ShardedMap[K, V]) UpdateIf(key K, pred func(V, bool) bool, fn func(V, bool) V) bool {
+	shardIndex := sm.getShardIndex(key)
+	sm.shardMutex[shardIndex].Lock()
+	defer sm.shardMutex[shardIndex].Unlock()
+
+	current, exists := sm.shards[shardIndex][key]
+	if !pred(current, exists) {
+		return false
+	}
+	sm.shards[shardIndex][key] = fn(current, exists)
+	sm.touch(shardIndex, key)
+	return true
+}
+
+// Compute atomically replaces or removes key's value: fn is called under the
+// shard's write lock with the current value and whether the key exists, and
+// its result is stored, or the key deleted if fn returns delete. This makes
+// read-modify-writes such as incrementing a count safe without a racy
+// Get/Set pair. fn must not call back into the map. The lock is released
+// even if fn panics, in which case the map is left unchanged.
+func (sm *ShardedMap[K, V]) Compute(key K, fn func(old V, exists bool) (new V, delete bool)) {
+	shardIndex := sm.getShardIndex(key)
+	sm.shardMutex[shardIndex].Lock()
+	defer sm.shardMutex[shardIndex].Unlock()
+
+	old, exists := sm.shards[shardIndex][key]
+	value, remove := fn(old, exists)
+	if remove {
+		if exists {
+			delete(sm.shards[shardIndex], key)
+			sm.forget(shardIndex, key)
+		}
+		return
+	}
+	sm.shards[shardIndex][key] = value
+	sm.touch(shardIndex, key)
 }
 
 // Delete removes a key from the map.
@@ -130,6 +289,35 @@ func (sm *ShardedMap[K, V]) Delete(key K) {
 	defer sm.shardMutex[shardIndex].Unlock()
 
 	delete(sm.shards[shardIndex], key)
+	sm.ops[shardIndex].deletes.Add(1)
+	sm.forget(shardIndex, key)
+}
+
+// Rename atomically moves the value stored under oldKey to newKey,
+// overwriting any value newKey already had. It returns false, changing
+// nothing, if oldKey is absent. When the keys live in different shards both
+// are locked, in ascending index order so concurrent renames can't deadlock.
+func (sm *ShardedMap[K, V]) Rename(oldKey, newKey K) bool {
+	oldIndex := sm.getShardIndex(oldKey)
+	newIndex := sm.getShardIndex(newKey)
+
+	first, second := min(oldIndex, newIndex), max(oldIndex, newIndex)
+	sm.shardMutex[first].Lock()
+	defer sm.shardMutex[first].Unlock()
+	if second != first {
+		sm.shardMutex[second].Lock()
+		defer sm.shardMutex[second].Unlock()
+	}
+
+	value, exists := sm.shards[oldIndex][oldKey]
+	if !exists {
+		return false
+	}
+	delete(sm.shards[oldIndex], oldKey)
+	sm.forget(oldIndex, oldKey)
+	sm.shards[newIndex][newKey] = value
+	sm.touch(newIndex, newKey)
+	return true
 }
 
 // Keys returns all keys from all shards.
@@ -161,3 +349,217 @@ func (sm *ShardedMap[K, V]) Keys() []K {
 
 	return keys
 }
+
+// Range calls fn for every entry, stopping early if fn returns false. Unlike
+// Keys it holds one shard's read lock at a time, not all of them at once, so
+// writes to other shards proceed during the walk; the entries seen are
+// therefore a per-shard snapshot, not one of the whole map. No slice of keys
+// is built. fn runs under the shard's read lock, so it must not write to the
+// map; use RangeChecked to iterate with fn free to write.
+func (sm *ShardedMap[K, V]) Range(fn func(key K, value V) bool) {
+	for i := range sm.shards {
+		if !sm.rangeShard(i, fn) {
+			return
+		}
+	}
+}
+
+// rangeShard calls fn for each of shard i's entries under its read lock,
+// reporting false if fn stopped the walk.
+func (sm *ShardedMap[K, V]) rangeShard(i int, fn func(K, V) bool) bool {
+	sm.shardMutex[i].RLock()
+	defer sm.shardMutex[i].RUnlock()
+
+	for key, value := range sm.shards[i] {
+		if !fn(key, sm.cloned(value)) {
+			return false
+		}
+	}
+	return true
+}
+
+// KeysChan streams the map's keys one shard at a time, so memory use is
+// bounded by the largest shard rather than the whole map. Each shard's keys
+// are copied under its read lock and sent after it is released, so a slow
+// consumer never blocks writers; keys written to a shard after it was copied
+// are not seen. The channel is closed once every shard has been sent or ctx
+// is done, whichever comes first, so cancel ctx when stopping early.
+func (sm *ShardedMap[K, V]) KeysChan(ctx context.Context) <-chan K {
+	ch := make(chan K)
+	go func() {
+		defer close(ch)
+		var keys []K
+		for i := range sm.shards {
+			sm.shardMutex[i].RLock()
+			keys = keys[:0]
+			for key := range sm.shards[i] {
+				keys = append(keys, key)
+			}
+			sm.shardMutex[i].RUnlock()
+
+			for _, key := range keys {
+				// select picks randomly when both are ready, so check ctx
+				// first to stop promptly while the consumer keeps reading
+				if ctx.Err() != nil {
+					return
+				}
+				select {
+				case ch <- key:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return ch
+}
+
+// Len returns the number of entries across all shards, without building a
+// slice of keys as len(Keys()) would. Shards are counted one at a time, so
+// under concurrent writes the total is a point-in-time estimate.
+func (sm *ShardedMap[K, V]) Len() int {
+	total := 0
+	for i := range sm.shards {
+		sm.shardMutex[i].RLock()
+		total += len(sm.shards[i])
+		sm.shardMutex[i].RUnlock()
+	}
+	return total
+}
+
+// ShardCount returns the number of shards in the map: the count passed to
+// NewShardedMap, rounded up to a power of 2.
+func (sm *ShardedMap[K, V]) ShardCount() int {
+	return int(sm.shardCount)
+}
+
+// NumShards returns the number of shards in the map, the same as ShardCount.
+func (sm *ShardedMap[K, V]) NumShards() int {
+	return sm.ShardCount()
+}
+
+// ShardSnapshot returns a copy of the entries in a single shard, taken under
+// that shard's read lock. Together with NumShards it lets callers fan out
+// processing one shard at a time without locking the whole map.
+// shardIndex must be in [0, NumShards()).
+func (sm *ShardedMap[K, V]) ShardSnapshot(shardIndex int) map[K]V {
+	sm.shardMutex[shardIndex].RLock()
+	defer sm.shardMutex[shardIndex].RUnlock()
+
+	snapshot := make(map[K]V, len(sm.shards[shardIndex]))
+	for key, value := range sm.shards[shardIndex] {
+		snapshot[key] = sm.cloned(value)
+	}
+	return snapshot
+}
+
+// UpdateAll replaces every value with fn's result, e.g. to decay all
+// rate-limit counters at the end of a window. Each shard is updated under
+// its write lock, one shard at a time: readers never see a half-updated
+// shard, but may see one shard updated before another.
+// fn must not call back into the map.
+func (sm *ShardedMap[K, V]) UpdateAll(fn func(K, V) V) {
+	for i := range sm.shards {
+		sm.shardMutex[i].Lock()
+		for key, value := range sm.shards[i] {
+			sm.shards[i][key] = fn(key, value)
+			sm.touch(uint64(i), key)
+		}
+		sm.shardMutex[i].Unlock()
+	}
+}
+
+// Drain removes every entry from the map and returns them as a plain map.
+// All shards are locked for the duration of the copy-and-clear, so no entry
+// can be written between being read and being removed.
+func (sm *ShardedMap[K, V]) Drain() map[K]V {
+	return sm.drain(nil)
+}
+
+// drain is Drain, calling removed, if non-nil, with the number of entries
+// removed before any shard is unlocked.
+func (sm *ShardedMap[K, V]) drain(removed func(n int)) map[K]V {
+	// Lock all shards for writing so the snapshot and reset are atomic
+	for i := range sm.shardMutex {
+		sm.shardMutex[i].Lock()
+	}
+	defer func() {
+		for i := range sm.shardMutex {
+			sm.shardMutex[i].Unlock()
+		}
+	}()
+
+	totalEntries := 0
+	for i := range sm.shards {
+		totalEntries += len(sm.shards[i])
+	}
+
+	drained := make(map[K]V, totalEntries)
+	if totalEntries > 0 {
+		sm.modified()
+	}
+	for i := range sm.shards {
+		for key, value := range sm.shards[i] {
+			drained[key] = value
+		}
+		// Replace instead of clearing so the old backing storage can be collected
+		sm.shards[i] = make(map[K]V)
+		sm.resetTracking(i, 0)
+	}
+
+	if removed != nil {
+		removed(len(drained))
+	}
+	return drained
+}
+
+// Clear removes every entry from the map. Unlike Drain it locks one shard at
+// a time, so operations on other shards carry on meanwhile; a concurrent
+// writer may therefore leave entries in shards already cleared. Each shard's
+// map is replaced rather than emptied, so its grown backing storage is
+// released to the garbage collector.
+func (sm *ShardedMap[K, V]) Clear() {
+	for i := range sm.shards {
+		sm.shardMutex[i].Lock()
+		if len(sm.shards[i]) > 0 {
+			sm.shards[i] = make(map[K]V)
+			sm.resetTracking(i, 0)
+			sm.modified()
+		}
+		sm.shardMutex[i].Unlock()
+	}
+}
+
+// ReplaceAll atomically replaces the map's contents with entries, e.g. to
+// swap in a freshly reloaded data set. The new entries are distributed into
+// shards before any lock is taken, so the write locks on all shards are held
+// only for the swap itself; any read that locks all shards, such as Keys,
+// sees either the old contents in full or the new ones. entries is copied
+// and may be reused by the caller.
+func (sm *ShardedMap[K, V]) ReplaceAll(entries map[K]V) {
+	shards := make([]map[K]V, sm.shardCount)
+	for i := range shards {
+		shards[i] = make(map[K]V)
+	}
+	for key, value := range entries {
+		shards[sm.getShardIndex(key)][key] = value
+	}
+
+	for i := range sm.shardMutex {
+		sm.shardMutex[i].Lock()
+	}
+	defer func() {
+		for i := range sm.shardMutex {
+			sm.shardMutex[i].Unlock()
+		}
+	}()
+
+	sm.modified()
+	for i := range sm.shards {
+		sm.shards[i] = shards[i]
+		sm.resetTracking(i, len(shards[i]))
+		for key := range shards[i] {
+			sm.touch(uint64(i), key)
+		}
+	}
+}
@@ -1,9 +1,14 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"runtime"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 // TestBasicOperations tests basic Get, Set, Delete operations
@@ -269,6 +274,28 @@ func BenchmarkGetSetMixed(b *testing.B) {
 	})
 }
 
+// TestLen tests that Len counts entries across shards without allocating
+func TestLen(t *testing.T) {
+	sm := NewShardedMap[int, int](16)
+	if got := sm.Len(); got != 0 {
+		t.Errorf("Expected empty map Len 0, got %d", got)
+	}
+
+	for i := 0; i < 1000; i++ {
+		sm.Set(i, i)
+	}
+	for i := 0; i < 1000; i += 4 {
+		sm.Delete(i)
+	}
+	if got := sm.Len(); got != 750 {
+		t.Errorf("Expected Len 750, got %d", got)
+	}
+
+	if allocs := testing.AllocsPerRun(100, func() { sm.Len() }); allocs != 0 {
+		t.Errorf("Expected Len not to allocate, got %v allocs", allocs)
+	}
+}
+
 // TestMemoryUsage tests that we don't use excessive memory
 func TestMemoryUsage(t *testing.T) {
 	sm := NewShardedMap[int, interface{}](64)
@@ -344,3 +371,910 @@ func TestUpdateValue(t *testing.T) {
 	}
 }
 
+
+// TestDrain tests that Drain returns all entries and empties the map
+func TestDrain(t *testing.T) {
+	sm := NewShardedMap[int, int](8)
+	for i := 0; i < 100; i++ {
+		sm.Set(i, i*10)
+	}
+
+	drained := sm.Drain()
+	if len(drained) != 100 {
+		t.Fatalf("Expected 100 drained entries, got %d", len(drained))
+	}
+	for i := 0; i < 100; i++ {
+		if drained[i] != i*10 {
+			t.Errorf("Key %d: expected %d, got %d", i, i*10, drained[i])
+		}
+	}
+
+	if keys := sm.Keys(); len(keys) != 0 {
+		t.Errorf("Expected empty map after Drain, got %d keys", len(keys))
+	}
+
+	// The map must remain usable after draining
+	sm.Set(1, 1)
+	if val, exists := sm.Get(1); !exists || val != 1 {
+		t.Errorf("Expected key 1=1 after Drain, got %d, exists=%v", val, exists)
+	}
+}
+
+// TestClear tests that Clear empties every shard and leaves the map usable
+func TestClear(t *testing.T) {
+	sm := NewShardedMap[int, int](8, WithVersioning[int, int]())
+	for i := 0; i < 1000; i++ {
+		sm.Set(i, i)
+	}
+
+	sm.Clear()
+
+	if got := sm.Len(); got != 0 {
+		t.Errorf("Expected Len 0 after Clear, got %d", got)
+	}
+	if _, exists := sm.Get(1); exists {
+		t.Error("Expected key 1 to be gone after Clear")
+	}
+	if changed, _ := sm.ChangedSince(0); len(changed) != 0 {
+		t.Errorf("Expected no versions to survive Clear, got %d", len(changed))
+	}
+
+	sm.Set(1, 10)
+	sm.Set(2000, 20)
+	if got := sm.Len(); got != 2 {
+		t.Errorf("Expected Len 2 after new writes, got %d", got)
+	}
+	if val, _ := sm.Get(1); val != 10 {
+		t.Errorf("Expected 10, got %d", val)
+	}
+}
+
+// TestDrainConcurrentWrites tests that Drain never loses or duplicates entries
+// inserted concurrently
+func TestDrainConcurrentWrites(t *testing.T) {
+	sm := NewShardedMap[int, int](16)
+	const numWriters = 8
+	const keysPerWriter = 2000
+
+	var writers sync.WaitGroup
+	for w := 0; w < numWriters; w++ {
+		writers.Add(1)
+		go func(id int) {
+			defer writers.Done()
+			for j := 0; j < keysPerWriter; j++ {
+				key := id*keysPerWriter + j
+				sm.Set(key, key)
+			}
+		}(w)
+	}
+
+	// Drain repeatedly while writers are running
+	seen := make(map[int]int)
+	done := make(chan struct{})
+	var drainer sync.WaitGroup
+	drainer.Add(1)
+	go func() {
+		defer drainer.Done()
+		for {
+			for key := range sm.Drain() {
+				seen[key]++
+			}
+			select {
+			case <-done:
+				return
+			default:
+				runtime.Gosched()
+			}
+		}
+	}()
+
+	writers.Wait()
+	close(done)
+	drainer.Wait()
+
+	// Whatever the drainer missed is still in the map
+	for _, key := range sm.Keys() {
+		seen[key]++
+	}
+
+	if len(seen) != numWriters*keysPerWriter {
+		t.Errorf("Expected %d distinct keys, got %d", numWriters*keysPerWriter, len(seen))
+	}
+	for key, count := range seen {
+		if count != 1 {
+			t.Errorf("Key %d observed %d times, expected exactly once", key, count)
+		}
+	}
+}
+
+// TestSeedChangesPlacement tests that the hash seed affects shard placement
+func TestSeedChangesPlacement(t *testing.T) {
+	const numKeys = 1000
+	smA := NewShardedMap[string, int](16, WithSeed[string, int](1))
+	smB := NewShardedMap[string, int](16, WithSeed[string, int](2))
+	smA2 := NewShardedMap[string, int](16, WithSeed[string, int](1))
+
+	moved := 0
+	for i := 0; i < numKeys; i++ {
+		key := fmt.Sprintf("user-%d", i)
+		if smA.getShardIndex(key) != smB.getShardIndex(key) {
+			moved++
+		}
+		if smA.getShardIndex(key) != smA2.getShardIndex(key) {
+			t.Fatalf("Key %q placed differently by maps with the same seed", key)
+		}
+	}
+
+	// With 16 shards, ~15/16 of keys should move; require a clear majority
+	if moved < numKeys/2 {
+		t.Errorf("Expected most keys to move between seeds, only %d/%d moved", moved, numKeys)
+	}
+}
+
+// TestSeedIntKeys tests that integer keys are also seeded
+func TestSeedIntKeys(t *testing.T) {
+	smA := NewShardedMap[int, int](16, WithSeed[int, int](1))
+	smB := NewShardedMap[int, int](16, WithSeed[int, int](2))
+
+	moved := 0
+	for i := 0; i < 1000; i++ {
+		if smA.getShardIndex(i) != smB.getShardIndex(i) {
+			moved++
+		}
+	}
+	if moved < 500 {
+		t.Errorf("Expected most int keys to move between seeds, only %d/1000 moved", moved)
+	}
+}
+
+// TestShardCountRoundedUp tests that shard counts are rounded up to a power
+// of 2 and that every shard is used
+func TestShardCountRoundedUp(t *testing.T) {
+	for _, tc := range []struct{ requested, want int }{
+		{-1, 1}, {0, 1}, {1, 1}, {2, 2}, {3, 4}, {10, 16}, {16, 16}, {17, 32},
+	} {
+		sm := NewShardedMap[int, int](tc.requested)
+		if got := sm.ShardCount(); got != tc.want {
+			t.Errorf("NewShardedMap(%d): expected %d shards, got %d", tc.requested, tc.want, got)
+		}
+		if got := sm.NumShards(); got != tc.want {
+			t.Errorf("Expected NumShards to match ShardCount %d, got %d", tc.want, got)
+		}
+	}
+
+	sm := NewShardedMap[int, int](10)
+	for i := 0; i < 10000; i++ {
+		sm.Set(i, i)
+	}
+	for i := 0; i < sm.ShardCount(); i++ {
+		if n := len(sm.ShardSnapshot(i)); n == 0 {
+			t.Errorf("Expected shard %d to hold keys", i)
+		}
+	}
+}
+
+// TestGetOrSet tests that GetOrSet stores only missing keys
+func TestGetOrSet(t *testing.T) {
+	sm := NewShardedMap[string, int](4)
+
+	actual, loaded, _ := sm.GetOrSet("a", 1)
+	if loaded || actual != 1 {
+		t.Errorf("Expected first GetOrSet to store 1, got %d, loaded=%v", actual, loaded)
+	}
+
+	actual, loaded, grew := sm.GetOrSet("a", 2)
+	if !loaded || actual != 1 {
+		t.Errorf("Expected existing value 1, got %d, loaded=%v", actual, loaded)
+	}
+	if grew {
+		t.Error("Expected grew=false when the key already exists")
+	}
+
+	if val, _ := sm.Get("a"); val != 1 {
+		t.Errorf("Expected GetOrSet not to overwrite, got %d", val)
+	}
+}
+
+// TestLoadOrStore tests that concurrent callers for a key all get the one
+// value that was stored
+func TestLoadOrStore(t *testing.T) {
+	sm := NewShardedMap[string, *int](4)
+	const numGoroutines = 50
+
+	var wg sync.WaitGroup
+	results := make([]*int, numGoroutines)
+	var stored atomic.Int32
+	for i := 0; i < numGoroutines; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			value := new(int)
+			*value = i
+			actual, loaded := sm.LoadOrStore("key", value)
+			if !loaded {
+				stored.Add(1)
+				if actual != value {
+					t.Error("Expected a store to return the stored value")
+				}
+			}
+			results[i] = actual
+		}(i)
+	}
+	wg.Wait()
+
+	if got := stored.Load(); got != 1 {
+		t.Errorf("Expected exactly one store, got %d", got)
+	}
+	current, _ := sm.Get("key")
+	for i, actual := range results {
+		if actual != current {
+			t.Errorf("Expected caller %d to get the stored value %d, got %d", i, *current, *actual)
+		}
+	}
+}
+
+// TestGetOrSetGrew tests that grew fires at power-of-two shard sizes
+func TestGetOrSetGrew(t *testing.T) {
+	// One shard so every key counts toward the same boundaries
+	sm := NewShardedMap[int, int](1)
+
+	var grewAt []int
+	for i := 1; i <= 100; i++ {
+		if _, _, grew := sm.GetOrSet(i, i); grew {
+			grewAt = append(grewAt, i)
+		}
+	}
+
+	want := []int{1, 2, 4, 8, 16, 32, 64}
+	if fmt.Sprint(grewAt) != fmt.Sprint(want) {
+		t.Errorf("Expected grew at %v, got %v", want, grewAt)
+	}
+
+	// Deleting lowers the count, so boundaries are measured from the
+	// current size rather than from the number of inserts ever made
+	for i := 1; i <= 37; i++ {
+		sm.Delete(i)
+	}
+	if _, _, grew := sm.GetOrSet(1000, 0); !grew {
+		t.Error("Expected grew when refilling back to 64 entries")
+	}
+}
+
+// TestShardSnapshot tests that shard snapshots partition the full key set
+func TestShardSnapshot(t *testing.T) {
+	sm := NewShardedMap[string, int](8)
+	const numKeys = 1000
+	for i := 0; i < numKeys; i++ {
+		sm.Set(fmt.Sprintf("key-%d", i), i)
+	}
+
+	if sm.NumShards() != 8 {
+		t.Fatalf("Expected 8 shards, got %d", sm.NumShards())
+	}
+
+	// Process shards in parallel, as a caller fanning out would
+	snapshots := make([]map[string]int, sm.NumShards())
+	var wg sync.WaitGroup
+	for i := 0; i < sm.NumShards(); i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			snapshots[i] = sm.ShardSnapshot(i)
+		}(i)
+	}
+	wg.Wait()
+
+	union := make(map[string]int)
+	for i, snapshot := range snapshots {
+		for key, value := range snapshot {
+			if _, dup := union[key]; dup {
+				t.Errorf("Key %s appears in more than one shard", key)
+			}
+			if got := sm.getShardIndex(key); got != uint64(i) {
+				t.Errorf("Key %s in snapshot %d belongs to shard %d", key, i, got)
+			}
+			union[key] = value
+		}
+	}
+
+	if len(union) != numKeys {
+		t.Fatalf("Expected union of %d keys, got %d", numKeys, len(union))
+	}
+	for _, key := range sm.Keys() {
+		if v, _ := sm.Get(key); union[key] != v {
+			t.Errorf("Expected %s=%d in union, got %d", key, v, union[key])
+		}
+	}
+
+	// Snapshots are copies
+	snapshots[0]["injected"] = -1
+	if _, exists := sm.Get("injected"); exists {
+		t.Error("Expected snapshot writes not to reach the map")
+	}
+}
+
+// TestTryGet tests that TryGet reports a locked shard instead of blocking
+func TestTryGet(t *testing.T) {
+	sm := NewShardedMap[string, int](16)
+	sm.Set("key1", 42)
+
+	val, exists, acquired := sm.TryGet("key1")
+	if !acquired || !exists || val != 42 {
+		t.Errorf("Expected key1=42 on an uncontended shard, got %d, exists=%v, acquired=%v", val, exists, acquired)
+	}
+
+	// Hold the shard's write lock as a writer would
+	shardIndex := sm.getShardIndex("key1")
+	sm.shardMutex[shardIndex].Lock()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, exists, acquired = sm.TryGet("key1")
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		sm.shardMutex[shardIndex].Unlock()
+		t.Fatal("TryGet blocked on a locked shard")
+	}
+	sm.shardMutex[shardIndex].Unlock()
+
+	if acquired {
+		t.Error("Expected acquired=false while the shard is write-locked")
+	}
+	if exists {
+		t.Error("Expected exists=false when the lookup was skipped")
+	}
+}
+
+// TestRename tests moving a value to a new key
+func TestRename(t *testing.T) {
+	sm := NewShardedMap[string, int](16)
+	sm.Set("old", 1)
+
+	if !sm.Rename("old", "new") {
+		t.Fatal("Expected Rename of an existing key to succeed")
+	}
+	if _, exists := sm.Get("old"); exists {
+		t.Error("Expected old key to be removed")
+	}
+	if val, exists := sm.Get("new"); !exists || val != 1 {
+		t.Errorf("Expected new=1, got %d, exists=%v", val, exists)
+	}
+
+	if sm.Rename("missing", "other") {
+		t.Error("Expected Rename of a missing key to return false")
+	}
+	if _, exists := sm.Get("other"); exists {
+		t.Error("Expected failed Rename not to create the new key")
+	}
+
+	// Renaming a key to itself leaves it in place
+	if !sm.Rename("new", "new") {
+		t.Error("Expected Rename to the same key to succeed")
+	}
+	if val, _ := sm.Get("new"); val != 1 {
+		t.Errorf("Expected new=1 after self-rename, got %d", val)
+	}
+}
+
+// TestRenameAtomicAcrossShards tests that readers never see a cross-shard
+// rename half done
+func TestRenameAtomicAcrossShards(t *testing.T) {
+	sm := NewShardedMap[string, int](16)
+
+	// Find two keys in different shards
+	a, b := "session-0", ""
+	for i := 1; b == ""; i++ {
+		if k := fmt.Sprintf("session-%d", i); sm.getShardIndex(k) != sm.getShardIndex(a) {
+			b = k
+		}
+	}
+	sm.Set(a, 42)
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	defer close(stop)
+
+	// Rotate the session back and forth
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		from, to := a, b
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			if !sm.Rename(from, to) {
+				t.Errorf("Rename %s -> %s failed", from, to)
+				return
+			}
+			from, to = to, from
+		}
+	}()
+
+	// Keys locks every shard, so each call is a consistent snapshot
+	for i := 0; i < 2000; i++ {
+		present := 0
+		for _, k := range sm.Keys() {
+			if k == a || k == b {
+				present++
+			}
+		}
+		if present != 1 {
+			t.Fatalf("Expected exactly one of %s/%s, saw %d", a, b, present)
+		}
+	}
+}
+
+// TestUpdateAll tests halving every value while readers run concurrently
+func TestUpdateAll(t *testing.T) {
+	sm := NewShardedMap[int, int](16)
+	const numKeys = 1000
+	for i := 0; i < numKeys; i++ {
+		sm.Set(i, i*4)
+	}
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				for i := 0; i < numKeys; i += 37 {
+					// Values only ever shrink by halving, so any value
+					// seen must be one of the stages i*4, i*2, i
+					if val, _ := sm.Get(i); val != i*4 && val != i*2 && val != i {
+						t.Errorf("Key %d: unexpected intermediate value %d", i, val)
+						return
+					}
+				}
+			}
+		}()
+	}
+
+	halve := func(_ int, v int) int { return v / 2 }
+	sm.UpdateAll(halve)
+	sm.UpdateAll(halve)
+	close(stop)
+	wg.Wait()
+
+	for i := 0; i < numKeys; i++ {
+		if val, _ := sm.Get(i); val != i {
+			t.Errorf("Expected key %d halved twice to %d, got %d", i, i, val)
+		}
+	}
+	if got := sm.Len(); got != numKeys {
+		t.Errorf("Expected UpdateAll to keep all %d keys, got %d", numKeys, got)
+	}
+}
+
+// TestChangedSince tests that only entries written after a recorded version are returned
+func TestChangedSince(t *testing.T) {
+	sm := NewShardedMap[string, int](8, WithVersioning[string, int]())
+
+	sm.Set("a", 1)
+	sm.Set("b", 2)
+	sm.Set("c", 3)
+	_, mark := sm.ChangedSince(0)
+
+	sm.Set("b", 20)
+	sm.Set("d", 4)
+	sm.Delete("c")
+
+	changed, next := sm.ChangedSince(mark)
+	if next <= mark {
+		t.Errorf("Expected high-water mark to advance past %d, got %d", mark, next)
+	}
+	got := make(map[string]int, len(changed))
+	for _, e := range changed {
+		if e.Version <= mark {
+			t.Errorf("Entry %q has version %d, not after %d", e.Key, e.Version, mark)
+		}
+		got[e.Key] = e.Value
+	}
+	if len(got) != 2 || got["b"] != 20 || got["d"] != 4 {
+		t.Errorf("Expected only b=20 and d=4, got %v", got)
+	}
+
+	if changed, _ := sm.ChangedSince(next); len(changed) != 0 {
+		t.Errorf("Expected no changes after %d, got %v", next, changed)
+	}
+}
+
+// TestCompute tests that concurrent increments through Compute are not
+// lost, that it can delete, and that a panicking fn leaves the shard unlocked
+func TestCompute(t *testing.T) {
+	sm := NewShardedMap[string, int](4)
+	const numGoroutines = 50
+	const incrementsPerGoroutine = 100
+
+	increment := func(old int, exists bool) (int, bool) {
+		return old + 1, false
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < numGoroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < incrementsPerGoroutine; j++ {
+				sm.Compute("hits", increment)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got, _ := sm.Get("hits"); got != numGoroutines*incrementsPerGoroutine {
+		t.Errorf("Expected %d, got %d", numGoroutines*incrementsPerGoroutine, got)
+	}
+
+	sm.Compute("hits", func(old int, exists bool) (int, bool) {
+		if !exists || old != numGoroutines*incrementsPerGoroutine {
+			t.Errorf("Expected fn to see the stored count, got %d, exists=%v", old, exists)
+		}
+		return 0, true
+	})
+	if _, exists := sm.Get("hits"); exists {
+		t.Error("Expected Compute to delete the key")
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("Expected the panic to reach the caller")
+			}
+		}()
+		sm.Compute("hits", func(int, bool) (int, bool) { panic("boom") })
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		sm.Set("hits", 1)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the shard to be unlocked after fn panicked")
+	}
+}
+
+// TestUpdateIf tests that a value only transitions from an allowed state
+func TestUpdateIf(t *testing.T) {
+	sm := NewShardedMap[string, string](8)
+
+	// pending -> running -> done is the only legal path
+	next := map[string]string{"pending": "running", "running": "done"}
+	advance := func(key string) bool {
+		return sm.UpdateIf(key,
+			func(state string, exists bool) bool { _, ok := next[state]; return exists && ok },
+			func(state string, _ bool) string { return next[state] },
+		)
+	}
+
+	if advance("job") {
+		t.Error("Expected no transition for a missing key")
+	}
+	if _, exists := sm.Get("job"); exists {
+		t.Error("Expected a rejected update not to create the key")
+	}
+
+	sm.Set("job", "pending")
+	for _, want := range []string{"running", "done"} {
+		if !advance("job") {
+			t.Fatalf("Expected transition to %s", want)
+		}
+		if state, _ := sm.Get("job"); state != want {
+			t.Fatalf("Expected state %s, got %s", want, state)
+		}
+	}
+
+	if advance("job") {
+		t.Error("Expected no transition out of done")
+	}
+	if state, _ := sm.Get("job"); state != "done" {
+		t.Errorf("Expected rejected transition to leave done, got %s", state)
+	}
+
+	// Concurrent advances from pending: exactly one may win each step
+	sm.Set("race", "pending")
+	var wins sync.WaitGroup
+	var mu sync.Mutex
+	won := 0
+	for i := 0; i < 50; i++ {
+		wins.Add(1)
+		go func() {
+			defer wins.Done()
+			if sm.UpdateIf("race",
+				func(state string, _ bool) bool { return state == "pending" },
+				func(string, bool) string { return "running" },
+			) {
+				mu.Lock()
+				won++
+				mu.Unlock()
+			}
+		}()
+	}
+	wins.Wait()
+	if won != 1 {
+		t.Errorf("Expected exactly one winner of the pending transition, got %d", won)
+	}
+}
+
+// TestTopN tests that TopN returns the highest values, largest first
+func TestTopN(t *testing.T) {
+	sm := NewShardedMap[string, int64](16)
+	const numKeys = 1000
+	for i := 0; i < numKeys; i++ {
+		// Spread the values so insertion order doesn't match rank
+		sm.Set(fmt.Sprintf("user%d", i), int64((i*7919)%numKeys))
+	}
+
+	top := sm.TopN(5, func(a, b int64) bool { return a < b })
+	if len(top) != 5 {
+		t.Fatalf("Expected 5 entries, got %d", len(top))
+	}
+	for i, e := range top {
+		want := int64(numKeys - 1 - i)
+		if e.Value != want {
+			t.Errorf("Rank %d: expected value %d, got %d (%s)", i, want, e.Value, e.Key)
+		}
+		if got, _ := sm.Get(e.Key); got != e.Value {
+			t.Errorf("Rank %d: key %s has value %d, entry says %d", i, e.Key, got, e.Value)
+		}
+	}
+
+	if all := sm.TopN(numKeys*2, func(a, b int64) bool { return a < b }); len(all) != numKeys {
+		t.Errorf("Expected n larger than the map to return every entry, got %d", len(all))
+	}
+	if none := sm.TopN(0, func(a, b int64) bool { return a < b }); len(none) != 0 {
+		t.Errorf("Expected no entries for n=0, got %d", len(none))
+	}
+}
+
+// TestRangeCheckedDetectsModification tests that a write during iteration is reported
+func TestRangeCheckedDetectsModification(t *testing.T) {
+	sm := NewShardedMap[int, int](8, WithModificationTracking[int, int]())
+	for i := 0; i < 100; i++ {
+		sm.Set(i, i)
+	}
+
+	seen := 0
+	if err := sm.RangeChecked(func(k, v int) bool { seen++; return true }); err != nil {
+		t.Fatalf("Expected an unmodified iteration to succeed, got %v", err)
+	}
+	if seen != 100 {
+		t.Errorf("Expected 100 entries, saw %d", seen)
+	}
+
+	err := sm.RangeChecked(func(k, v int) bool {
+		sm.Set(1000+k, v)
+		return true
+	})
+	if err != ErrConcurrentModification {
+		t.Errorf("Expected ErrConcurrentModification, got %v", err)
+	}
+
+	// Deletes count as modifications too
+	err = sm.RangeChecked(func(k, v int) bool {
+		sm.Delete(k)
+		return true
+	})
+	if err != ErrConcurrentModification {
+		t.Errorf("Expected ErrConcurrentModification after a delete, got %v", err)
+	}
+
+	if err := NewShardedMap[int, int](8).RangeChecked(func(int, int) bool { return true }); err == nil {
+		t.Error("Expected an error without modification tracking")
+	}
+}
+
+// TestReplaceAllAtomic tests that readers see either the old or the new
+// contents in full while ReplaceAll swaps between two data sets
+func TestReplaceAllAtomic(t *testing.T) {
+	const size = 200
+	sets := [2]map[string]int{{}, {}}
+	for i := 0; i < size; i++ {
+		sets[0][fmt.Sprintf("old-%d", i)] = i
+		sets[1][fmt.Sprintf("new-%d", i)] = i
+	}
+
+	sm := NewShardedMap[string, int](16)
+	sm.ReplaceAll(sets[0])
+
+	done := make(chan struct{})
+	var readers sync.WaitGroup
+	for r := 0; r < 4; r++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				keys := sm.Keys()
+				if len(keys) != size {
+					t.Errorf("Expected %d keys, got %d", size, len(keys))
+					return
+				}
+				prefix, _, _ := strings.Cut(keys[0], "-")
+				for _, key := range keys {
+					if !strings.HasPrefix(key, prefix+"-") {
+						t.Errorf("Mixed state: saw %q alongside %q keys", key, prefix)
+						return
+					}
+				}
+			}
+		}()
+	}
+
+	for i := 1; i <= 500; i++ {
+		sm.ReplaceAll(sets[i%2])
+	}
+	close(done)
+	readers.Wait()
+
+	// After an even number of swaps the old set is back
+	if val, ok := sm.Get("old-7"); !ok || val != 7 {
+		t.Errorf("Expected old-7=7 after the last swap, got %d, exists=%v", val, ok)
+	}
+	if _, ok := sm.Get("new-7"); ok {
+		t.Error("Expected new keys to be gone after the last swap")
+	}
+}
+
+// TestRange tests that Range visits every entry once, stops when fn returns
+// false, and leaves other shards writable while fn runs
+func TestRange(t *testing.T) {
+	sm := NewShardedMap[int, int](8)
+	for i := 0; i < 1000; i++ {
+		sm.Set(i, i*2)
+	}
+
+	seen := make(map[int]bool)
+	sm.Range(func(key, value int) bool {
+		if seen[key] {
+			t.Errorf("Expected key %d once, seen again", key)
+		}
+		seen[key] = true
+		if value != key*2 {
+			t.Errorf("Key %d: expected %d, got %d", key, key*2, value)
+		}
+		return true
+	})
+	if len(seen) != 1000 {
+		t.Errorf("Expected 1000 entries, got %d", len(seen))
+	}
+
+	visits := 0
+	sm.Range(func(int, int) bool {
+		visits++
+		return visits < 10
+	})
+	if visits != 10 {
+		t.Errorf("Expected Range to stop after 10 entries, got %d", visits)
+	}
+
+	// Only the shard being walked is locked, so a write elsewhere completes
+	// while fn is still running
+	var other int
+	sm.Range(func(key, _ int) bool {
+		for other = 0; sm.getShardIndex(other) == sm.getShardIndex(key); other++ {
+		}
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			sm.Set(other, -1)
+		}()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Error("Expected a write to another shard not to wait for Range")
+		}
+		return false
+	})
+	if val, _ := sm.Get(other); val != -1 {
+		t.Errorf("Expected the concurrent write to land, got %d", val)
+	}
+}
+
+// TestKeysChan tests that every key is streamed exactly once
+func TestKeysChan(t *testing.T) {
+	sm := NewShardedMap[int, int](8)
+	for i := 0; i < 1000; i++ {
+		sm.Set(i, i)
+	}
+
+	seen := make(map[int]int)
+	for key := range sm.KeysChan(context.Background()) {
+		seen[key]++
+	}
+	if len(seen) != 1000 {
+		t.Fatalf("Expected 1000 distinct keys, got %d", len(seen))
+	}
+	for key, n := range seen {
+		if n != 1 {
+			t.Errorf("Key %d streamed %d times", key, n)
+		}
+	}
+}
+
+// TestKeysChanCancel tests that cancelling stops the stream early and the
+// streaming goroutine exits
+func TestKeysChanCancel(t *testing.T) {
+	sm := NewShardedMap[int, int](8)
+	for i := 0; i < 10000; i++ {
+		sm.Set(i, i)
+	}
+	baseline := runtime.NumGoroutine()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := sm.KeysChan(ctx)
+	for i := 0; i < 10; i++ {
+		<-ch
+	}
+	cancel()
+
+	// At most one key may already be on its way when ctx is cancelled
+	remaining := 0
+	for range ch {
+		remaining++
+	}
+	if remaining > 1 {
+		t.Errorf("Expected the stream to stop after cancellation, got %d more keys", remaining)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > baseline {
+		if time.Now().After(deadline) {
+			t.Fatalf("Goroutine leak: %d goroutines, expected %d", runtime.NumGoroutine(), baseline)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// TestStats tests that the operation counters match the operations performed
+func TestStats(t *testing.T) {
+	sm := NewShardedMap[int, int](8)
+
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				key := g*100 + i
+				sm.Set(key, i)
+				sm.Get(key)
+				sm.Get(key + 1000)
+				if i%2 == 0 {
+					sm.Delete(key)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	h := sm.Handle(-1)
+	h.Set(1)
+	h.Get()
+	h.Delete()
+
+	want := Stats{Gets: 801, Sets: 401, Deletes: 201}
+	if got := sm.Stats(); got != want {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"container/heap"
+	"slices"
+)
+
+// entryHeap is a min-heap of entries under a caller-supplied ordering, for
+// use with container/heap. less reports whether a orders before b.
+type entryHeap[K comparable, V any] struct {
+	entries []Entry[K, V]
+	less    func(a, b V) bool
+}
+
+func (h *entryHeap[K, V]) Len() int           { return len(h.entries) }
+func (h *entryHeap[K, V]) Less(i, j int) bool { return h.less(h.entries[i].Value, h.entries[j].Value) }
+func (h *entryHeap[K, V]) Swap(i, j int)      { h.entries[i], h.entries[j] = h.entries[j], h.entries[i] }
+func (h *entryHeap[K, V]) Push(x any)         { h.entries = append(h.entries, x.(Entry[K, V])) }
+func (h *entryHeap[K, V]) Pop() any {
+	old := h.entries
+	n := len(old)
+	entry := old[n-1]
+	h.entries = old[:n-1]
+	return entry
+}
+
+// TopN returns the n entries with the largest values according to less,
+// largest first. It keeps a heap of at most n entries instead of sorting the
+// whole map, and read-locks one shard at a time, so under concurrent writes
+// the result reflects each shard at the moment it was scanned.
+// Ties between equal values are broken arbitrarily.
+func (sm *ShardedMap[K, V]) TopN(n int, less func(a, b V) bool) []Entry[K, V] {
+	if n <= 0 {
+		return nil
+	}
+
+	// The heap's root is the smallest of the current top n, so each new
+	// value only has to beat that one to get in
+	h := &entryHeap[K, V]{less: less}
+	for i := range sm.shards {
+		sm.shardMutex[i].RLock()
+		for key, value := range sm.shards[i] {
+			// Only entries that make it into the heap are cloned
+			if h.Len() < n {
+				heap.Push(h, sm.entry(uint64(i), key, value))
+			} else if less(h.entries[0].Value, value) {
+				h.entries[0] = sm.entry(uint64(i), key, value)
+				heap.Fix(h, 0)
+			}
+		}
+		sm.shardMutex[i].RUnlock()
+	}
+
+	top := h.entries
+	slices.SortFunc(top, func(a, b Entry[K, V]) int {
+		switch {
+		case less(b.Value, a.Value):
+			return -1
+		case less(a.Value, b.Value):
+			return 1
+		}
+		return 0
+	})
+	return top
+}
+
+// entry builds an Entry for a key in shardIndex, with its version when
+// versioning is enabled. The caller must hold the shard's lock.
+func (sm *ShardedMap[K, V]) entry(shardIndex uint64, key K, value V) Entry[K, V] {
+	e := Entry[K, V]{Key: key, Value: sm.cloned(value)}
+	if sm.versions != nil {
+		e.Version = sm.versions[shardIndex][key]
+	}
+	return e
+}
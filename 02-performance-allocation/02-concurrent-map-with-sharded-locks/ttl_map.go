@@ -0,0 +1,130 @@
+package main
+
+import (
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// ttlEntry wraps a value with its expiry time.
+type ttlEntry[V any] struct {
+	value     V
+	expiresAt time.Time
+}
+
+// ttlShard is one lock domain of a TTLShardedMap. Each shard keeps its own
+// pool of entry wrappers so that delete-then-insert churn reuses wrappers
+// instead of allocating new ones, without contending on a shared pool.
+type ttlShard[K comparable, V any] struct {
+	mu      sync.RWMutex
+	entries map[K]*ttlEntry[V]
+	pool    sync.Pool
+}
+
+// TTLShardedMap is a sharded map whose entries expire after a per-entry TTL.
+// Expired entries are removed lazily when they are next accessed.
+type TTLShardedMap[K comparable, V any] struct {
+	shards     []ttlShard[K, V]
+	shardCount uint64
+	seed       uint64
+	pooled     bool
+}
+
+// NewTTLShardedMap creates a new TTLShardedMap with the specified number of shards.
+func NewTTLShardedMap[K comparable, V any](shardCount int) *TTLShardedMap[K, V] {
+	return newTTLShardedMap[K, V](shardCount, true)
+}
+
+// newTTLShardedMap allows disabling wrapper pooling, for benchmark comparison.
+func newTTLShardedMap[K comparable, V any](shardCount int, pooled bool) *TTLShardedMap[K, V] {
+	if shardCount < 1 {
+		shardCount = 1
+	}
+	tm := &TTLShardedMap[K, V]{
+		shards:     make([]ttlShard[K, V], shardCount),
+		shardCount: uint64(shardCount),
+		seed:       rand.Uint64(),
+		pooled:     pooled,
+	}
+	for i := range tm.shards {
+		tm.shards[i].entries = make(map[K]*ttlEntry[V])
+	}
+	return tm
+}
+
+func (tm *TTLShardedMap[K, V]) shardFor(key K) *ttlShard[K, V] {
+	return &tm.shards[hashKey(key, tm.seed)%tm.shardCount]
+}
+
+// newEntry takes a wrapper from the shard's pool, allocating only if empty.
+func (tm *TTLShardedMap[K, V]) newEntry(shard *ttlShard[K, V]) *ttlEntry[V] {
+	if tm.pooled {
+		if e, ok := shard.pool.Get().(*ttlEntry[V]); ok {
+			return e
+		}
+	}
+	return new(ttlEntry[V])
+}
+
+// releaseEntry clears a removed wrapper and returns it to the shard's pool.
+// Must be called with the shard lock held, after the entry is unreachable.
+func (tm *TTLShardedMap[K, V]) releaseEntry(shard *ttlShard[K, V], e *ttlEntry[V]) {
+	if !tm.pooled {
+		return
+	}
+	// Drop the value so the pool doesn't keep it alive
+	*e = ttlEntry[V]{}
+	shard.pool.Put(e)
+}
+
+// Set inserts or updates a value that expires after ttl.
+func (tm *TTLShardedMap[K, V]) Set(key K, value V, ttl time.Duration) {
+	shard := tm.shardFor(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	e, exists := shard.entries[key]
+	if !exists {
+		e = tm.newEntry(shard)
+		shard.entries[key] = e
+	}
+	e.value = value
+	e.expiresAt = time.Now().Add(ttl)
+}
+
+// Get retrieves a value if present and not expired.
+func (tm *TTLShardedMap[K, V]) Get(key K) (V, bool) {
+	shard := tm.shardFor(key)
+	shard.mu.RLock()
+	e, exists := shard.entries[key]
+	if exists && time.Now().Before(e.expiresAt) {
+		value := e.value
+		shard.mu.RUnlock()
+		return value, true
+	}
+	shard.mu.RUnlock()
+
+	var zero V
+	if exists {
+		// Expired: upgrade to a write lock and remove it if still stale
+		shard.mu.Lock()
+		if e, ok := shard.entries[key]; ok && !time.Now().Before(e.expiresAt) {
+			delete(shard.entries, key)
+			tm.releaseEntry(shard, e)
+		}
+		shard.mu.Unlock()
+	}
+	return zero, false
+}
+
+// Delete removes a key from the map.
+func (tm *TTLShardedMap[K, V]) Delete(key K) {
+	shard := tm.shardFor(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	if e, exists := shard.entries[key]; exists {
+		delete(shard.entries, key)
+		tm.releaseEntry(shard, e)
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// TestTTLBasicOperations tests Set, Get and Delete on a TTLShardedMap
+func TestTTLBasicOperations(t *testing.T) {
+	tm := NewTTLShardedMap[string, int](8)
+
+	tm.Set("key", 42, time.Minute)
+	if val, exists := tm.Get("key"); !exists || val != 42 {
+		t.Errorf("Expected key=42, got %d, exists=%v", val, exists)
+	}
+
+	tm.Delete("key")
+	if _, exists := tm.Get("key"); exists {
+		t.Error("Expected key to be deleted")
+	}
+
+	// A reused wrapper must not leak the previous value
+	tm.Set("other", 7, time.Minute)
+	if val, _ := tm.Get("other"); val != 7 {
+		t.Errorf("Expected other=7, got %d", val)
+	}
+}
+
+// TestTTLExpiry tests that entries disappear after their TTL
+func TestTTLExpiry(t *testing.T) {
+	tm := NewTTLShardedMap[int, string](4)
+	tm.Set(1, "short", 20*time.Millisecond)
+	tm.Set(2, "long", time.Minute)
+
+	time.Sleep(40 * time.Millisecond)
+
+	if _, exists := tm.Get(1); exists {
+		t.Error("Expected short-lived entry to expire")
+	}
+	if val, exists := tm.Get(2); !exists || val != "long" {
+		t.Errorf("Expected long-lived entry to remain, got %q, exists=%v", val, exists)
+	}
+}
+
+// BenchmarkTTLChurn benchmarks delete-then-insert cycles with and without
+// per-shard wrapper pooling; run with -benchmem to compare allocations
+func BenchmarkTTLChurn(b *testing.B) {
+	for _, bc := range []struct {
+		name   string
+		pooled bool
+	}{
+		{"pooled", true},
+		{"unpooled", false},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			tm := newTTLShardedMap[int, int](64, bc.pooled)
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					key := i % 1024
+					tm.Set(key, i, time.Minute)
+					tm.Delete(key)
+					i++
+				}
+			})
+		})
+	}
+}
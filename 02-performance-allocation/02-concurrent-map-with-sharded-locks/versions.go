@@ -0,0 +1,84 @@
+package main
+
+// Entry is a key-value pair returned by ChangedSince or TopN, with the
+// version it was last written at. Version is zero without WithVersioning.
+type Entry[K comparable, V any] struct {
+	Key     K
+	Value   V
+	Version uint64
+}
+
+// WithVersioning makes the map track a version for every entry, so that
+// ChangedSince can report what was written after a given point. Versions
+// come from one map-wide counter and increase with every write; tracking
+// costs an extra map insert per write, so it is off by default.
+func WithVersioning[K comparable, V any]() Option[K, V] {
+	return func(sm *ShardedMap[K, V]) {
+		sm.versions = make([]map[K]uint64, sm.shardCount)
+		for i := range sm.versions {
+			sm.versions[i] = make(map[K]uint64)
+		}
+	}
+}
+
+// touch records a write to key. The caller must hold the shard's write lock;
+// ChangedSince relies on versions being assigned under it.
+func (sm *ShardedMap[K, V]) touch(shardIndex uint64, key K) {
+	sm.modified()
+	sm.created(shardIndex, key)
+	if sm.versions != nil {
+		sm.versions[shardIndex][key] = sm.clock.Add(1)
+	}
+}
+
+// forget drops key's version and metadata after a delete. The caller must
+// hold the shard's write lock.
+func (sm *ShardedMap[K, V]) forget(shardIndex uint64, key K) {
+	sm.modified()
+	if sm.versions != nil {
+		delete(sm.versions[shardIndex], key)
+	}
+	if sm.metas != nil {
+		delete(sm.metas[shardIndex], key)
+	}
+}
+
+// resetTracking drops the versions and metadata of every key in shard i,
+// sized for n new keys, after its entries have been replaced wholesale. The
+// caller must hold the shard's write lock.
+func (sm *ShardedMap[K, V]) resetTracking(i int, n int) {
+	if sm.versions != nil {
+		sm.versions[i] = make(map[K]uint64, n)
+	}
+	if sm.metas != nil {
+		sm.metas[i] = make(map[K]*entryMeta, n)
+	}
+}
+
+// ChangedSince returns the entries written after version, along with a new
+// high-water mark to pass to the next call. Deleted keys are not reported.
+//
+// Shards are scanned one at a time, so an entry written during the scan may
+// be returned both now and by the next call, but none is ever missed.
+// Without WithVersioning it always returns no entries and 0.
+func (sm *ShardedMap[K, V]) ChangedSince(version uint64) ([]Entry[K, V], uint64) {
+	if sm.versions == nil {
+		return nil, 0
+	}
+
+	// Taken before scanning: any write numbered at or below it has either
+	// finished or still holds its shard's lock, which the scan waits for
+	highWater := sm.clock.Load()
+
+	var changed []Entry[K, V]
+	for i := range sm.shards {
+		sm.shardMutex[i].RLock()
+		for key, v := range sm.versions[i] {
+			if v > version {
+				changed = append(changed, Entry[K, V]{Key: key, Value: sm.cloned(sm.shards[i][key]), Version: v})
+			}
+		}
+		sm.shardMutex[i].RUnlock()
+	}
+	return changed, highWater
+}
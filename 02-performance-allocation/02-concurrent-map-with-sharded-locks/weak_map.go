@@ -0,0 +1,71 @@
+package main
+
+import (
+	"runtime"
+	"weak"
+)
+
+// WeakShardedMap is a sharded map whose values don't keep themselves alive.
+// It suits caches of large values that can be rebuilt: once nothing outside
+// the map references a value, the garbage collector may reclaim it, after
+// which Get reports a miss and the entry is removed.
+//
+// The underlying map is not embedded so that every value goes through
+// methods that handle the weak references.
+type WeakShardedMap[K comparable, V any] struct {
+	sm *ShardedMap[K, weak.Pointer[V]]
+}
+
+// weakCleanup identifies the entry to remove once its value is collected.
+type weakCleanup[K comparable, V any] struct {
+	key K
+	ptr weak.Pointer[V]
+}
+
+// NewWeakShardedMap creates a WeakShardedMap with the specified number of shards.
+func NewWeakShardedMap[K comparable, V any](shardCount int, opts ...Option[K, weak.Pointer[V]]) *WeakShardedMap[K, V] {
+	return &WeakShardedMap[K, V]{sm: NewShardedMap[K, weak.Pointer[V]](shardCount, opts...)}
+}
+
+// Get returns the value for key, or false if it is absent or has been
+// collected.
+func (wm *WeakShardedMap[K, V]) Get(key K) (*V, bool) {
+	ptr, exists := wm.sm.Get(key)
+	if !exists {
+		return nil, false
+	}
+	value := ptr.Value()
+	return value, value != nil
+}
+
+// Set stores a weak reference to value under key. value must not be nil.
+func (wm *WeakShardedMap[K, V]) Set(key K, value *V) {
+	ptr := weak.Make(value)
+	wm.sm.Set(key, ptr)
+	runtime.AddCleanup(value, wm.evict, weakCleanup[K, V]{key: key, ptr: ptr})
+}
+
+// Delete removes key from the map.
+func (wm *WeakShardedMap[K, V]) Delete(key K) {
+	wm.sm.Delete(key)
+}
+
+// Keys returns all keys from all shards, including any whose values have
+// been collected but not yet evicted.
+func (wm *WeakShardedMap[K, V]) Keys() []K {
+	return wm.sm.Keys()
+}
+
+// evict removes an entry after its value was collected, unless the key has
+// since been set to a different value.
+func (wm *WeakShardedMap[K, V]) evict(c weakCleanup[K, V]) {
+	sm := wm.sm
+	shardIndex := sm.getShardIndex(c.key)
+	sm.shardMutex[shardIndex].Lock()
+	defer sm.shardMutex[shardIndex].Unlock()
+
+	if sm.shards[shardIndex][c.key] == c.ptr {
+		delete(sm.shards[shardIndex], c.key)
+		sm.forget(shardIndex, c.key)
+	}
+}
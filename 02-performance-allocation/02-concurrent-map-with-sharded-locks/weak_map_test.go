@@ -0,0 +1,58 @@
+package main
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+// blob is a large value worth letting the collector reclaim
+type blob struct {
+	data [1 << 16]byte
+}
+
+// TestWeakShardedMapCollected tests that an entry disappears once its value
+// is no longer referenced and has been collected
+func TestWeakShardedMapCollected(t *testing.T) {
+	wm := NewWeakShardedMap[string, blob](4)
+	wm.Set("dropped", &blob{})
+	kept := &blob{}
+	wm.Set("kept", kept)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		runtime.GC()
+		_, found := wm.Get("dropped")
+		if !found && len(wm.Keys()) == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the dropped entry to be collected and evicted, keys: %v", wm.Keys())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if value, ok := wm.Get("kept"); !ok || value != kept {
+		t.Error("Expected a referenced value to survive collection")
+	}
+	runtime.KeepAlive(kept)
+}
+
+// TestWeakShardedMapOverwriteNotEvicted tests that collecting an overwritten
+// value doesn't evict the key's new value
+func TestWeakShardedMapOverwriteNotEvicted(t *testing.T) {
+	wm := NewWeakShardedMap[string, blob](4)
+	wm.Set("a", &blob{})
+	current := &blob{}
+	wm.Set("a", current)
+
+	for i := 0; i < 5; i++ {
+		runtime.GC()
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if value, ok := wm.Get("a"); !ok || value != current {
+		t.Error("Expected the current value to remain after the old one was collected")
+	}
+	runtime.KeepAlive(current)
+}